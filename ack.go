@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pendingAck is an action sent to Poppit whose result has not been reported
+// yet. It is stored as a member of the ACK_PENDING_KEY sorted set, scored by
+// when it times out.
+type pendingAck struct {
+	CorrelationID string `json:"correlationId"`
+	Repo          string `json:"repo"`
+	Action        string `json:"action"`
+}
+
+func (p pendingAck) member() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+// expectAck starts waiting ACK_TIMEOUT for the result of an action that has
+// just been sent to Poppit. Failures are logged but never block the caller.
+func expectAck(ctx context.Context, rdb *redis.Client, ack pendingAck) {
+	if ackTimeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	deadline := time.Now().Add(ackTimeout)
+	if err := rdb.ZAdd(ctx, ackPendingKey, redis.Z{Score: float64(deadline.UnixMilli()), Member: ack.member()}).Err(); err != nil {
+		log.Printf("[%s] Error tracking acknowledgement of %s for %s: %v", ack.CorrelationID, ack.Action, ack.Repo, err)
+	}
+}
+
+// acknowledge stops waiting for the result of an action once Poppit has
+// reported it
+func acknowledge(ctx context.Context, rdb *redis.Client, ack pendingAck) {
+	if ackTimeout <= 0 {
+		return
+	}
+	removed, err := rdb.ZRem(ctx, ackPendingKey, ack.member()).Result()
+	if err != nil {
+		log.Printf("[%s] Error acknowledging %s for %s: %v", ack.CorrelationID, ack.Action, ack.Repo, err)
+		return
+	}
+	if removed == 0 {
+		log.Printf("[%s] Result of %s for %s arrived after ACK_TIMEOUT or was not expected", ack.CorrelationID, ack.Action, ack.Repo)
+	}
+}
+
+// runAckWatcher alerts on actions whose result has not been reported within
+// ACK_TIMEOUT, checking every SCHEDULE_POLL_INTERVAL
+func runAckWatcher(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		ticker := time.NewTicker(schedulePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if isLeader() {
+					checkUnacknowledged(ctx, rdb)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Alerting on actions without a result after %s", ackTimeout)
+}
+
+func checkUnacknowledged(ctx context.Context, rdb *redis.Client) {
+	overdue, err := rdb.ZRangeByScore(ctx, ackPendingKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: 100,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Error reading pending acknowledgements: %v", err)
+		}
+		return
+	}
+
+	for _, member := range overdue {
+		removed, err := rdb.ZRem(ctx, ackPendingKey, member).Result()
+		if err != nil {
+			log.Printf("Error removing pending acknowledgement: %v", err)
+			continue
+		}
+		if removed == 0 {
+			// Acknowledged meanwhile, or claimed by another instance
+			continue
+		}
+
+		var ack pendingAck
+		if err := json.Unmarshal([]byte(member), &ack); err != nil {
+			log.Printf("Discarding unreadable pending acknowledgement: %v", err)
+			continue
+		}
+		message := fmt.Sprintf("no result for %s within %s of it being sent to Poppit", ack.Action, ackTimeout)
+		recordHistory(ctx, rdb, HistoryEntry{Repo: ack.Repo, Action: ack.Action, Outcome: outcomeUnacknowledged, Error: message, CorrelationID: ack.CorrelationID})
+		actionsUnacknowledged.WithLabelValues(ack.Action, ack.Repo).Inc()
+		emitAlert(Alert{Type: "unacknowledged", Repo: ack.Repo, Message: fmt.Sprintf("[%s] %s", ack.CorrelationID, message)})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// AlertRule runs a project action when a firing Alertmanager alert carries
+// every label in Match
+type AlertRule struct {
+	Name  string            `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+	Match map[string]string `json:"match" yaml:"match" toml:"match"`
+	// Action defaults to restart
+	Action string `json:"action,omitempty" yaml:"action,omitempty" toml:"action,omitempty"`
+	// Cooldown is how long the same alert is ignored after triggering the rule
+	Cooldown string `json:"cooldown,omitempty" yaml:"cooldown,omitempty" toml:"cooldown,omitempty"`
+}
+
+// alertmanagerPayload is the body of an Alertmanager webhook notification
+type alertmanagerPayload struct {
+	Status string              `json:"status"`
+	Alerts []alertmanagerAlert `json:"alerts"`
+}
+
+type alertmanagerAlert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+// alertRuleName returns the name used to address the rule at index i, which
+// is its configured name or its position in the list
+func alertRuleName(r AlertRule, i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return strconv.Itoa(i)
+}
+
+func (r AlertRule) actionOrDefault() string {
+	if r.Action != "" {
+		return r.Action
+	}
+	return "restart"
+}
+
+// cooldownPeriod returns how long the same alert is ignored after it triggers
+// the rule
+func (r AlertRule) cooldownPeriod() time.Duration {
+	if d, err := time.ParseDuration(r.Cooldown); err == nil {
+		return d
+	}
+	return alertmanagerCooldown
+}
+
+// matches reports whether the alert carries every label the rule matches on
+func (r AlertRule) matches(labels map[string]string) bool {
+	for k, v := range r.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// validateAlertRules checks that every alert rule has labels to match, a
+// unique name, a valid cooldown, and an action the project defines
+func validateAlertRules(p Project) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, r := range p.AlertRules {
+		name := alertRuleName(r, i)
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("project %s: duplicate alert rule name %q", p.Repo, name))
+		}
+		seen[name] = true
+
+		if len(r.Match) == 0 {
+			errs = append(errs, fmt.Errorf("project %s: alert rule %s: match must contain at least one label", p.Repo, name))
+		}
+		if r.Cooldown != "" {
+			if d, err := time.ParseDuration(r.Cooldown); err != nil || d < 0 {
+				errs = append(errs, fmt.Errorf("project %s: alert rule %s: invalid cooldown %q", p.Repo, name, r.Cooldown))
+			}
+		}
+		action := r.actionOrDefault()
+		if _, ok := p.Actions[action]; !ok && !slices.Contains(builtinActions, action) {
+			errs = append(errs, fmt.Errorf("project %s: alert rule %s: unknown action %q", p.Repo, name, action))
+		} else if action == "restart" && !p.canRestart() {
+			errs = append(errs, fmt.Errorf("project %s: alert rule %s: restart requires restartCommands", p.Repo, name))
+		}
+	}
+	return errs
+}
+
+// alertFingerprint identifies an alert across notifications, using
+// Alertmanager's fingerprint or, when absent, a hash of its labels
+func alertFingerprint(alert alertmanagerAlert) string {
+	if alert.Fingerprint != "" {
+		return alert.Fingerprint
+	}
+	h := sha256.New()
+	for _, k := range slices.Sorted(maps.Keys(alert.Labels)) {
+		fmt.Fprintf(h, "%s=%s\n", k, alert.Labels[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// triggerAlertRules runs the action of every rule matching a firing alert,
+// skipping rules whose cooldown for that alert is still running. It returns
+// the number of actions submitted.
+func triggerAlertRules(ctx context.Context, rdb *redis.Client, payload alertmanagerPayload) int {
+	triggered := 0
+	for _, alert := range payload.Alerts {
+		if alert.Status != "firing" {
+			continue
+		}
+		fingerprint := alertFingerprint(alert)
+		for _, p := range allProjects() {
+			for i, rule := range p.AlertRules {
+				if !rule.matches(alert.Labels) {
+					continue
+				}
+				id := p.Repo + "#" + alertRuleName(rule, i)
+				if submitAlertRule(ctx, rdb, p, rule, id, alert, fingerprint) {
+					triggered++
+				}
+			}
+		}
+	}
+	return triggered
+}
+
+func submitAlertRule(ctx context.Context, rdb *redis.Client, p Project, rule AlertRule, id string, alert alertmanagerAlert, fingerprint string) bool {
+	action := rule.actionOrDefault()
+	key := alertmanagerKeyPrefix + id + ":" + fingerprint
+	if period := rule.cooldownPeriod(); period > 0 {
+		ok, err := rdb.SetNX(ctx, key, time.Now().UTC().Format(time.RFC3339), period).Result()
+		if err != nil {
+			log.Printf("Error checking cooldown of alert rule %s: %v", id, err)
+			return false
+		}
+		if !ok {
+			log.Printf("Alert %s (%s) matched rule %s, which is cooling down", alert.Labels["alertname"], fingerprint, id)
+			return false
+		}
+	}
+
+	log.Printf("Alert %s (%s) triggered %s for %s via rule %s", alert.Labels["alertname"], fingerprint, action, p.Repo, id)
+	result, err := submitMessage(context.Background(), rdb, RedisMessage{Action: action, Repo: Target(p.Repo)}, "alertmanager")
+	if err != nil {
+		log.Printf("[%s] Error running alert rule %s: %v", result.CorrelationID, id, err)
+		// Let the next notification for the alert try again
+		rdb.Del(ctx, key)
+		return false
+	}
+	return true
+}
+
+// handleAlertmanagerWebhook handles POST /webhooks/alertmanager
+func handleAlertmanagerWebhook(w http.ResponseWriter, r *http.Request) {
+	if alertmanagerToken != "" {
+		want := "Bearer " + alertmanagerToken
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var payload alertmanagerPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	triggered := triggerAlertRules(r.Context(), redisClient, payload)
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: fmt.Sprintf("Triggered %d actions", triggered)})
+}
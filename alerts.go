@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Alert is an event published for operators and alerting tools to act on
+type Alert struct {
+	Type    string    `json:"type"`
+	Repo    string    `json:"repo,omitempty"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+// emitAlert logs an alert and pushes it onto ALERT_LIST. Failures are logged
+// but never block the caller.
+func emitAlert(alert Alert) {
+	if alert.At.IsZero() {
+		alert.At = time.Now().UTC()
+	}
+	log.Printf("ALERT %s for %s: %s", alert.Type, alert.Repo, alert.Message)
+	publishEvent(Event{Type: eventAlert, Repo: alert.Repo, Alert: alert.Type, Message: alert.Message, At: alert.At})
+	if redisClient == nil || alertList == "" {
+		return
+	}
+
+	data, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Error emitting alert: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := redisClient.RPush(ctx, alertList, data).Err(); err != nil {
+		log.Printf("Error emitting alert to %s: %v", alertList, err)
+	}
+}
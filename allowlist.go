@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+var (
+	allowedNetworksMu sync.RWMutex
+	// allowedNetworks are the networks HTTP clients may connect from. Every
+	// client is allowed when it is empty.
+	allowedNetworks []netip.Prefix
+)
+
+// loadAllowedNetworks reads CIDR ranges and single addresses from
+// HTTP_ALLOWED_CIDRS. The current networks are kept if it cannot be parsed.
+func loadAllowedNetworks() error {
+	var networks []netip.Prefix
+	for _, entry := range getEnvList("HTTP_ALLOWED_CIDRS", "") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return fmt.Errorf("invalid network %q in HTTP_ALLOWED_CIDRS: %w", entry, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		networks = append(networks, prefix.Masked())
+	}
+
+	allowedNetworksMu.Lock()
+	allowedNetworks = networks
+	allowedNetworksMu.Unlock()
+	return nil
+}
+
+// viaUnixSocket reports whether the request arrived on the unix socket
+func viaUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// clientAllowed reports whether the request comes from an allowed network.
+// Requests on the unix socket have no address and are always allowed.
+func clientAllowed(r *http.Request) bool {
+	allowedNetworksMu.RLock()
+	networks := allowedNetworks
+	allowedNetworksMu.RUnlock()
+	if len(networks) == 0 || viaUnixSocket(r) {
+		return true
+	}
+
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	return slices.ContainsFunc(networks, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// allowClients answers 403 Forbidden to clients outside HTTP_ALLOWED_CIDRS,
+// before any other checks
+func allowClients(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !clientAllowed(r) {
+			log.Printf("[%s] Denied %s %s from %s: not in HTTP_ALLOWED_CIDRS", requestID(r.Context()), r.Method, r.URL.Path, r.RemoteAddr)
+			clientsDenied.Inc()
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
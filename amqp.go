@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// runAMQPConsumer consumes commands from AMQP_QUEUE with manual acks,
+// reconnecting whenever the connection to the broker is lost. With leader
+// election, only the leader consumes, so messages are never delivered to an
+// instance that would not process them.
+func runAMQPConsumer(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		for ctx.Err() == nil {
+			if !isLeader() {
+				time.Sleep(time.Second)
+				continue
+			}
+			if err := consumeAMQP(ctx, rdb); err != nil {
+				log.Printf("AMQP consumer stopped: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}()
+
+	log.Printf("Consuming AMQP queue %s", amqpQueue)
+}
+
+// consumeAMQP consumes until ctx is done, this instance stops leading, or the
+// connection is lost. Closing the connection returns any unacknowledged
+// deliveries to the queue.
+func consumeAMQP(ctx context.Context, rdb *redis.Client) error {
+	conn, err := amqp.DialConfig(amqpURL, amqp.Config{
+		Properties: amqp.Table{"connection_name": "TurnItOffAndOnAgain " + instanceID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to AMQP broker: %w", err)
+	}
+	defer conn.Close()
+
+	if err := ensureQueue(conn, amqpQueue); err != nil {
+		return err
+	}
+	if amqpDeadLetterQueue != "" {
+		if err := ensureQueue(conn, amqpDeadLetterQueue); err != nil {
+			return err
+		}
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+	if err := ch.Qos(1, 0, false); err != nil {
+		return fmt.Errorf("failed to set AMQP prefetch: %w", err)
+	}
+	deliveries, err := ch.Consume(amqpQueue, "tioaoa-"+instanceID, false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume AMQP queue %s: %w", amqpQueue, err)
+	}
+
+	leaderCtx, cancel := whileLeading(ctx)
+	defer cancel()
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	for {
+		select {
+		case <-leaderCtx.Done():
+			return nil
+		case err := <-closed:
+			return fmt.Errorf("AMQP connection closed: %w", err)
+		case d, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("AMQP delivery channel closed")
+			}
+			processAMQPDelivery(leaderCtx, rdb, ch, d)
+		}
+	}
+}
+
+// ensureQueue declares a durable queue unless it already exists, in which
+// case its existing arguments, such as a dead-letter exchange, are kept
+func ensureQueue(conn *amqp.Connection, name string) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+	defer ch.Close()
+	if _, err := ch.QueueDeclarePassive(name, true, false, false, false, nil); err == nil {
+		return nil
+	}
+
+	// A failed passive declare closes the channel
+	ch, err = conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+	defer ch.Close()
+	if _, err := ch.QueueDeclare(name, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare AMQP queue %s: %w", name, err)
+	}
+	return nil
+}
+
+// processAMQPDelivery processes a delivery, retrying failures that may pass
+// with a growing delay. The delivery is acknowledged once processed. It is
+// dead-lettered after AMQP_MAX_RETRIES such failures, or straight away when
+// processing it again would either fail again or dispatch projects that were
+// dispatched already.
+func processAMQPDelivery(ctx context.Context, rdb *redis.Client, ch *amqp.Channel, d amqp.Delivery) {
+	log.Printf("Received message from AMQP queue %s: %s", amqpQueue, d.Body)
+	for attempt := 1; ; attempt++ {
+		err := receiveMessage(withSource(ctx, "amqp:"+amqpQueue), rdb, string(d.Body))
+		if err == nil {
+			if err := d.Ack(false); err != nil {
+				log.Printf("Error acknowledging AMQP message: %v", err)
+			}
+			return
+		}
+		if ctx.Err() != nil {
+			// Left unacknowledged, so the broker delivers it again
+			return
+		}
+		if !retryableError(err) {
+			log.Printf("Error processing AMQP message, dead-lettering it: %v", err)
+			deadLetterAMQP(ctx, ch, d, err)
+			return
+		}
+		if attempt >= amqpMaxRetries {
+			log.Printf("Error processing AMQP message, dead-lettering after %d attempts: %v", attempt, err)
+			deadLetterAMQP(ctx, ch, d, err)
+			return
+		}
+		log.Printf("Error processing AMQP message (attempt %d of %d): %v", attempt, amqpMaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+}
+
+// deadLetterAMQP moves a message that could not be processed to
+// AMQP_DEAD_LETTER_QUEUE, recording the error in its headers. Without a
+// dead-letter queue the message is rejected, so the broker dead-letters it
+// if the queue has a dead-letter exchange and drops it otherwise.
+func deadLetterAMQP(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, cause error) {
+	if amqpDeadLetterQueue == "" {
+		if err := d.Nack(false, false); err != nil {
+			log.Printf("Error rejecting AMQP message: %v", err)
+		}
+		return
+	}
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers["x-tioaoa-error"] = cause.Error()
+	headers["x-tioaoa-queue"] = amqpQueue
+	err := ch.PublishWithContext(ctx, "", amqpDeadLetterQueue, false, false, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   d.ContentType,
+		CorrelationId: d.CorrelationId,
+		DeliveryMode:  amqp.Persistent,
+		Timestamp:     time.Now(),
+		Body:          d.Body,
+	})
+	if err != nil {
+		// Requeue rather than lose the message
+		log.Printf("Error dead-lettering AMQP message to %s: %v", amqpDeadLetterQueue, err)
+		d.Nack(false, true)
+		return
+	}
+	if err := d.Ack(false); err != nil {
+		log.Printf("Error acknowledging AMQP message: %v", err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package api holds the gRPC API definition and the code generated from it
+package api
+
+//go:generate buf generate
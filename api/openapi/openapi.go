@@ -0,0 +1,12 @@
+// Package openapi holds the OpenAPI definition of the HTTP API and the request
+// and response types generated from it
+package openapi
+
+import _ "embed"
+
+//go:generate oapi-codegen -config oapi-codegen.yaml openapi.yaml
+
+// Spec is the OpenAPI document in YAML
+//
+//go:embed openapi.yaml
+var Spec []byte
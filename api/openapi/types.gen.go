@@ -0,0 +1,644 @@
+// Package openapi provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.5.1 DO NOT EDIT.
+package openapi
+
+import (
+	"time"
+)
+
+const (
+	ApiKeyScopes = "apiKey.Scopes"
+)
+
+// Defines values for AlertmanagerPayloadAlertsStatus.
+const (
+	AlertmanagerPayloadAlertsStatusFiring   AlertmanagerPayloadAlertsStatus = "firing"
+	AlertmanagerPayloadAlertsStatusResolved AlertmanagerPayloadAlertsStatus = "resolved"
+)
+
+// Defines values for BatchItemResultStatus.
+const (
+	BatchItemResultStatusError   BatchItemResultStatus = "error"
+	BatchItemResultStatusSkipped BatchItemResultStatus = "skipped"
+	BatchItemResultStatusSuccess BatchItemResultStatus = "success"
+)
+
+// Defines values for BatchResponseStatus.
+const (
+	BatchResponseStatusError   BatchResponseStatus = "error"
+	BatchResponseStatusPartial BatchResponseStatus = "partial"
+	BatchResponseStatusSuccess BatchResponseStatus = "success"
+)
+
+// Defines values for DesiredStateRequestState.
+const (
+	DesiredStateRequestStateDown  DesiredStateRequestState = "down"
+	DesiredStateRequestStateEmpty DesiredStateRequestState = ""
+	DesiredStateRequestStateUp    DesiredStateRequestState = "up"
+)
+
+// Defines values for EventType.
+const (
+	EventTypeAlert      EventType = "alert"
+	EventTypeDispatched EventType = "dispatched"
+	EventTypeReceived   EventType = "received"
+	EventTypeResolved   EventType = "resolved"
+	EventTypeResult     EventType = "result"
+	EventTypeState      EventType = "state"
+)
+
+// Defines values for HistoryEntryOutcome.
+const (
+	HistoryEntryOutcomeCollapsed      HistoryEntryOutcome = "collapsed"
+	HistoryEntryOutcomeCompleted      HistoryEntryOutcome = "completed"
+	HistoryEntryOutcomeDeferred       HistoryEntryOutcome = "deferred"
+	HistoryEntryOutcomeDispatched     HistoryEntryOutcome = "dispatched"
+	HistoryEntryOutcomeFailed         HistoryEntryOutcome = "failed"
+	HistoryEntryOutcomeSkipped        HistoryEntryOutcome = "skipped"
+	HistoryEntryOutcomeUnacknowledged HistoryEntryOutcome = "unacknowledged"
+)
+
+// Defines values for JobState.
+const (
+	JobStateCompleted  JobState = "completed"
+	JobStateDispatched JobState = "dispatched"
+	JobStateFailed     JobState = "failed"
+	JobStateQueued     JobState = "queued"
+	JobStateScheduled  JobState = "scheduled"
+)
+
+// Defines values for KubernetesExecutorKind.
+const (
+	Deployment  KubernetesExecutorKind = "deployment"
+	Statefulset KubernetesExecutorKind = "statefulset"
+)
+
+// Defines values for ProjectStateHealth.
+const (
+	ProjectStateHealthHealthy   ProjectStateHealth = "healthy"
+	ProjectStateHealthUnhealthy ProjectStateHealth = "unhealthy"
+)
+
+// Defines values for ProjectStateState.
+const (
+	ProjectStateStateDown     ProjectStateState = "down"
+	ProjectStateStateFailed   ProjectStateState = "failed"
+	ProjectStateStateStarting ProjectStateState = "starting"
+	ProjectStateStateStopping ProjectStateState = "stopping"
+	ProjectStateStateUnknown  ProjectStateState = "unknown"
+	ProjectStateStateUp       ProjectStateState = "up"
+)
+
+// Defines values for ProjectStatusHealth.
+const (
+	ProjectStatusHealthHealthy   ProjectStatusHealth = "healthy"
+	ProjectStatusHealthUnhealthy ProjectStatusHealth = "unhealthy"
+)
+
+// Defines values for ProjectStatusState.
+const (
+	Down     ProjectStatusState = "down"
+	Failed   ProjectStatusState = "failed"
+	Starting ProjectStatusState = "starting"
+	Stopping ProjectStatusState = "stopping"
+	Unknown  ProjectStatusState = "unknown"
+	Up       ProjectStatusState = "up"
+)
+
+// Defines values for QueueConfigFields.
+const (
+	Action        QueueConfigFields = "action"
+	Branch        QueueConfigFields = "branch"
+	Commands      QueueConfigFields = "commands"
+	CorrelationID QueueConfigFields = "correlationId"
+	Dir           QueueConfigFields = "dir"
+	Env           QueueConfigFields = "env"
+	Repo          QueueConfigFields = "repo"
+	RequestID     QueueConfigFields = "requestId"
+	Type          QueueConfigFields = "type"
+)
+
+// Defines values for StatusResponseStatus.
+const (
+	StatusResponseStatusSuccess StatusResponseStatus = "success"
+)
+
+// Defines values for ValidateConfigParamsFormat.
+const (
+	JSON ValidateConfigParamsFormat = "json"
+	Toml ValidateConfigParamsFormat = "toml"
+	Yaml ValidateConfigParamsFormat = "yaml"
+	Yml  ValidateConfigParamsFormat = "yml"
+)
+
+// Defines values for PostBatchParamsMode.
+const (
+	BestEffort PostBatchParamsMode = "best-effort"
+	FailFast   PostBatchParamsMode = "fail-fast"
+)
+
+// AlertmanagerPayload defines model for AlertmanagerPayload.
+type AlertmanagerPayload struct {
+	Alerts []struct {
+		Fingerprint string                          `json:"fingerprint,omitempty"`
+		Labels      map[string]string               `json:"labels,omitempty"`
+		Status      AlertmanagerPayloadAlertsStatus `json:"status,omitempty"`
+	} `json:"alerts,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// AlertmanagerPayloadAlertsStatus defines model for AlertmanagerPayload.Alerts.Status.
+type AlertmanagerPayloadAlertsStatus string
+
+// ApplyResult defines model for ApplyResult.
+type ApplyResult struct {
+	CorrelationID string   `json:"correlationId"`
+	Down          []string `json:"down"`
+	Unchanged     []string `json:"unchanged"`
+	Up            []string `json:"up"`
+}
+
+// BatchItemResult defines model for BatchItemResult.
+type BatchItemResult struct {
+	CorrelationID string                `json:"correlationId,omitempty"`
+	Error         string                `json:"error,omitempty"`
+	Index         int                   `json:"index"`
+	Status        BatchItemResultStatus `json:"status"`
+}
+
+// BatchItemResultStatus defines model for BatchItemResult.Status.
+type BatchItemResultStatus string
+
+// BatchResponse defines model for BatchResponse.
+type BatchResponse struct {
+	Results []BatchItemResult   `json:"results"`
+	Status  BatchResponseStatus `json:"status"`
+}
+
+// BatchResponseStatus defines model for BatchResponse.Status.
+type BatchResponseStatus string
+
+// Config defines model for Config.
+type Config struct {
+	Projects []Project `json:"projects"`
+
+	// Queues Notification formats of target queues, keyed by queue name
+	Queues    map[string]QueueConfig `json:"queues,omitempty"`
+	Templates map[string]Project     `json:"templates,omitempty"`
+}
+
+// ConfigRevision defines model for ConfigRevision.
+type ConfigRevision struct {
+	Checksum  string    `json:"checksum"`
+	Config    Config    `json:"config,omitempty"`
+	ID        int64     `json:"id"`
+	Projects  int       `json:"projects"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DesiredStateRequest defines model for DesiredStateRequest.
+type DesiredStateRequest struct {
+	// State An empty state removes the override
+	State DesiredStateRequestState `json:"state"`
+}
+
+// DesiredStateRequestState An empty state removes the override
+type DesiredStateRequestState string
+
+// DockerExecutor defines model for DockerExecutor.
+type DockerExecutor struct {
+	// ComposeProject Compose project whose containers are the project's, by default derived from dir
+	ComposeProject string `json:"composeProject,omitempty"`
+
+	// Containers Names or IDs of the project's containers
+	Containers []string `json:"containers,omitempty"`
+
+	// Labels Labels, as key or key=value, that select the project's containers
+	Labels []string `json:"labels,omitempty"`
+
+	// StopTimeout Go duration containers get to stop before they are killed
+	StopTimeout string `json:"stopTimeout,omitempty"`
+}
+
+// Event defines model for Event.
+type Event struct {
+	Action        string    `json:"action,omitempty"`
+	Alert         string    `json:"alert,omitempty"`
+	At            time.Time `json:"at"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Message       string    `json:"message,omitempty"`
+	PreviousState string    `json:"previousState,omitempty"`
+	Repo          string    `json:"repo,omitempty"`
+	Repos         []string  `json:"repos,omitempty"`
+	Source        string    `json:"source,omitempty"`
+	State         string    `json:"state,omitempty"`
+	Target        string    `json:"target,omitempty"`
+	Type          EventType `json:"type"`
+}
+
+// EventType defines model for Event.Type.
+type EventType string
+
+// HistoryEntry defines model for HistoryEntry.
+type HistoryEntry struct {
+	Action        string              `json:"action"`
+	CorrelationID string              `json:"correlationId,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	ID            string              `json:"id,omitempty"`
+	Outcome       HistoryEntryOutcome `json:"outcome"`
+	Repo          string              `json:"repo"`
+	Source        string              `json:"source,omitempty"`
+	TargetQueue   string              `json:"targetQueue,omitempty"`
+	Timestamp     time.Time           `json:"timestamp"`
+}
+
+// HistoryEntryOutcome defines model for HistoryEntry.Outcome.
+type HistoryEntryOutcome string
+
+// HistoryPage defines model for HistoryPage.
+type HistoryPage struct {
+	Entries    []HistoryEntry `json:"entries"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// Job defines model for Job.
+type Job struct {
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"createdAt"`
+	Error     string    `json:"error,omitempty"`
+	ID        string    `json:"id"`
+	State     JobState  `json:"state"`
+	Target    string    `json:"target"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// JobState defines model for Job.State.
+type JobState string
+
+// KubernetesExecutor defines model for KubernetesExecutor.
+type KubernetesExecutor struct {
+	Kind      KubernetesExecutorKind `json:"kind,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Namespace string                 `json:"namespace,omitempty"`
+
+	// Replicas Replicas that up scales the workload to
+	Replicas int `json:"replicas,omitempty"`
+}
+
+// KubernetesExecutorKind defines model for KubernetesExecutor.Kind.
+type KubernetesExecutorKind string
+
+// LocalExecutor defines model for LocalExecutor.
+type LocalExecutor struct {
+	// Shell Shell that runs each command with -c
+	Shell string `json:"shell,omitempty"`
+
+	// Timeout Go duration bounding all the commands of an action
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Message A version 1 message names its action as a key, e.g. {"up": "its-the-vibe/InnerGate"},
+// or uses action and repo for custom actions.
+type Message struct {
+	Action        string    `json:"action,omitempty"`
+	At            time.Time `json:"at,omitempty"`
+	Branch        string    `json:"branch,omitempty"`
+	Cancel        string    `json:"cancel,omitempty"`
+	Cascade       bool      `json:"cascade,omitempty"`
+	Confirm       bool      `json:"confirm,omitempty"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Delay         string    `json:"delay,omitempty"`
+
+	// Down A repo, alias, group:<name>, glob pattern, or all as a string, or a
+	// label selector as {"selector": "team=vibe,tier=backend"}
+	Down          Target    `json:"down,omitempty"`
+	ExpiresAt     time.Time `json:"expiresAt,omitempty"`
+	ExtraCommands []string  `json:"extraCommands,omitempty"`
+	Force         bool      `json:"force,omitempty"`
+
+	// IdempotencyKey Retries with the same key within IDEMPOTENCY_WINDOW are not dispatched again
+	IdempotencyKey       string `json:"idempotencyKey,omitempty"`
+	IfState              string `json:"ifState,omitempty"`
+	PrependExtraCommands bool   `json:"prependExtraCommands,omitempty"`
+	Priority             string `json:"priority,omitempty"`
+	ReplyTo              string `json:"replyTo,omitempty"`
+
+	// Repo A repo, alias, group:<name>, glob pattern, or all as a string, or a
+	// label selector as {"selector": "team=vibe,tier=backend"}
+	Repo Target `json:"repo,omitempty"`
+
+	// Restart A repo, alias, group:<name>, glob pattern, or all as a string, or a
+	// label selector as {"selector": "team=vibe,tier=backend"}
+	Restart     Target `json:"restart,omitempty"`
+	Status      string `json:"status,omitempty"`
+	TargetQueue string `json:"target-queue,omitempty"`
+
+	// Toggle A repo, alias, group:<name>, glob pattern, or all as a string, or a
+	// label selector as {"selector": "team=vibe,tier=backend"}
+	Toggle Target `json:"toggle,omitempty"`
+
+	// Up A repo, alias, group:<name>, glob pattern, or all as a string, or a
+	// label selector as {"selector": "team=vibe,tier=backend"}
+	Up Target `json:"up,omitempty"`
+}
+
+// MessageOrBatch A Message, or a JSON array of Messages processed as a batch
+type MessageOrBatch = interface{}
+
+// MessageVersions defines model for MessageVersions.
+type MessageVersions struct {
+	Current   int   `json:"current"`
+	Supported []int `json:"supported"`
+}
+
+// Project A project definition; see the README for every field
+type Project struct {
+	Actions      map[string][]string `json:"actions,omitempty"`
+	Aliases      []string            `json:"aliases,omitempty"`
+	DependsOn    []string            `json:"dependsOn,omitempty"`
+	Dir          string              `json:"dir,omitempty"`
+	Docker       DockerExecutor      `json:"docker,omitempty"`
+	DownCommands []string            `json:"downCommands,omitempty"`
+	Env          map[string]string   `json:"env,omitempty"`
+
+	// Executor What carries out the project's actions, poppit (the default), webhook, local, ssh, docker, or kubernetes
+	Executor        string             `json:"executor,omitempty"`
+	Extends         string             `json:"extends,omitempty"`
+	Group           string             `json:"group,omitempty"`
+	Kubernetes      KubernetesExecutor `json:"kubernetes,omitempty"`
+	Labels          map[string]string  `json:"labels,omitempty"`
+	Local           LocalExecutor      `json:"local,omitempty"`
+	Repo            string             `json:"repo,omitempty"`
+	RestartCommands []string           `json:"restartCommands,omitempty"`
+	SSH             SSHExecutor        `json:"ssh,omitempty"`
+	TargetQueue     string             `json:"targetQueue,omitempty"`
+	UpCommands      []string           `json:"upCommands,omitempty"`
+	Webhook         WebhookExecutor    `json:"webhook,omitempty"`
+}
+
+// ProjectState defines model for ProjectState.
+type ProjectState struct {
+	AutoRestartGaveUp bool               `json:"autoRestartGaveUp,omitempty"`
+	AutoRestarts      int                `json:"autoRestarts,omitempty"`
+	Flapping          bool               `json:"flapping,omitempty"`
+	Health            ProjectStateHealth `json:"health,omitempty"`
+	HealthCheckedAt   time.Time          `json:"healthCheckedAt,omitempty"`
+	HealthError       string             `json:"healthError,omitempty"`
+	HealthFailures    int                `json:"healthFailures,omitempty"`
+	IdleStopped       bool               `json:"idleStopped,omitempty"`
+	LastAction        string             `json:"lastAction,omitempty"`
+	LastActionAt      time.Time          `json:"lastActionAt,omitempty"`
+	LastActivityAt    time.Time          `json:"lastActivityAt,omitempty"`
+	LastAutoRestartAt time.Time          `json:"lastAutoRestartAt,omitempty"`
+
+	// LastCorrelationID Correlation ID of the last action, which its result on RESULTS_QUEUE must match
+	LastCorrelationID string            `json:"lastCorrelationId,omitempty"`
+	Repo              string            `json:"repo,omitempty"`
+	State             ProjectStateState `json:"state"`
+	StateChangedAt    time.Time         `json:"stateChangedAt,omitempty"`
+}
+
+// ProjectStateHealth defines model for ProjectState.Health.
+type ProjectStateHealth string
+
+// ProjectStateState defines model for ProjectState.State.
+type ProjectStateState string
+
+// ProjectStatus defines model for ProjectStatus.
+type ProjectStatus struct {
+	AutoRestartGaveUp bool                `json:"autoRestartGaveUp,omitempty"`
+	AutoRestarts      int                 `json:"autoRestarts,omitempty"`
+	Flapping          bool                `json:"flapping,omitempty"`
+	Health            ProjectStatusHealth `json:"health,omitempty"`
+	HealthCheckedAt   time.Time           `json:"healthCheckedAt,omitempty"`
+	HealthError       string              `json:"healthError,omitempty"`
+	HealthFailures    int                 `json:"healthFailures,omitempty"`
+	IdleStopped       bool                `json:"idleStopped,omitempty"`
+	LastAction        string              `json:"lastAction,omitempty"`
+	LastActionAt      time.Time           `json:"lastActionAt,omitempty"`
+	LastActivityAt    time.Time           `json:"lastActivityAt,omitempty"`
+	LastAutoRestartAt time.Time           `json:"lastAutoRestartAt,omitempty"`
+
+	// LastCorrelationID Correlation ID of the last action, which its result on RESULTS_QUEUE must match
+	LastCorrelationID string             `json:"lastCorrelationId,omitempty"`
+	Repo              string             `json:"repo,omitempty"`
+	Scheduled         []ScheduledJob     `json:"scheduled"`
+	State             ProjectStatusState `json:"state"`
+	StateChangedAt    time.Time          `json:"stateChangedAt,omitempty"`
+}
+
+// ProjectStatusHealth defines model for ProjectStatus.Health.
+type ProjectStatusHealth string
+
+// ProjectStatusState defines model for ProjectStatus.State.
+type ProjectStatusState string
+
+// QueueConfig defines model for QueueConfig.
+type QueueConfig struct {
+	// Fields Keys of a JSON object payload, mapped to the notification fields they are taken from
+	Fields map[string]QueueConfigFields `json:"fields,omitempty"`
+
+	// Template Go template rendering the notification payload
+	Template string `json:"template,omitempty"`
+}
+
+// QueueConfigFields defines model for QueueConfig.Fields.
+type QueueConfigFields string
+
+// QueueDepth defines model for QueueDepth.
+type QueueDepth struct {
+	Depth int64  `json:"depth"`
+	List  string `json:"list"`
+}
+
+// SSHExecutor defines model for SSHExecutor.
+type SSHExecutor struct {
+	// Host Host to run the commands on, with an optional port
+	Host string `json:"host,omitempty"`
+
+	// Key Private key file, or the PEM contents of the key
+	Key string `json:"key,omitempty"`
+
+	// Timeout Go duration bounding all the commands of an action
+	Timeout string `json:"timeout,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// ScheduleStatus defines model for ScheduleStatus.
+type ScheduleStatus struct {
+	Action string    `json:"action"`
+	Cron   string    `json:"cron"`
+	Error  string    `json:"error,omitempty"`
+	ID     string    `json:"id"`
+	Name   string    `json:"name"`
+	Next   time.Time `json:"next"`
+	Paused bool      `json:"paused"`
+	Repo   string    `json:"repo"`
+}
+
+// ScheduledJob defines model for ScheduledJob.
+type ScheduledJob struct {
+	DueAt time.Time `json:"dueAt"`
+	ID    string    `json:"id"`
+
+	// Message A version 1 message names its action as a key, e.g. {"up": "its-the-vibe/InnerGate"},
+	// or uses action and repo for custom actions.
+	Message Message `json:"message"`
+}
+
+// Snapshot defines model for Snapshot.
+type Snapshot struct {
+	DesiredStates   map[string]string `json:"desiredStates"`
+	ExportedAt      time.Time         `json:"exportedAt"`
+	PausedSchedules []string          `json:"pausedSchedules"`
+	Scheduled       []ScheduledJob    `json:"scheduled"`
+	States          []ProjectState    `json:"states"`
+	Version         int               `json:"version"`
+}
+
+// StatusReply defines model for StatusReply.
+type StatusReply struct {
+	Actions         map[string][]string `json:"actions,omitempty"`
+	CorrelationID   string              `json:"correlationId,omitempty"`
+	DownCommands    []string            `json:"downCommands,omitempty"`
+	Found           bool                `json:"found"`
+	LastAction      string              `json:"lastAction,omitempty"`
+	LastActionAt    time.Time           `json:"lastActionAt,omitempty"`
+	Repo            string              `json:"repo"`
+	RestartCommands []string            `json:"restartCommands,omitempty"`
+	State           string              `json:"state,omitempty"`
+	UpCommands      []string            `json:"upCommands,omitempty"`
+}
+
+// StatusResponse defines model for StatusResponse.
+type StatusResponse struct {
+	CorrelationID string `json:"correlationId,omitempty"`
+
+	// JobID ID of the scheduled job, for messages with a future dispatch time
+	JobID   string               `json:"jobId,omitempty"`
+	Message string               `json:"message"`
+	Status  StatusResponseStatus `json:"status"`
+}
+
+// StatusResponseStatus defines model for StatusResponse.Status.
+type StatusResponseStatus string
+
+// Target A repo, alias, group:<name>, glob pattern, or all as a string, or a
+// label selector as {"selector": "team=vibe,tier=backend"}
+type Target = interface{}
+
+// WebhookExecutor defines model for WebhookExecutor.
+type WebhookExecutor struct {
+	// Secret Key the X-Hub-Signature-256 header of each request is signed with
+	Secret string `json:"secret,omitempty"`
+
+	// URL HTTPS endpoint the actions are POSTed to
+	URL string `json:"url,omitempty"`
+}
+
+// Name defines model for Name.
+type Name = string
+
+// Owner defines model for Owner.
+type Owner = string
+
+// RevisionID defines model for RevisionID.
+type RevisionID = int64
+
+// Schedule defines model for Schedule.
+type Schedule = string
+
+// Success defines model for Success.
+type Success = StatusResponse
+
+// ValidateConfigParams defines parameters for ValidateConfig.
+type ValidateConfigParams struct {
+	Format ValidateConfigParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// ValidateConfigParamsFormat defines parameters for ValidateConfig.
+type ValidateConfigParamsFormat string
+
+// StreamEventsParams defines parameters for StreamEvents.
+type StreamEventsParams struct {
+	Repo string `form:"repo,omitempty" json:"repo,omitempty"`
+
+	// Type Comma-separated event types
+	Type string `form:"type,omitempty" json:"type,omitempty"`
+}
+
+// ListHistoryParams defines parameters for ListHistory.
+type ListHistoryParams struct {
+	Repo   string `form:"repo,omitempty" json:"repo,omitempty"`
+	Action string `form:"action,omitempty" json:"action,omitempty"`
+
+	// Since RFC3339 timestamp, or a duration before now such as 24h
+	Since string `form:"since,omitempty" json:"since,omitempty"`
+	Limit int    `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The nextCursor of the previous page
+	Cursor string `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// PostMessageParams defines parameters for PostMessage.
+type PostMessageParams struct {
+	Async bool `form:"async,omitempty" json:"async,omitempty"`
+
+	// IdempotencyKey Idempotency key of a single message without an idempotencyKey field
+	IdempotencyKey string `json:"Idempotency-Key,omitempty"`
+}
+
+// PostBatchJSONBody defines parameters for PostBatch.
+type PostBatchJSONBody = []Message
+
+// PostBatchParams defines parameters for PostBatch.
+type PostBatchParams struct {
+	Mode PostBatchParamsMode `form:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// PostBatchParamsMode defines parameters for PostBatch.
+type PostBatchParamsMode string
+
+// HandleSlackCommandFormdataBody defines parameters for HandleSlackCommand.
+type HandleSlackCommandFormdataBody = struct {
+}
+
+// ApplyStateJSONBody defines parameters for ApplyState.
+type ApplyStateJSONBody map[string]string
+
+// OpenWebSocketParams defines parameters for OpenWebSocket.
+type OpenWebSocketParams struct {
+	Repo string `form:"repo,omitempty" json:"repo,omitempty"`
+}
+
+// ValidateConfigJSONRequestBody defines body for ValidateConfig for application/json ContentType.
+type ValidateConfigJSONRequestBody = Config
+
+// PostMessageJSONRequestBody defines body for PostMessage for application/json ContentType.
+type PostMessageJSONRequestBody = MessageOrBatch
+
+// PostBatchJSONRequestBody defines body for PostBatch for application/json ContentType.
+type PostBatchJSONRequestBody = PostBatchJSONBody
+
+// CreateProjectJSONRequestBody defines body for CreateProject for application/json ContentType.
+type CreateProjectJSONRequestBody = Project
+
+// UpdateProjectJSONRequestBody defines body for UpdateProject for application/json ContentType.
+type UpdateProjectJSONRequestBody = Project
+
+// SetDesiredStateJSONRequestBody defines body for SetDesiredState for application/json ContentType.
+type SetDesiredStateJSONRequestBody = DesiredStateRequest
+
+// HandleSlackCommandFormdataRequestBody defines body for HandleSlackCommand for application/x-www-form-urlencoded ContentType.
+type HandleSlackCommandFormdataRequestBody = HandleSlackCommandFormdataBody
+
+// ImportSnapshotJSONRequestBody defines body for ImportSnapshot for application/json ContentType.
+type ImportSnapshotJSONRequestBody = Snapshot
+
+// ApplyStateJSONRequestBody defines body for ApplyState for application/json ContentType.
+type ApplyStateJSONRequestBody ApplyStateJSONBody
+
+// HandleAlertmanagerWebhookJSONRequestBody defines body for HandleAlertmanagerWebhook for application/json ContentType.
+type HandleAlertmanagerWebhookJSONRequestBody = AlertmanagerPayload
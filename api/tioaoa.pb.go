@@ -0,0 +1,810 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: tioaoa.proto
+
+package api
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubmitActionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Action is up, down, restart, toggle, or the name of a custom action.
+	Action string `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	// Target is a repo, alias, glob, group, label selector, or "all".
+	Target        string `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	CorrelationId string `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	// Branch overrides the branch sent to Poppit (default refs/heads/main).
+	Branch string `protobuf:"bytes,4,opt,name=branch,proto3" json:"branch,omitempty"`
+	// Delay is a duration such as "10m" to wait before dispatching.
+	Delay string `protobuf:"bytes,5,opt,name=delay,proto3" json:"delay,omitempty"`
+	// At is an RFC3339 time at which to dispatch.
+	At string `protobuf:"bytes,6,opt,name=at,proto3" json:"at,omitempty"`
+	// IfState only dispatches to projects whose tracked state matches.
+	IfState     string `protobuf:"bytes,7,opt,name=if_state,json=ifState,proto3" json:"if_state,omitempty"`
+	Priority    string `protobuf:"bytes,8,opt,name=priority,proto3" json:"priority,omitempty"`
+	TargetQueue string `protobuf:"bytes,9,opt,name=target_queue,json=targetQueue,proto3" json:"target_queue,omitempty"`
+	Cascade     bool   `protobuf:"varint,10,opt,name=cascade,proto3" json:"cascade,omitempty"`
+	Force       bool   `protobuf:"varint,11,opt,name=force,proto3" json:"force,omitempty"`
+	// Confirm must be set to stop every project with a down of "all".
+	Confirm       bool `protobuf:"varint,12,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitActionRequest) Reset() {
+	*x = SubmitActionRequest{}
+	mi := &file_tioaoa_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitActionRequest) ProtoMessage() {}
+
+func (x *SubmitActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitActionRequest.ProtoReflect.Descriptor instead.
+func (*SubmitActionRequest) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitActionRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetDelay() string {
+	if x != nil {
+		return x.Delay
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetAt() string {
+	if x != nil {
+		return x.At
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetIfState() string {
+	if x != nil {
+		return x.IfState
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetTargetQueue() string {
+	if x != nil {
+		return x.TargetQueue
+	}
+	return ""
+}
+
+func (x *SubmitActionRequest) GetCascade() bool {
+	if x != nil {
+		return x.Cascade
+	}
+	return false
+}
+
+func (x *SubmitActionRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+func (x *SubmitActionRequest) GetConfirm() bool {
+	if x != nil {
+		return x.Confirm
+	}
+	return false
+}
+
+type SubmitActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CorrelationId string                 `protobuf:"bytes,1,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// JobId identifies the scheduled job when the action was scheduled.
+	JobId string `protobuf:"bytes,3,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// Queued is set when a follower queued the action for the leader.
+	Queued        bool `protobuf:"varint,4,opt,name=queued,proto3" json:"queued,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitActionResponse) Reset() {
+	*x = SubmitActionResponse{}
+	mi := &file_tioaoa_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitActionResponse) ProtoMessage() {}
+
+func (x *SubmitActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitActionResponse.ProtoReflect.Descriptor instead.
+func (*SubmitActionResponse) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitActionResponse) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *SubmitActionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SubmitActionResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *SubmitActionResponse) GetQueued() bool {
+	if x != nil {
+		return x.Queued
+	}
+	return false
+}
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Repo          string                 `protobuf:"bytes,1,opt,name=repo,proto3" json:"repo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_tioaoa_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetStatusRequest) GetRepo() string {
+	if x != nil {
+		return x.Repo
+	}
+	return ""
+}
+
+type ProjectStatus struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Repo           string                 `protobuf:"bytes,1,opt,name=repo,proto3" json:"repo,omitempty"`
+	State          string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	LastAction     string                 `protobuf:"bytes,3,opt,name=last_action,json=lastAction,proto3" json:"last_action,omitempty"`
+	LastActionAt   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_action_at,json=lastActionAt,proto3" json:"last_action_at,omitempty"`
+	StateChangedAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=state_changed_at,json=stateChangedAt,proto3" json:"state_changed_at,omitempty"`
+	Health         string                 `protobuf:"bytes,6,opt,name=health,proto3" json:"health,omitempty"`
+	HealthError    string                 `protobuf:"bytes,7,opt,name=health_error,json=healthError,proto3" json:"health_error,omitempty"`
+	Flapping       bool                   `protobuf:"varint,8,opt,name=flapping,proto3" json:"flapping,omitempty"`
+	DesiredState   string                 `protobuf:"bytes,9,opt,name=desired_state,json=desiredState,proto3" json:"desired_state,omitempty"`
+	Group          string                 `protobuf:"bytes,10,opt,name=group,proto3" json:"group,omitempty"`
+	Labels         map[string]string      `protobuf:"bytes,11,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Actions lists the project's custom actions.
+	Actions       []string `protobuf:"bytes,12,rep,name=actions,proto3" json:"actions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProjectStatus) Reset() {
+	*x = ProjectStatus{}
+	mi := &file_tioaoa_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProjectStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProjectStatus) ProtoMessage() {}
+
+func (x *ProjectStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProjectStatus.ProtoReflect.Descriptor instead.
+func (*ProjectStatus) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ProjectStatus) GetRepo() string {
+	if x != nil {
+		return x.Repo
+	}
+	return ""
+}
+
+func (x *ProjectStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ProjectStatus) GetLastAction() string {
+	if x != nil {
+		return x.LastAction
+	}
+	return ""
+}
+
+func (x *ProjectStatus) GetLastActionAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastActionAt
+	}
+	return nil
+}
+
+func (x *ProjectStatus) GetStateChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StateChangedAt
+	}
+	return nil
+}
+
+func (x *ProjectStatus) GetHealth() string {
+	if x != nil {
+		return x.Health
+	}
+	return ""
+}
+
+func (x *ProjectStatus) GetHealthError() string {
+	if x != nil {
+		return x.HealthError
+	}
+	return ""
+}
+
+func (x *ProjectStatus) GetFlapping() bool {
+	if x != nil {
+		return x.Flapping
+	}
+	return false
+}
+
+func (x *ProjectStatus) GetDesiredState() string {
+	if x != nil {
+		return x.DesiredState
+	}
+	return ""
+}
+
+func (x *ProjectStatus) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *ProjectStatus) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *ProjectStatus) GetActions() []string {
+	if x != nil {
+		return x.Actions
+	}
+	return nil
+}
+
+type ListProjectsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProjectsRequest) Reset() {
+	*x = ListProjectsRequest{}
+	mi := &file_tioaoa_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProjectsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProjectsRequest) ProtoMessage() {}
+
+func (x *ListProjectsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProjectsRequest.ProtoReflect.Descriptor instead.
+func (*ListProjectsRequest) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{4}
+}
+
+type ListProjectsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Projects      []*ProjectStatus       `protobuf:"bytes,1,rep,name=projects,proto3" json:"projects,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProjectsResponse) Reset() {
+	*x = ListProjectsResponse{}
+	mi := &file_tioaoa_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProjectsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProjectsResponse) ProtoMessage() {}
+
+func (x *ListProjectsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProjectsResponse.ProtoReflect.Descriptor instead.
+func (*ListProjectsResponse) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListProjectsResponse) GetProjects() []*ProjectStatus {
+	if x != nil {
+		return x.Projects
+	}
+	return nil
+}
+
+type WatchEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Repo only streams events for the given project when set.
+	Repo          string `protobuf:"bytes,1,opt,name=repo,proto3" json:"repo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_tioaoa_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchEventsRequest) GetRepo() string {
+	if x != nil {
+		return x.Repo
+	}
+	return ""
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Type is received, resolved, dispatched, state, or alert.
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Repo          string                 `protobuf:"bytes,2,opt,name=repo,proto3" json:"repo,omitempty"`
+	Action        string                 `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
+	PreviousState string                 `protobuf:"bytes,5,opt,name=previous_state,json=previousState,proto3" json:"previous_state,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	CorrelationId string                 `protobuf:"bytes,7,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=at,proto3" json:"at,omitempty"`
+	// Alert is the alert type, such as flapping, for alert events.
+	Alert string `protobuf:"bytes,9,opt,name=alert,proto3" json:"alert,omitempty"`
+	// Target is the target of the action as received.
+	Target string `protobuf:"bytes,10,opt,name=target,proto3" json:"target,omitempty"`
+	// Source is where the message came from, e.g. http or nats:<subject>.
+	Source string `protobuf:"bytes,11,opt,name=source,proto3" json:"source,omitempty"`
+	// Repos lists the projects the target resolved to.
+	Repos         []string `protobuf:"bytes,12,rep,name=repos,proto3" json:"repos,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_tioaoa_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_tioaoa_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_tioaoa_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetRepo() string {
+	if x != nil {
+		return x.Repo
+	}
+	return ""
+}
+
+func (x *Event) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *Event) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Event) GetPreviousState() string {
+	if x != nil {
+		return x.PreviousState
+	}
+	return ""
+}
+
+func (x *Event) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Event) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *Event) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *Event) GetAlert() string {
+	if x != nil {
+		return x.Alert
+	}
+	return ""
+}
+
+func (x *Event) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *Event) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Event) GetRepos() []string {
+	if x != nil {
+		return x.Repos
+	}
+	return nil
+}
+
+var File_tioaoa_proto protoreflect.FileDescriptor
+
+const file_tioaoa_proto_rawDesc = "" +
+	"\n" +
+	"\ftioaoa.proto\x12\ttioaoa.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xce\x02\n" +
+	"\x13SubmitActionRequest\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\x12%\n" +
+	"\x0ecorrelation_id\x18\x03 \x01(\tR\rcorrelationId\x12\x16\n" +
+	"\x06branch\x18\x04 \x01(\tR\x06branch\x12\x14\n" +
+	"\x05delay\x18\x05 \x01(\tR\x05delay\x12\x0e\n" +
+	"\x02at\x18\x06 \x01(\tR\x02at\x12\x19\n" +
+	"\bif_state\x18\a \x01(\tR\aifState\x12\x1a\n" +
+	"\bpriority\x18\b \x01(\tR\bpriority\x12!\n" +
+	"\ftarget_queue\x18\t \x01(\tR\vtargetQueue\x12\x18\n" +
+	"\acascade\x18\n" +
+	" \x01(\bR\acascade\x12\x14\n" +
+	"\x05force\x18\v \x01(\bR\x05force\x12\x18\n" +
+	"\aconfirm\x18\f \x01(\bR\aconfirm\"\x86\x01\n" +
+	"\x14SubmitActionResponse\x12%\n" +
+	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x15\n" +
+	"\x06job_id\x18\x03 \x01(\tR\x05jobId\x12\x16\n" +
+	"\x06queued\x18\x04 \x01(\bR\x06queued\"&\n" +
+	"\x10GetStatusRequest\x12\x12\n" +
+	"\x04repo\x18\x01 \x01(\tR\x04repo\"\x87\x04\n" +
+	"\rProjectStatus\x12\x12\n" +
+	"\x04repo\x18\x01 \x01(\tR\x04repo\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x1f\n" +
+	"\vlast_action\x18\x03 \x01(\tR\n" +
+	"lastAction\x12@\n" +
+	"\x0elast_action_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\flastActionAt\x12D\n" +
+	"\x10state_changed_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x0estateChangedAt\x12\x16\n" +
+	"\x06health\x18\x06 \x01(\tR\x06health\x12!\n" +
+	"\fhealth_error\x18\a \x01(\tR\vhealthError\x12\x1a\n" +
+	"\bflapping\x18\b \x01(\bR\bflapping\x12#\n" +
+	"\rdesired_state\x18\t \x01(\tR\fdesiredState\x12\x14\n" +
+	"\x05group\x18\n" +
+	" \x01(\tR\x05group\x12<\n" +
+	"\x06labels\x18\v \x03(\v2$.tioaoa.v1.ProjectStatus.LabelsEntryR\x06labels\x12\x18\n" +
+	"\aactions\x18\f \x03(\tR\aactions\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x15\n" +
+	"\x13ListProjectsRequest\"L\n" +
+	"\x14ListProjectsResponse\x124\n" +
+	"\bprojects\x18\x01 \x03(\v2\x18.tioaoa.v1.ProjectStatusR\bprojects\"(\n" +
+	"\x12WatchEventsRequest\x12\x12\n" +
+	"\x04repo\x18\x01 \x01(\tR\x04repo\"\xcd\x02\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
+	"\x04repo\x18\x02 \x01(\tR\x04repo\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\x12%\n" +
+	"\x0eprevious_state\x18\x05 \x01(\tR\rpreviousState\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x12%\n" +
+	"\x0ecorrelation_id\x18\a \x01(\tR\rcorrelationId\x12*\n" +
+	"\x02at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12\x14\n" +
+	"\x05alert\x18\t \x01(\tR\x05alert\x12\x16\n" +
+	"\x06target\x18\n" +
+	" \x01(\tR\x06target\x12\x16\n" +
+	"\x06source\x18\v \x01(\tR\x06source\x12\x14\n" +
+	"\x05repos\x18\f \x03(\tR\x05repos2\xb0\x02\n" +
+	"\x06Tioaoa\x12O\n" +
+	"\fSubmitAction\x12\x1e.tioaoa.v1.SubmitActionRequest\x1a\x1f.tioaoa.v1.SubmitActionResponse\x12B\n" +
+	"\tGetStatus\x12\x1b.tioaoa.v1.GetStatusRequest\x1a\x18.tioaoa.v1.ProjectStatus\x12O\n" +
+	"\fListProjects\x12\x1e.tioaoa.v1.ListProjectsRequest\x1a\x1f.tioaoa.v1.ListProjectsResponse\x12@\n" +
+	"\vWatchEvents\x12\x1d.tioaoa.v1.WatchEventsRequest\x1a\x10.tioaoa.v1.Event0\x01B1Z/github.com/its-the-vibe/TurnItOffAndOnAgain/apib\x06proto3"
+
+var (
+	file_tioaoa_proto_rawDescOnce sync.Once
+	file_tioaoa_proto_rawDescData []byte
+)
+
+func file_tioaoa_proto_rawDescGZIP() []byte {
+	file_tioaoa_proto_rawDescOnce.Do(func() {
+		file_tioaoa_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tioaoa_proto_rawDesc), len(file_tioaoa_proto_rawDesc)))
+	})
+	return file_tioaoa_proto_rawDescData
+}
+
+var file_tioaoa_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_tioaoa_proto_goTypes = []any{
+	(*SubmitActionRequest)(nil),   // 0: tioaoa.v1.SubmitActionRequest
+	(*SubmitActionResponse)(nil),  // 1: tioaoa.v1.SubmitActionResponse
+	(*GetStatusRequest)(nil),      // 2: tioaoa.v1.GetStatusRequest
+	(*ProjectStatus)(nil),         // 3: tioaoa.v1.ProjectStatus
+	(*ListProjectsRequest)(nil),   // 4: tioaoa.v1.ListProjectsRequest
+	(*ListProjectsResponse)(nil),  // 5: tioaoa.v1.ListProjectsResponse
+	(*WatchEventsRequest)(nil),    // 6: tioaoa.v1.WatchEventsRequest
+	(*Event)(nil),                 // 7: tioaoa.v1.Event
+	nil,                           // 8: tioaoa.v1.ProjectStatus.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_tioaoa_proto_depIdxs = []int32{
+	9, // 0: tioaoa.v1.ProjectStatus.last_action_at:type_name -> google.protobuf.Timestamp
+	9, // 1: tioaoa.v1.ProjectStatus.state_changed_at:type_name -> google.protobuf.Timestamp
+	8, // 2: tioaoa.v1.ProjectStatus.labels:type_name -> tioaoa.v1.ProjectStatus.LabelsEntry
+	3, // 3: tioaoa.v1.ListProjectsResponse.projects:type_name -> tioaoa.v1.ProjectStatus
+	9, // 4: tioaoa.v1.Event.at:type_name -> google.protobuf.Timestamp
+	0, // 5: tioaoa.v1.Tioaoa.SubmitAction:input_type -> tioaoa.v1.SubmitActionRequest
+	2, // 6: tioaoa.v1.Tioaoa.GetStatus:input_type -> tioaoa.v1.GetStatusRequest
+	4, // 7: tioaoa.v1.Tioaoa.ListProjects:input_type -> tioaoa.v1.ListProjectsRequest
+	6, // 8: tioaoa.v1.Tioaoa.WatchEvents:input_type -> tioaoa.v1.WatchEventsRequest
+	1, // 9: tioaoa.v1.Tioaoa.SubmitAction:output_type -> tioaoa.v1.SubmitActionResponse
+	3, // 10: tioaoa.v1.Tioaoa.GetStatus:output_type -> tioaoa.v1.ProjectStatus
+	5, // 11: tioaoa.v1.Tioaoa.ListProjects:output_type -> tioaoa.v1.ListProjectsResponse
+	7, // 12: tioaoa.v1.Tioaoa.WatchEvents:output_type -> tioaoa.v1.Event
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_tioaoa_proto_init() }
+func file_tioaoa_proto_init() {
+	if File_tioaoa_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tioaoa_proto_rawDesc), len(file_tioaoa_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tioaoa_proto_goTypes,
+		DependencyIndexes: file_tioaoa_proto_depIdxs,
+		MessageInfos:      file_tioaoa_proto_msgTypes,
+	}.Build()
+	File_tioaoa_proto = out.File
+	file_tioaoa_proto_goTypes = nil
+	file_tioaoa_proto_depIdxs = nil
+}
@@ -0,0 +1,255 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: tioaoa.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Tioaoa_SubmitAction_FullMethodName = "/tioaoa.v1.Tioaoa/SubmitAction"
+	Tioaoa_GetStatus_FullMethodName    = "/tioaoa.v1.Tioaoa/GetStatus"
+	Tioaoa_ListProjects_FullMethodName = "/tioaoa.v1.Tioaoa/ListProjects"
+	Tioaoa_WatchEvents_FullMethodName  = "/tioaoa.v1.Tioaoa/WatchEvents"
+)
+
+// TioaoaClient is the client API for Tioaoa service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Tioaoa submits lifecycle actions for projects and reports their state.
+type TioaoaClient interface {
+	// SubmitAction dispatches an action, or schedules it when it has a delay
+	// or a dispatch time.
+	SubmitAction(ctx context.Context, in *SubmitActionRequest, opts ...grpc.CallOption) (*SubmitActionResponse, error)
+	// GetStatus returns the configuration and tracked state of a project.
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*ProjectStatus, error)
+	// ListProjects returns every configured project with its tracked state.
+	ListProjects(ctx context.Context, in *ListProjectsRequest, opts ...grpc.CallOption) (*ListProjectsResponse, error)
+	// WatchEvents streams received, resolved, and dispatched actions, state
+	// changes, and alerts as they happen.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type tioaoaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTioaoaClient(cc grpc.ClientConnInterface) TioaoaClient {
+	return &tioaoaClient{cc}
+}
+
+func (c *tioaoaClient) SubmitAction(ctx context.Context, in *SubmitActionRequest, opts ...grpc.CallOption) (*SubmitActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitActionResponse)
+	err := c.cc.Invoke(ctx, Tioaoa_SubmitAction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tioaoaClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*ProjectStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProjectStatus)
+	err := c.cc.Invoke(ctx, Tioaoa_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tioaoaClient) ListProjects(ctx context.Context, in *ListProjectsRequest, opts ...grpc.CallOption) (*ListProjectsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProjectsResponse)
+	err := c.cc.Invoke(ctx, Tioaoa_ListProjects_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tioaoaClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Tioaoa_ServiceDesc.Streams[0], Tioaoa_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tioaoa_WatchEventsClient = grpc.ServerStreamingClient[Event]
+
+// TioaoaServer is the server API for Tioaoa service.
+// All implementations must embed UnimplementedTioaoaServer
+// for forward compatibility.
+//
+// Tioaoa submits lifecycle actions for projects and reports their state.
+type TioaoaServer interface {
+	// SubmitAction dispatches an action, or schedules it when it has a delay
+	// or a dispatch time.
+	SubmitAction(context.Context, *SubmitActionRequest) (*SubmitActionResponse, error)
+	// GetStatus returns the configuration and tracked state of a project.
+	GetStatus(context.Context, *GetStatusRequest) (*ProjectStatus, error)
+	// ListProjects returns every configured project with its tracked state.
+	ListProjects(context.Context, *ListProjectsRequest) (*ListProjectsResponse, error)
+	// WatchEvents streams received, resolved, and dispatched actions, state
+	// changes, and alerts as they happen.
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedTioaoaServer()
+}
+
+// UnimplementedTioaoaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTioaoaServer struct{}
+
+func (UnimplementedTioaoaServer) SubmitAction(context.Context, *SubmitActionRequest) (*SubmitActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitAction not implemented")
+}
+func (UnimplementedTioaoaServer) GetStatus(context.Context, *GetStatusRequest) (*ProjectStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedTioaoaServer) ListProjects(context.Context, *ListProjectsRequest) (*ListProjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProjects not implemented")
+}
+func (UnimplementedTioaoaServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedTioaoaServer) mustEmbedUnimplementedTioaoaServer() {}
+func (UnimplementedTioaoaServer) testEmbeddedByValue()                {}
+
+// UnsafeTioaoaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TioaoaServer will
+// result in compilation errors.
+type UnsafeTioaoaServer interface {
+	mustEmbedUnimplementedTioaoaServer()
+}
+
+func RegisterTioaoaServer(s grpc.ServiceRegistrar, srv TioaoaServer) {
+	// If the following call pancis, it indicates UnimplementedTioaoaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Tioaoa_ServiceDesc, srv)
+}
+
+func _Tioaoa_SubmitAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TioaoaServer).SubmitAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tioaoa_SubmitAction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TioaoaServer).SubmitAction(ctx, req.(*SubmitActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tioaoa_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TioaoaServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tioaoa_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TioaoaServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tioaoa_ListProjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TioaoaServer).ListProjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tioaoa_ListProjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TioaoaServer).ListProjects(ctx, req.(*ListProjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tioaoa_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TioaoaServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tioaoa_WatchEventsServer = grpc.ServerStreamingServer[Event]
+
+// Tioaoa_ServiceDesc is the grpc.ServiceDesc for Tioaoa service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Tioaoa_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tioaoa.v1.Tioaoa",
+	HandlerType: (*TioaoaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitAction",
+			Handler:    _Tioaoa_SubmitAction_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Tioaoa_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListProjects",
+			Handler:    _Tioaoa_ListProjects_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Tioaoa_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tioaoa.proto",
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+var errInvalidDesiredState = errors.New("invalid desired state")
+
+// ApplyResult lists what applying a desired-state document changed
+type ApplyResult = openapi.ApplyResult
+
+// satisfiesDesired reports whether a tracked state already is, or is on its
+// way to, the desired state
+func satisfiesDesired(state, desired string) bool {
+	switch desired {
+	case stateUp:
+		return state == stateUp || state == stateStarting
+	case stateDown:
+		return state == stateDown || state == stateStopping
+	}
+	return true
+}
+
+// applyDesiredState diffs a document mapping repos to "up" or "down" against
+// the tracked state and dispatches only the actions needed to converge.
+// Projects are stopped dependents first and started in dependency order.
+func applyDesiredState(ctx context.Context, rdb *redis.Client, doc map[string]string, correlationID string) (ApplyResult, error) {
+	result := ApplyResult{Up: []string{}, Down: []string{}, Unchanged: []string{}, CorrelationID: correlationID}
+
+	var errs []error
+	var ups, downs []Project
+	for _, name := range slices.Sorted(maps.Keys(doc)) {
+		desired := doc[name]
+		project, ok := lookupProject(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("project %s not found", name))
+			continue
+		}
+		if desired != stateUp && desired != stateDown {
+			errs = append(errs, fmt.Errorf("project %s: invalid state %q (expected %s or %s)", name, desired, stateUp, stateDown))
+			continue
+		}
+		switch {
+		case satisfiesDesired(getProjectState(project.Repo).State, desired):
+			result.Unchanged = append(result.Unchanged, project.Repo)
+		case desired == stateUp:
+			ups = append(ups, project)
+		default:
+			downs = append(downs, project)
+		}
+	}
+	if len(errs) > 0 {
+		return result, fmt.Errorf("%w: %w", errInvalidDesiredState, errors.Join(errs...))
+	}
+
+	msg := RedisMessage{CorrelationID: correlationID}
+	// The document is authoritative, so dependents it keeps up are not
+	// protected from a down
+	msg.Force = true
+
+	tiers := dependencyTiers(downs, "down")
+	slices.Reverse(tiers)
+	for _, tier := range tiers {
+		for _, project := range tier {
+			if err := dispatchAction(ctx, rdb, msg, project, "down"); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result.Down = append(result.Down, project.Repo)
+		}
+	}
+
+	if len(ups) > 0 {
+		tiers := dependencyTiers(ups, "up")
+		if err := dispatchTiers(ctx, rdb, msg, tiers); err != nil {
+			errs = append(errs, err)
+		}
+		for _, tier := range tiers {
+			for _, project := range tier {
+				result.Up = append(result.Up, project.Repo)
+			}
+		}
+	}
+
+	sort.Strings(result.Up)
+	sort.Strings(result.Down)
+	return result, errors.Join(errs...)
+}
+
+// handleApplyState handles PUT /state with a document such as
+// {"its-the-vibe/InnerGate": "up", "its-the-vibe/OctoCatalog": "down"}
+func handleApplyState(w http.ResponseWriter, r *http.Request) {
+	if !requireLeader(w, r) {
+		return
+	}
+
+	var doc map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc)) {
+		if project, ok := lookupProject(name); ok && (doc[name] == stateUp || doc[name] == stateDown) {
+			if err := authorizeProjects(contextIdentity(r.Context()), doc[name], []Project{project}); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	correlationID := newCorrelationID()
+	w.Header().Set("X-Correlation-ID", correlationID)
+
+	result, err := applyDesiredState(r.Context(), redisClient, doc, correlationID)
+	if err != nil {
+		log.Printf("[%s] Error applying desired state: %v", correlationID, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidDesiredState) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf("Failed to apply desired state: %v", err), status)
+		return
+	}
+	log.Printf("[%s] Applied desired state: %d up, %d down, %d unchanged", correlationID, len(result.Up), len(result.Down), len(result.Unchanged))
+
+	writeJSON(w, http.StatusOK, result)
+}
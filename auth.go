@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// apiKey is a bearer token accepted by the HTTP API. The name identifies the
+// caller in logs and the action history.
+type apiKey struct {
+	Name string
+	Key  string
+}
+
+var (
+	apiKeysMu sync.RWMutex
+	apiKeys   []apiKey
+)
+
+// ownAuthPaths are endpoints that verify their callers themselves, so they
+// are exempt from API keys
+var ownAuthPaths = map[string]bool{
+	"/slack/commands":        true,
+	"/webhooks/alertmanager": true,
+}
+
+type identityContextKey struct{}
+
+// loadAPIKeys reads name:key pairs from API_KEYS and API_KEYS_FILE. The
+// current keys are kept if either cannot be parsed.
+func loadAPIKeys() error {
+	var keys []apiKey
+	seen := make(map[string]bool)
+	add := func(entry, where string) error {
+		name, key, ok := strings.Cut(entry, ":")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			return fmt.Errorf("invalid API key in %s: expected name:key", where)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate API key name %q in %s", name, where)
+		}
+		seen[name] = true
+		keys = append(keys, apiKey{Name: name, Key: key})
+		return nil
+	}
+
+	for _, entry := range strings.Split(getEnv("API_KEYS", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if err := add(entry, "API_KEYS"); err != nil {
+			return err
+		}
+	}
+
+	if path := getEnv("API_KEYS_FILE", ""); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open API keys file: %w", err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := add(line, fmt.Sprintf("%s line %d", path, lineNum)); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read API keys file: %w", err)
+		}
+	}
+
+	for _, k := range keys {
+		registerSecret(k.Key)
+	}
+	apiKeysMu.Lock()
+	apiKeys = keys
+	apiKeysMu.Unlock()
+	return nil
+}
+
+func getAPIKeys() []apiKey {
+	apiKeysMu.RLock()
+	defer apiKeysMu.RUnlock()
+	return apiKeys
+}
+
+// authorizeRequest identifies the caller by a verified client certificate, or
+// else checks the request's bearer token against the API keys, then as an OIDC
+// token. It returns the identity of the caller: the certificate's common name,
+// the name of the matching key, or the identity claim of the token. Every
+// request is authorized, with no identity, when neither keys nor OIDC are
+// configured or it arrived on the unix socket, whose file permissions control
+// access instead.
+func authorizeRequest(r *http.Request) (string, error) {
+	if identity := clientCertIdentity(r); identity != "" {
+		return identity, nil
+	}
+	if viaUnixSocket(r) {
+		return "", nil
+	}
+	return authorizeBearer(r.Context(), r.Header.Get("Authorization"))
+}
+
+// authorizeBearer checks a "Bearer" authorization value against the API keys
+// and OIDC tokens and returns the caller's identity. It returns no identity
+// and no error when neither is configured.
+func authorizeBearer(ctx context.Context, authorization string) (string, error) {
+	keys := getAPIKeys()
+	if len(keys) == 0 && oidcVerifier == nil {
+		return "", nil
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return "", errors.New("missing bearer token")
+	}
+	name := ""
+	for _, k := range keys {
+		// Compare against every key so the time taken does not reveal which matched
+		if subtle.ConstantTimeCompare([]byte(token), []byte(k.Key)) == 1 {
+			name = k.Name
+		}
+	}
+	if name != "" {
+		return name, nil
+	}
+	if oidcVerifier == nil {
+		return "", errors.New("invalid API key")
+	}
+	identity, err := verifyOIDCToken(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	return identity, nil
+}
+
+// needsAuth reports whether the request can change anything and so must be
+// authorized. Signed webhook requests have been checked already.
+func needsAuth(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !ownAuthPaths[r.URL.Path] && !signedRoute(r)
+}
+
+// requireAuth rejects mutating requests without a valid API key or token and
+// logs who made each one
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, err := authorizeRequest(r)
+		if needsAuth(r) {
+			if err != nil {
+				log.Printf("[%s] Rejected %s %s from %s: %v", requestID(r.Context()), r.Method, r.URL.Path, r.RemoteAddr, err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tioaoa"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if name != "" {
+				log.Printf("[%s] %s %s by %s", requestID(r.Context()), r.Method, r.URL.Path, name)
+			}
+		}
+		if name != "" {
+			if info := getRequestInfo(r.Context()); info != nil {
+				info.Identity = name
+			}
+			r = r.WithContext(context.WithValue(r.Context(), identityContextKey{}, name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestSource names where a request came from for the action history,
+// adding the identity of the caller that authorized it
+func requestSource(r *http.Request, source string) string {
+	if name, _ := r.Context().Value(identityContextKey{}).(string); name != "" {
+		return source + ":" + name
+	}
+	return source
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// autoRestartBackoff returns how long to wait after the given number of
+// automatic restarts before the next one, doubling each time
+func (hc HealthCheck) autoRestartBackoff(restarts int) time.Duration {
+	backoff := autoRestartBackoff
+	if d, err := time.ParseDuration(hc.RestartBackoff); err == nil && d > 0 {
+		backoff = d
+	}
+	for i := 1; i < restarts; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// maxAutoRestarts returns how many automatic restarts are attempted before
+// giving up until the project is healthy again
+func (hc HealthCheck) maxAutoRestarts() int {
+	if hc.MaxRestarts > 0 {
+		return hc.MaxRestarts
+	}
+	return autoRestartRetries
+}
+
+// maybeAutoRestart restarts a project whose health check has failed
+// restartAfter consecutive times. Restarts back off exponentially and stop
+// after maxRestarts until the project is healthy again. Only projects that
+// should be running are restarted: those that are up, or failed other than
+// on the way down, but not those brought down on purpose or for being idle.
+func maybeAutoRestart(ctx context.Context, rdb *redis.Client, p Project, s ProjectState) {
+	hc := p.HealthCheck
+	if hc == nil || hc.RestartAfter <= 0 || s.HealthFailures < hc.RestartAfter {
+		return
+	}
+	if s.State != stateUp && (s.State != stateFailed || s.LastAction == "down") {
+		return
+	}
+	if s.AutoRestarts >= hc.maxAutoRestarts() {
+		if !s.AutoRestartGaveUp {
+			log.Printf("Not restarting %s: gave up after %d automatic restarts", p.Repo, s.AutoRestarts)
+			noteAutoRestartGaveUp(p.Repo)
+		}
+		return
+	}
+	if isFlapping(p.Repo) {
+		return
+	}
+	if s.AutoRestarts > 0 && time.Since(s.LastAutoRestartAt) < hc.autoRestartBackoff(s.AutoRestarts) {
+		return
+	}
+
+	actions := []string{"restart"}
+	if !p.canRestart() {
+		actions = []string{"down", "up"}
+	}
+	attempt := noteAutoRestart(p.Repo)
+	correlationID := "autorestart-" + newCorrelationID()
+	log.Printf("[%s] Automatically restarting %s after %d failed health checks (attempt %d of %d)", correlationID, p.Repo, s.HealthFailures, attempt, hc.maxAutoRestarts())
+	if len(actions) > 1 {
+		// Count down followed by up as a restart for flap detection
+		recordRestart(p.Repo)
+	}
+	for _, action := range actions {
+		msg := RedisMessage{Action: action, Repo: Target(p.Repo), CorrelationID: correlationID}
+		// Dependents only see a brief outage, as with restartCommands
+		msg.Force = true
+		message, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("Error restarting %s: %v", p.Repo, err)
+			return
+		}
+		if err := processMessage(ctx, rdb, string(message)); err != nil {
+			log.Printf("Error restarting %s: %v", p.Repo, err)
+			return
+		}
+	}
+}
+
+// noteAutoRestart records an automatic restart of repo, returning how many
+// have been attempted since it was last healthy
+func noteAutoRestart(repo string) int {
+	stateMu.Lock()
+	s := projectStates[repo]
+	s.Repo = repo
+	if s.State == "" {
+		s.State = stateUnknown
+	}
+	s.AutoRestarts++
+	s.LastAutoRestartAt = time.Now().UTC()
+	projectStates[repo] = s
+	stateMu.Unlock()
+
+	persistState(s)
+	return s.AutoRestarts
+}
+
+// noteAutoRestartGaveUp records that no more automatic restarts of repo will
+// be attempted, so the fact is only logged once
+func noteAutoRestartGaveUp(repo string) {
+	stateMu.Lock()
+	s := projectStates[repo]
+	s.AutoRestartGaveUp = true
+	projectStates[repo] = s
+	stateMu.Unlock()
+
+	persistState(s)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+var errNestedBatch = errors.New("nested batches are not supported")
+
+// Modes of POST /messages/batch
+const (
+	batchModeBestEffort = "best-effort"
+	batchModeFailFast   = "fail-fast"
+)
+
+// BatchItemResult reports the outcome of one message in a batch
+type BatchItemResult = openapi.BatchItemResult
+
+// isBatch reports whether a raw message is a JSON array of messages
+func isBatch(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// processBatch processes every message in a JSON array independently and
+// returns one result per item, in order. With failFast, the items after the
+// first one that fails are skipped.
+func processBatch(ctx context.Context, rdb *redis.Client, data []byte, failFast bool) ([]BatchItemResult, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse message batch: %w", err)
+	}
+
+	results := make([]BatchItemResult, len(items))
+	firstFailed := -1
+	for i, item := range items {
+		if failFast && firstFailed >= 0 {
+			results[i] = BatchItemResult{Index: i, Status: openapi.BatchItemResultStatusSkipped, Error: fmt.Sprintf("skipped after item %d failed", firstFailed)}
+			continue
+		}
+		results[i] = BatchItemResult{Index: i, Status: openapi.BatchItemResultStatusSuccess}
+		err := errNestedBatch
+		if !isBatch(item) {
+			item, results[i].CorrelationID = withCorrelationID(item)
+			err = processMessage(ctx, rdb, string(item))
+		}
+		if err != nil {
+			results[i].Status = openapi.BatchItemResultStatusError
+			results[i].Error = err.Error()
+			if firstFailed < 0 {
+				firstFailed = i
+			}
+		}
+	}
+	return results, nil
+}
+
+// withCorrelationID assigns a correlation ID to a batch item that does not
+// carry one, so the item can be traced from the per-item results. Items that
+// cannot be parsed are returned unchanged.
+func withCorrelationID(item json.RawMessage) (json.RawMessage, string) {
+	msg, err := decodeMessage(item)
+	if err != nil {
+		return item, ""
+	}
+	if msg.CorrelationID != "" {
+		return item, msg.CorrelationID
+	}
+	msg.CorrelationID = newCorrelationID()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return item, ""
+	}
+	return data, msg.CorrelationID
+}
+
+// withOnBehalfOf records identity as OnBehalfOf on every item of a batch, so
+// that each is held to the identity's permissions when the batch is processed
+// later. Items that cannot be decoded are left as they are and fail then.
+func withOnBehalfOf(data []byte, identity string) []byte {
+	var items []json.RawMessage
+	if identity == "" || json.Unmarshal(data, &items) != nil {
+		return data
+	}
+	for i, item := range items {
+		msg, err := decodeMessage(item)
+		if err != nil || isBatch(item) {
+			continue
+		}
+		msg.OnBehalfOf = identity
+		if encoded, err := json.Marshal(msg); err == nil {
+			items[i] = encoded
+		}
+	}
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return data
+	}
+	return encoded
+}
+
+// processBatchMessage processes a batch received from the Redis list and logs
+// the per-item results
+func processBatchMessage(ctx context.Context, rdb *redis.Client, message string) error {
+	results, err := processBatch(ctx, rdb, []byte(message), false)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, result := range results {
+		if result.Status != openapi.BatchItemResultStatusSuccess {
+			errs = append(errs, fmt.Errorf("item %d: %s", result.Index, result.Error))
+		}
+	}
+	log.Printf("Processed batch of %d messages (%d failed)", len(results), len(errs))
+	return errors.Join(errs...)
+}
+
+// handleBatchMessages processes a batch posted to /messages or
+// /messages/batch and reports the result of each item. The response is 200 if
+// every item succeeded and 207 Multi-Status otherwise.
+func handleBatchMessages(w http.ResponseWriter, r *http.Request, body []byte, failFast bool) {
+	results, err := processBatch(withSource(context.WithoutCancel(r.Context()), requestSource(r, "http")), redisClient, body, failFast)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status != "success" {
+			failed++
+		}
+	}
+
+	status, summary := http.StatusOK, openapi.BatchResponseStatusSuccess
+	if failed > 0 {
+		status, summary = http.StatusMultiStatus, openapi.BatchResponseStatusPartial
+		if failed == len(results) {
+			summary = openapi.BatchResponseStatusError
+		}
+	}
+	log.Printf("Processed batch of %d messages (%d failed)", len(results), failed)
+
+	writeJSON(w, status, openapi.BatchResponse{Status: summary, Results: results})
+}
+
+// handlePostBatch handles POST /messages/batch with a JSON array of messages.
+// Every item is processed even if earlier ones fail, unless ?mode=fail-fast
+// asks to stop at the first failure.
+func handlePostBatch(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "", batchModeBestEffort, batchModeFailFast:
+	default:
+		http.Error(w, fmt.Sprintf("Invalid mode %q: expected %s or %s", mode, batchModeBestEffort, batchModeFailFast), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !isBatch(body) {
+		http.Error(w, "Body must be a JSON array of messages", http.StatusBadRequest)
+		return
+	}
+
+	// Items queued for the leader are processed independently, so stopping at
+	// a failure needs the leader
+	if !isLeader() {
+		if mode == batchModeFailFast {
+			requireLeader(w, r)
+			return
+		}
+		handleQueueForLeader(w, r, body)
+		return
+	}
+	handleBatchMessages(w, r, body, mode == batchModeFailFast)
+}
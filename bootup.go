@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// runBootUp brings up every project with bootUp set once BOOT_UP_DELAY has
+// passed after the service starts, e.g. after a host reboot
+func runBootUp(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(bootUpDelay):
+		}
+		bootUp(ctx, rdb)
+	}()
+}
+
+func bootUp(ctx context.Context, rdb *redis.Client) {
+	var projects []Project
+	for _, p := range allProjects() {
+		if p.BootUp {
+			projects = append(projects, p)
+		}
+	}
+	if len(projects) == 0 {
+		return
+	}
+	if !isLeader() {
+		log.Printf("Not bringing up %d boot projects: this instance is not the leader", len(projects))
+		return
+	}
+
+	// The tracked state may predate a reboot, so every boot project is
+	// dispatched whatever its state
+	msg := RedisMessage{CorrelationID: "boot-" + newCorrelationID()}
+	tiers := dependencyTiers(projects, "up")
+	log.Printf("[%s] Bringing up %s on startup", msg.CorrelationID, tierRepos(tiers))
+	if err := dispatchTiers(ctx, rdb, msg, tiers); err != nil {
+		log.Printf("[%s] Error bringing up boot projects: %v", msg.CorrelationID, err)
+	}
+}
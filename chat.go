@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// chatCommand is the outcome of a command typed in a chat integration, such
+// as "restart InnerGate"
+type chatCommand struct {
+	Action      string
+	Target      string
+	Repos       []string
+	TargetQueue string
+	Result      submitResult
+	// Status is set instead of Result for status queries
+	Status *StatusReply
+}
+
+// runChatCommand parses and submits a command typed in chat on behalf of
+// identity, if any. Targets may be given by the repo name alone, e.g.
+// InnerGate for its-the-vibe/InnerGate.
+func runChatCommand(text, source, identity string) (chatCommand, error) {
+	msg, err := parsePlainMessage(text)
+	if err != nil {
+		return chatCommand{}, err
+	}
+	if msg.Status != "" {
+		reply := buildStatusReply(chatTarget(msg.Status), "")
+		return chatCommand{Action: "status", Target: reply.Repo, Status: &reply}, nil
+	}
+	if msg.Cancel != "" {
+		return chatCommand{}, errors.New("cancelling scheduled jobs is not supported from chat")
+	}
+
+	action, target, _ := msg.actionTarget()
+	target = chatTarget(target)
+	if msg, err = parsePlainMessage(action + " " + target); err != nil {
+		return chatCommand{}, err
+	}
+	cmd := chatCommand{Action: action, Target: target}
+
+	targets, err := resolveTargets(target, action)
+	if err != nil {
+		return cmd, err
+	}
+	if len(targets) == 0 {
+		return cmd, fmt.Errorf("no project matches %s", target)
+	}
+	cmd.Repos = projectRepos(targets)
+	var queues []string
+	for _, p := range targets {
+		queue := p.TargetQueue
+		if queue == "" {
+			queue = getDefaultTargetQueue()
+		}
+		if !slices.Contains(queues, queue) {
+			queues = append(queues, queue)
+		}
+	}
+	cmd.TargetQueue = strings.Join(queues, ", ")
+
+	if err := authorizeMessage(identity, msg); err != nil {
+		return cmd, err
+	}
+	ctx := context.Background()
+	if identity != "" {
+		ctx = context.WithValue(ctx, identityContextKey{}, identity)
+	}
+	cmd.Result, err = submitMessage(ctx, redisClient, msg, source)
+	return cmd, err
+}
+
+// chatTarget expands a bare repo name to the one project whose repo ends in
+// it, leaving repos, aliases, patterns, and ambiguous names unchanged
+func chatTarget(target string) string {
+	if strings.Contains(target, "/") || strings.Contains(target, ":") || isGlob(target) || target == allTarget {
+		return target
+	}
+	if _, exists := lookupProject(target); exists {
+		return target
+	}
+
+	match := ""
+	for _, p := range allProjects() {
+		if strings.EqualFold(path.Base(p.Repo), target) {
+			if match != "" {
+				return target
+			}
+			match = p.Repo
+		}
+	}
+	if match == "" {
+		return target
+	}
+	return match
+}
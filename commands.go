@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// CommandData is the data available to Go templates in command strings
+type CommandData struct {
+	Repo        string
+	RepoShort   string
+	Owner       string
+	Dir         string
+	Branch      string
+	Action      string
+	TargetQueue string
+	Env         map[string]string
+	// Fields taken from the message that requested the action. They are set
+	// by the sender, so commands should pass them through quote.
+	CorrelationID   string
+	RequestID       string
+	RequestedBranch string
+	Identity        string
+}
+
+// newCommandData builds the template data for a project and action
+func newCommandData(project Project, action, branch, targetQueue string, msg RedisMessage, identity string) CommandData {
+	owner, short, found := strings.Cut(project.Repo, "/")
+	if !found {
+		owner, short = "", project.Repo
+	}
+	return CommandData{
+		Repo:        project.Repo,
+		RepoShort:   short,
+		Owner:       owner,
+		Dir:         project.Dir,
+		Branch:      branch,
+		Action:      action,
+		TargetQueue: targetQueue,
+		Env:         project.Env,
+
+		CorrelationID:   msg.CorrelationID,
+		RequestID:       msg.RequestID,
+		RequestedBranch: msg.Branch,
+		Identity:        identity,
+	}
+}
+
+// commandFuncs are the functions available to command templates
+var commandFuncs = template.FuncMap{
+	"quote": shellQuote,
+}
+
+// renderCommands expands Go template syntax in each command. Commands without
+// template actions are returned unchanged.
+func renderCommands(commands []string, data CommandData) ([]string, error) {
+	rendered := make([]string, len(commands))
+	for i, command := range commands {
+		if !strings.Contains(command, "{{") {
+			rendered[i] = command
+			continue
+		}
+		tmpl, err := parseCommandTemplate(command)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			return nil, fmt.Errorf("failed to render command %q: %w", command, err)
+		}
+		rendered[i] = sb.String()
+	}
+	return rendered, nil
+}
+
+func parseCommandTemplate(command string) (*template.Template, error) {
+	tmpl, err := template.New("command").Funcs(commandFuncs).Option("missingkey=error").Parse(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command template %q: %w", command, err)
+	}
+	return tmpl, nil
+}
+
+// validateCommandTemplates checks that every templated command parses and
+// renders against sample data, so mistakes surface at load time
+func validateCommandTemplates(p Project) []error {
+	var errs []error
+	sample := newCommandData(p, "up", "refs/heads/main", p.TargetQueue, RedisMessage{}, "")
+	all := [][]string{p.UpCommands, p.DownCommands, p.RestartCommands}
+	for _, name := range slices.Sorted(maps.Keys(p.Actions)) {
+		all = append(all, p.Actions[name])
+	}
+	for _, commands := range all {
+		if _, err := renderCommands(commands, sample); err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.Repo, err))
+		}
+	}
+	return errs
+}
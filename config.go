@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// Config represents the overall configuration
+type Config struct {
+	Templates map[string]Project `json:"templates,omitempty" yaml:"templates,omitempty" toml:"templates,omitempty"`
+	Projects  []Project          `json:"projects" yaml:"projects" toml:"projects"`
+	// Queues customises the notifications pushed onto target queues, keyed
+	// by queue name
+	Queues map[string]QueueConfig `json:"queues,omitempty" yaml:"queues,omitempty" toml:"queues,omitempty"`
+}
+
+// Project represents a single project configuration
+type Project struct {
+	Repo            string            `json:"repo" yaml:"repo" toml:"repo"`
+	Dir             string            `json:"dir" yaml:"dir" toml:"dir"`
+	UpDir           string            `json:"upDir,omitempty" yaml:"upDir,omitempty" toml:"upDir,omitempty"`
+	DownDir         string            `json:"downDir,omitempty" yaml:"downDir,omitempty" toml:"downDir,omitempty"`
+	RestartDir      string            `json:"restartDir,omitempty" yaml:"restartDir,omitempty" toml:"restartDir,omitempty"`
+	UpCommands      []string          `json:"upCommands" yaml:"upCommands,omitempty" toml:"upCommands,omitempty"`
+	DownCommands    []string          `json:"downCommands" yaml:"downCommands,omitempty" toml:"downCommands,omitempty"`
+	RestartCommands []string          `json:"restartCommands,omitempty" yaml:"restartCommands,omitempty" toml:"restartCommands,omitempty"`
+	TargetQueue     string            `json:"targetQueue,omitempty" yaml:"targetQueue,omitempty" toml:"targetQueue,omitempty"`
+	Group           string            `json:"group,omitempty" yaml:"group,omitempty" toml:"group,omitempty"`
+	GroupOrder      int               `json:"groupOrder,omitempty" yaml:"groupOrder,omitempty" toml:"groupOrder,omitempty"`
+	Aliases         []string          `json:"aliases,omitempty" yaml:"aliases,omitempty" toml:"aliases,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty" yaml:"labels,omitempty" toml:"labels,omitempty"`
+	Extends         string            `json:"extends,omitempty" yaml:"extends,omitempty" toml:"extends,omitempty"`
+	Env             map[string]string `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
+	// Actions defines custom actions beyond up/down/restart, keyed by name
+	Actions map[string][]string `json:"actions,omitempty" yaml:"actions,omitempty" toml:"actions,omitempty"`
+	// Cooldown holds back repeats of an action for this long after it is
+	// dispatched; CooldownMode rejects or defers them
+	Cooldown     string `json:"cooldown,omitempty" yaml:"cooldown,omitempty" toml:"cooldown,omitempty"`
+	CooldownMode string `json:"cooldownMode,omitempty" yaml:"cooldownMode,omitempty" toml:"cooldownMode,omitempty"`
+	// DependsOn lists the repos (or aliases) that must be up before this project
+	DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty" toml:"dependsOn,omitempty"`
+	// BootUp brings the project up when the service starts
+	BootUp bool `json:"bootUp,omitempty" yaml:"bootUp,omitempty" toml:"bootUp,omitempty"`
+	// DownOnShutdown stops the project when the service receives SIGTERM
+	// with SHUTDOWN_DOWN=flagged
+	DownOnShutdown bool `json:"downOnShutdown,omitempty" yaml:"downOnShutdown,omitempty" toml:"downOnShutdown,omitempty"`
+	// DesiredState is the state (up or down) the reconciler keeps the project in
+	DesiredState string `json:"desiredState,omitempty" yaml:"desiredState,omitempty" toml:"desiredState,omitempty"`
+	// HealthCheck probes whether the project's service is healthy
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty" yaml:"healthCheck,omitempty" toml:"healthCheck,omitempty"`
+	// IdleTimeout downs the project after this long without activity
+	IdleTimeout string          `json:"idleTimeout,omitempty" yaml:"idleTimeout,omitempty" toml:"idleTimeout,omitempty"`
+	Activity    *ActivitySignal `json:"activity,omitempty" yaml:"activity,omitempty" toml:"activity,omitempty"`
+	// Schedules run actions on recurring cron schedules
+	Schedules []Schedule `json:"schedules,omitempty" yaml:"schedules,omitempty" toml:"schedules,omitempty"`
+	// AlertRules run actions when matching Alertmanager alerts fire
+	AlertRules []AlertRule `json:"alertRules,omitempty" yaml:"alertRules,omitempty" toml:"alertRules,omitempty"`
+	// Executor carries out the project's actions: poppit (the default),
+	// webhook, local, ssh, docker, or kubernetes, which are configured by the
+	// fields below
+	Executor   string              `json:"executor,omitempty" yaml:"executor,omitempty" toml:"executor,omitempty"`
+	Webhook    *WebhookExecutor    `json:"webhook,omitempty" yaml:"webhook,omitempty" toml:"webhook,omitempty"`
+	Local      *LocalExecutor      `json:"local,omitempty" yaml:"local,omitempty" toml:"local,omitempty"`
+	SSH        *SSHExecutor        `json:"ssh,omitempty" yaml:"ssh,omitempty" toml:"ssh,omitempty"`
+	Docker     *DockerExecutor     `json:"docker,omitempty" yaml:"docker,omitempty" toml:"docker,omitempty"`
+	Kubernetes *KubernetesExecutor `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty" toml:"kubernetes,omitempty"`
+}
+
+// configReloadDebounce is how long the watcher waits for further file events
+// before reloading, so that editors writing in several steps trigger one reload.
+const configReloadDebounce = 500 * time.Millisecond
+
+var (
+	projectsMu sync.RWMutex
+	// aliases maps lower-cased project aliases to repository identifiers
+	aliases map[string]string
+	// templates holds the templates of the active configuration so projects
+	// added at runtime can extend them too
+	templates map[string]Project
+	// rawConfig is the active configuration as written, before discovery,
+	// templates, interpolation, and secrets are applied
+	rawConfig Config
+	// notificationFormats holds the formats of target queues with custom
+	// notifications
+	notificationFormats map[string]*notificationFormat
+)
+
+// loadConfig loads the project configuration from the configured source and
+// replaces the active configuration only if loading succeeds
+func loadConfig() error {
+	config, err := readSourceConfig()
+	if err != nil {
+		return err
+	}
+	return applyConfig(config, "load")
+}
+
+// readSourceConfig reads the raw configuration from the configured source
+func readSourceConfig() (Config, error) {
+	var config Config
+	var err error
+	switch configSource {
+	case "redis":
+		config.Projects, err = loadProjectsFromRedis(context.Background())
+	case "consul":
+		config.Projects, err = loadProjectsFromConsul(context.Background())
+	case "etcd":
+		config.Projects, err = loadProjectsFromEtcd(context.Background())
+	default:
+		if dir := getConfigDir(); dir != "" {
+			config, err = readConfigDir(dir)
+		} else if location := getConfigFile(); isRemoteConfig(location) {
+			config, _, err = fetchRemoteConfig(context.Background(), location)
+		} else {
+			config, err = readConfigFile(location)
+		}
+	}
+	return config, err
+}
+
+// applyConfig builds the given raw configuration and, if it is valid, makes it
+// the active configuration and records it as a new revision
+func applyConfig(config Config, reason string) error {
+	built := config
+	if discoveryRoot != "" {
+		discovered, err := discoverProjects(discoveryRoot)
+		if err != nil {
+			return err
+		}
+		built = mergeDiscoveredProjects(config, discovered)
+	}
+
+	loaded, errs := buildProjects(built)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	formats, _ := buildNotificationFormats(config.Queues)
+
+	// Swap the whole map so readers never observe a partially loaded config
+	projectsMu.Lock()
+	projects = loaded
+	aliases = buildAliases(loaded)
+	templates = config.Templates
+	rawConfig = config
+	notificationFormats = formats
+	projectsMu.Unlock()
+
+	log.Printf("Loaded %d project configurations", len(loaded))
+	recordConfigRevision(config, reason)
+	return nil
+}
+
+// buildProjects applies templates, interpolates, resolves secrets in, and
+// validates the configured projects, and builds a map for quick lookups.
+// All problems found are returned, not just the first.
+func buildProjects(config Config) (map[string]Project, []error) {
+	resolved, errs := applyTemplates(config)
+	for i, p := range resolved {
+		p, err := interpolateProject(p)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		p, err = resolveProjectSecrets(p)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		resolved[i] = p
+	}
+	errs = append(errs, validateProjects(resolved)...)
+	_, formatErrs := buildNotificationFormats(config.Queues)
+	errs = append(errs, formatErrs...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	loaded := make(map[string]Project, len(resolved))
+	for _, p := range resolved {
+		loaded[p.Repo] = p
+	}
+	return loaded, nil
+}
+
+// buildAliases maps every alias to its project's repository. Conflicts are
+// rejected by validateProjects before this is called.
+func buildAliases(loaded map[string]Project) map[string]string {
+	index := make(map[string]string)
+	for repo, p := range loaded {
+		for _, alias := range p.Aliases {
+			index[strings.ToLower(alias)] = repo
+		}
+	}
+	return index
+}
+
+// readConfigFile reads and parses a project configuration file. The format is
+// chosen from the file extension: .yaml/.yml, .toml, or JSON otherwise.
+func readConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config, err := parseConfig(data, filepath.Ext(path))
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return config, nil
+}
+
+// readConfigDir reads every configuration file in dir, in lexical order, and
+// merges their projects, templates, and queues into a single configuration. Hidden
+// files, subdirectories, and files with unsupported extensions are skipped.
+func readConfigDir(dir string) (Config, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	config := Config{Templates: make(map[string]Project), Queues: make(map[string]QueueConfig)}
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFileName(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		fileConfig, err := readConfigFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+		for name, template := range fileConfig.Templates {
+			if _, exists := config.Templates[name]; exists {
+				return Config{}, fmt.Errorf("%s: template %s is defined in more than one file", path, name)
+			}
+			config.Templates[name] = template
+		}
+		for name, queue := range fileConfig.Queues {
+			if _, exists := config.Queues[name]; exists {
+				return Config{}, fmt.Errorf("%s: queue %s is defined in more than one file", path, name)
+			}
+			config.Queues[name] = queue
+		}
+		config.Projects = append(config.Projects, fileConfig.Projects...)
+	}
+	return config, nil
+}
+
+// isConfigFileName reports whether name looks like a configuration file that
+// should be loaded from a config directory
+func isConfigFileName(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// parseConfig decodes configuration data in the format implied by ext.
+// JSON and YAML accept either a top-level list of projects or an object with
+// a "projects" key; TOML requires the latter ([[projects]] tables). A document
+// without a "projects" key that describes a single project (has a "repo" key)
+// is also accepted, which allows one file per project in a config directory.
+func parseConfig(data []byte, ext string) (Config, error) {
+	config, err := decodeConfig(data, ext)
+	if err != nil || len(config.Projects) > 0 {
+		return config, err
+	}
+
+	var single Project
+	if err := decodeDocument(data, ext, &single); err == nil && single.Repo != "" {
+		config.Projects = []Project{single}
+	}
+	return config, nil
+}
+
+// decodeDocument decodes a single object document in the format implied by ext
+func decodeDocument(data []byte, ext string, v any) error {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, v)
+	case ".toml":
+		return toml.Unmarshal(data, v)
+	default:
+		return json.Unmarshal(data, v)
+	}
+}
+
+func decodeConfig(data []byte, ext string) (Config, error) {
+	var config Config
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return config, err
+		}
+		if len(root.Content) > 0 && root.Content[0].Kind == yaml.SequenceNode {
+			err := root.Decode(&config.Projects)
+			return config, err
+		}
+		err := root.Decode(&config)
+		return config, err
+	case ".toml":
+		err := toml.Unmarshal(data, &config)
+		return config, err
+	default:
+		trimmed := bytes.TrimSpace(data)
+		if len(trimmed) > 0 && trimmed[0] == '[' {
+			err := json.Unmarshal(trimmed, &config.Projects)
+			return config, err
+		}
+		err := json.Unmarshal(trimmed, &config)
+		return config, err
+	}
+}
+
+// lookupProject returns the configuration for the given repository or alias
+func lookupProject(repo string) (Project, bool) {
+	projectsMu.RLock()
+	defer projectsMu.RUnlock()
+	project, exists := projects[repo]
+	if !exists {
+		if aliased, ok := aliases[strings.ToLower(repo)]; ok {
+			project, exists = projects[aliased]
+		}
+	}
+	return project, exists
+}
+
+// actionDir returns the working directory for the given action, falling back
+// to the project's dir when no per-action override is configured
+func (p Project) actionDir(action string) string {
+	var dir string
+	switch action {
+	case "up":
+		dir = p.UpDir
+	case "down":
+		dir = p.DownDir
+	case "restart":
+		dir = p.RestartDir
+	}
+	if dir == "" {
+		return p.Dir
+	}
+	return dir
+}
+
+// builtinActions are the actions every project supports. Toggle is resolved to
+// up or down from the tracked state before dispatch.
+var builtinActions = []string{"up", "down", "restart", "toggle"}
+
+// actionCommands returns the commands configured for the given action
+func (p Project) actionCommands(action string) ([]string, error) {
+	if !p.runsCommands() {
+		// The executor carries out the builtin actions itself
+		if action == "up" || action == "down" || action == "restart" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("the %s executor of %s only supports up, down, and restart", p.executor(), p.Repo)
+	}
+	switch action {
+	case "up":
+		return p.UpCommands, nil
+	case "down":
+		return p.DownCommands, nil
+	case "restart":
+		if len(p.RestartCommands) == 0 {
+			return nil, fmt.Errorf("no restartCommands configured for repository: %s", p.Repo)
+		}
+		return p.RestartCommands, nil
+	}
+	commands, ok := p.Actions[action]
+	if !ok {
+		return nil, fmt.Errorf("no %s action configured for repository: %s", action, p.Repo)
+	}
+	return commands, nil
+}
+
+// allProjects returns a snapshot of every configured project
+func allProjects() []Project {
+	projectsMu.RLock()
+	defer projectsMu.RUnlock()
+	snapshot := make([]Project, 0, len(projects))
+	for _, p := range projects {
+		snapshot = append(snapshot, p)
+	}
+	return snapshot
+}
+
+// setProject adds or replaces a single project in the active configuration.
+// raw is the project as submitted and resolved the result of building it.
+func setProject(raw, resolved Project) {
+	projectsMu.Lock()
+	updated := make(map[string]Project, len(projects)+1)
+	for repo, existing := range projects {
+		updated[repo] = existing
+	}
+	updated[resolved.Repo] = resolved
+	projects = updated
+	aliases = buildAliases(updated)
+	rawConfig = withRawProject(rawConfig, raw.Repo, &raw)
+	snapshot := rawConfig
+	projectsMu.Unlock()
+
+	recordConfigRevision(snapshot, "api: set "+raw.Repo)
+}
+
+// removeProject deletes a project from the active configuration, reporting
+// whether it existed
+func removeProject(repo string) bool {
+	projectsMu.Lock()
+	if _, exists := projects[repo]; !exists {
+		projectsMu.Unlock()
+		return false
+	}
+	updated := make(map[string]Project, len(projects))
+	for r, existing := range projects {
+		if r != repo {
+			updated[r] = existing
+		}
+	}
+	projects = updated
+	aliases = buildAliases(updated)
+	rawConfig = withRawProject(rawConfig, repo, nil)
+	snapshot := rawConfig
+	projectsMu.Unlock()
+
+	recordConfigRevision(snapshot, "api: remove "+repo)
+	return true
+}
+
+// withRawProject returns a copy of config with repo replaced by p, added if
+// missing, or removed when p is nil
+func withRawProject(config Config, repo string, p *Project) Config {
+	updated := make([]Project, 0, len(config.Projects)+1)
+	replaced := false
+	for _, existing := range config.Projects {
+		if existing.Repo != repo {
+			updated = append(updated, existing)
+		} else if p != nil && !replaced {
+			updated = append(updated, *p)
+			replaced = true
+		}
+	}
+	if p != nil && !replaced {
+		updated = append(updated, *p)
+	}
+	config.Projects = updated
+	return config
+}
+
+// activeTemplates returns the templates of the active configuration
+func activeTemplates() map[string]Project {
+	projectsMu.RLock()
+	defer projectsMu.RUnlock()
+	return templates
+}
+
+// watchConfig reloads the project configuration whenever the config file, or
+// any file in the config directory, changes. For a single file the parent
+// directory is watched rather than the file itself so that editors that write
+// a temporary file and rename it over the original are picked up too.
+func watchConfig(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+
+	configPath := filepath.Clean(getConfigFile())
+	watchDir := filepath.Dir(configPath)
+	if dir := getConfigDir(); dir != "" {
+		configPath = filepath.Clean(dir)
+		watchDir = configPath
+	}
+	if err := watcher.Add(watchDir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config directory: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isWatchedConfigPath(event.Name) {
+					continue
+				}
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove) {
+					reload = time.After(configReloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Config watcher error: %v", err)
+			case <-reload:
+				reload = nil
+				log.Printf("Configuration in %s changed, reloading", configPath)
+				if err := loadConfig(); err != nil {
+					log.Printf("Failed to reload configuration, keeping previous config: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Watching %s for changes", configPath)
+	return nil
+}
+
+// isWatchedConfigPath reports whether a change to name affects the configuration
+func isWatchedConfigPath(name string) bool {
+	name = filepath.Clean(name)
+	if dir := getConfigDir(); dir != "" {
+		return filepath.Dir(name) == filepath.Clean(dir) && isConfigFileName(filepath.Base(name))
+	}
+	return name == filepath.Clean(getConfigFile())
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+)
+
+// ConfigRevision is a recorded version of the raw project configuration
+type ConfigRevision struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason"`
+	Checksum  string    `json:"checksum"`
+	Projects  int       `json:"projects"`
+	Config    *Config   `json:"config,omitempty"`
+}
+
+var (
+	historyMu sync.Mutex
+	// lastRevisionChecksum identifies the most recently recorded revision so
+	// reloads that do not change anything are not recorded again
+	lastRevisionChecksum string
+)
+
+// recordConfigRevision stores config as a new revision in Redis unless it is
+// identical to the latest one. Failures are logged but never block a reload.
+func recordConfigRevision(config Config, reason string) {
+	if redisClient == nil || configHistoryLimit <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		log.Printf("Error recording config revision: %v", err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if lastRevisionChecksum == "" {
+		// Compare against the latest stored revision after a restart
+		if latest, err := redisClient.LIndex(ctx, configHistoryKey, 0).Result(); err == nil {
+			var rev ConfigRevision
+			if json.Unmarshal([]byte(latest), &rev) == nil {
+				lastRevisionChecksum = rev.Checksum
+			}
+		}
+	}
+	if checksum == lastRevisionChecksum {
+		return
+	}
+
+	id, err := redisClient.Incr(ctx, configHistoryKey+":seq").Result()
+	if err != nil {
+		log.Printf("Error recording config revision: %v", err)
+		return
+	}
+	rev := ConfigRevision{
+		ID:        id,
+		Timestamp: time.Now().UTC(),
+		Reason:    reason,
+		Checksum:  checksum,
+		Projects:  len(config.Projects),
+		Config:    &config,
+	}
+	revJSON, err := json.Marshal(rev)
+	if err != nil {
+		log.Printf("Error recording config revision: %v", err)
+		return
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.LPush(ctx, configHistoryKey, revJSON)
+	pipe.LTrim(ctx, configHistoryKey, 0, int64(configHistoryLimit-1))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error recording config revision: %v", err)
+		return
+	}
+
+	lastRevisionChecksum = checksum
+	log.Printf("Recorded configuration revision %d (%s)", id, reason)
+}
+
+// listConfigRevisions returns the stored revisions, newest first
+func listConfigRevisions(ctx context.Context) ([]ConfigRevision, error) {
+	entries, err := redisClient.LRange(ctx, configHistoryKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config history: %w", err)
+	}
+	revisions := make([]ConfigRevision, 0, len(entries))
+	for _, entry := range entries {
+		var rev ConfigRevision
+		if err := json.Unmarshal([]byte(entry), &rev); err != nil {
+			log.Printf("Skipping unreadable config revision: %v", err)
+			continue
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+var errRevisionNotFound = errors.New("revision not found")
+
+func getConfigRevision(ctx context.Context, id int64) (ConfigRevision, error) {
+	revisions, err := listConfigRevisions(ctx)
+	if err != nil {
+		return ConfigRevision{}, err
+	}
+	for _, rev := range revisions {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+	return ConfigRevision{}, errRevisionNotFound
+}
+
+// rollbackConfig makes the given revision the active configuration and writes
+// it back to the configuration source so that it survives restarts
+func rollbackConfig(ctx context.Context, id int64) error {
+	rev, err := getConfigRevision(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rev.Config == nil {
+		return fmt.Errorf("revision %d has no configuration", id)
+	}
+
+	if err := applyConfig(*rev.Config, fmt.Sprintf("rollback to %d", id)); err != nil {
+		return err
+	}
+	return writeSourceConfig(ctx, *rev.Config)
+}
+
+// writeSourceConfig replaces the configuration source with config
+func writeSourceConfig(ctx context.Context, config Config) error {
+	if configSource == "redis" {
+		pipe := redisClient.TxPipeline()
+		pipe.Del(ctx, configRedisKey)
+		for _, p := range config.Projects {
+			data, err := json.Marshal(p)
+			if err != nil {
+				return fmt.Errorf("failed to marshal project %s: %w", p.Repo, err)
+			}
+			pipe.HSet(ctx, configRedisKey, p.Repo, data)
+		}
+		pipe.Publish(ctx, configRedisChannel, "rollback")
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to write projects to redis: %w", err)
+		}
+		return nil
+	}
+
+	if configSource == "consul" || configSource == "etcd" {
+		return writeKVConfig(ctx, config)
+	}
+
+	if getConfigDir() != "" {
+		log.Println("Rollback applied in memory only; CONFIG_DIR is not rewritten")
+		return nil
+	}
+
+	path := getConfigFile()
+	if isRemoteConfig(path) {
+		log.Println("Rollback applied in memory only; remote configuration is not rewritten")
+		return nil
+	}
+	ext := filepath.Ext(path)
+	var doc any = config
+	if data, err := os.ReadFile(path); err == nil && isListDocument(data, ext) && len(config.Templates) == 0 && len(config.Queues) == 0 {
+		doc = config.Projects
+	}
+	out, err := encodeDocument(doc, ext)
+	if err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	return writeFileAtomic(path, out)
+}
+
+// handleListConfigRevisions handles GET /config/revisions
+func handleListConfigRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := listConfigRevisions(r.Context())
+	if err != nil {
+		log.Printf("Error listing config revisions: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list revisions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for i := range revisions {
+		revisions[i].Config = nil
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// handleGetConfigRevision handles GET /config/revisions/{id}
+func handleGetConfigRevision(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid revision id", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := getConfigRevision(r.Context(), id)
+	if errors.Is(err, errRevisionNotFound) {
+		http.Error(w, fmt.Sprintf("Revision %d not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading config revision %d: %v", id, err)
+		http.Error(w, fmt.Sprintf("Failed to read revision: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rev)
+}
+
+// handleRollbackConfig handles POST /config/revisions/{id}/rollback
+func handleRollbackConfig(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid revision id", http.StatusBadRequest)
+		return
+	}
+	if err := authorizeConfigAll(contextIdentity(r.Context())); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	projectsAPIMu.Lock()
+	defer projectsAPIMu.Unlock()
+
+	if err := rollbackConfig(r.Context(), id); err != nil {
+		if errors.Is(err, errRevisionNotFound) {
+			http.Error(w, fmt.Sprintf("Revision %d not found", id), http.StatusNotFound)
+			return
+		}
+		log.Printf("Error rolling back to revision %d: %v", id, err)
+		http.Error(w, fmt.Sprintf("Failed to roll back: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Rolled back configuration to revision %d", id)
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: fmt.Sprintf("Rolled back to revision %d", id)})
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kvPair is a single project definition stored in a key/value backend
+type kvPair struct {
+	Key   string
+	Value []byte
+}
+
+// projectsFromKV parses project definitions stored under configKVPrefix. The
+// key suffix after the prefix is the repository identifier.
+func projectsFromKV(pairs []kvPair) ([]Project, error) {
+	config := make([]Project, 0, len(pairs))
+	for _, pair := range pairs {
+		repo := strings.TrimPrefix(pair.Key, configKVPrefix)
+		if repo == "" || len(pair.Value) == 0 {
+			continue
+		}
+		var project Project
+		if err := json.Unmarshal(pair.Value, &project); err != nil {
+			return nil, fmt.Errorf("failed to parse project %s from %s: %w", repo, configSource, err)
+		}
+		project.Repo = repo
+		config = append(config, project)
+	}
+	return config, nil
+}
+
+// kvRequest performs an HTTP request against a key/value backend and decodes
+// the JSON response into out (if non-nil)
+func kvRequest(ctx context.Context, method, endpoint string, body any, header http.Header, out any) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return resp.Header, nil
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// Consul
+
+func consulHeader() http.Header {
+	header := http.Header{}
+	if consulToken != "" {
+		header.Set("X-Consul-Token", consulToken)
+	}
+	return header
+}
+
+// readConsulProjects lists the project keys in Consul. When index is non-zero
+// the request blocks until the data changes or the wait time elapses.
+func readConsulProjects(ctx context.Context, index uint64) ([]kvPair, uint64, error) {
+	query := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", "5m")
+	}
+	endpoint := strings.TrimRight(consulAddr, "/") + "/v1/kv/" + configKVPrefix + "?" + query.Encode()
+
+	var entries []struct {
+		Key   string
+		Value []byte
+	}
+	header, err := kvRequest(ctx, http.MethodGet, endpoint, nil, consulHeader(), &entries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read projects from consul: %w", err)
+	}
+
+	newIndex, _ := strconv.ParseUint(header.Get("X-Consul-Index"), 10, 64)
+	pairs := make([]kvPair, 0, len(entries))
+	for _, e := range entries {
+		pairs = append(pairs, kvPair{Key: e.Key, Value: e.Value})
+	}
+	return pairs, newIndex, nil
+}
+
+func loadProjectsFromConsul(ctx context.Context) ([]Project, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	pairs, _, err := readConsulProjects(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	return projectsFromKV(pairs)
+}
+
+// watchConsulConfig uses Consul blocking queries to reload the configuration
+// whenever a key under the prefix changes
+func watchConsulConfig(ctx context.Context) {
+	go func() {
+		var index uint64
+		for ctx.Err() == nil {
+			_, newIndex, err := readConsulProjects(ctx, index)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Consul watch error: %v", err)
+					time.Sleep(5 * time.Second)
+				}
+				continue
+			}
+			// Consul may reset the index; start over rather than block forever
+			if newIndex < index {
+				newIndex = 0
+			}
+			if index != 0 && newIndex != index {
+				log.Printf("Project configuration in consul changed, reloading")
+				if err := loadConfig(); err != nil {
+					log.Printf("Failed to reload configuration, keeping previous config: %v", err)
+				}
+			}
+			index = newIndex
+		}
+	}()
+
+	log.Printf("Watching consul prefix %s for configuration changes", configKVPrefix)
+}
+
+func consulPut(ctx context.Context, key string, value []byte) error {
+	endpoint := strings.TrimRight(consulAddr, "/") + "/v1/kv/" + key
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	req.Header = consulHeader()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func consulDelete(ctx context.Context, key string) error {
+	endpoint := strings.TrimRight(consulAddr, "/") + "/v1/kv/" + key
+	_, err := kvRequest(ctx, http.MethodDelete, endpoint, nil, consulHeader(), nil)
+	return err
+}
+
+// etcd (v3 JSON gateway)
+
+func etcdKey(key string) string {
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
+
+// etcdPrefixEnd returns the range end that covers every key with the prefix
+func etcdPrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return base64.StdEncoding.EncodeToString(end[:i+1])
+		}
+	}
+	return base64.StdEncoding.EncodeToString([]byte{0})
+}
+
+func loadProjectsFromEtcd(ctx context.Context) ([]Project, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	pairs, err := readEtcdProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return projectsFromKV(pairs)
+}
+
+// readEtcdProjects lists the project keys in etcd
+func readEtcdProjects(ctx context.Context) ([]kvPair, error) {
+	var resp struct {
+		Kvs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	body := map[string]string{"key": etcdKey(configKVPrefix), "range_end": etcdPrefixEnd(configKVPrefix)}
+	if _, err := kvRequest(ctx, http.MethodPost, strings.TrimRight(etcdEndpoint, "/")+"/v3/kv/range", body, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read projects from etcd: %w", err)
+	}
+
+	pairs := make([]kvPair, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		pairs = append(pairs, kvPair{Key: string(kv.Key), Value: kv.Value})
+	}
+	return pairs, nil
+}
+
+// watchEtcdConfig opens an etcd watch stream on the prefix and reloads the
+// configuration whenever events arrive, reconnecting if the stream drops
+func watchEtcdConfig(ctx context.Context) {
+	go func() {
+		for ctx.Err() == nil {
+			if err := streamEtcdWatch(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("etcd watch error: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}()
+
+	log.Printf("Watching etcd prefix %s for configuration changes", configKVPrefix)
+}
+
+func streamEtcdWatch(ctx context.Context) error {
+	body, err := json.Marshal(map[string]any{
+		"create_request": map[string]string{"key": etcdKey(configKVPrefix), "range_end": etcdPrefixEnd(configKVPrefix)},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(etcdEndpoint, "/")+"/v3/watch", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Events []json.RawMessage `json:"events"`
+			} `json:"result"`
+		}
+		if err := decoder.Decode(&msg); err != nil {
+			return err
+		}
+		if len(msg.Result.Events) == 0 {
+			continue
+		}
+		log.Printf("Project configuration in etcd changed, reloading")
+		if err := loadConfig(); err != nil {
+			log.Printf("Failed to reload configuration, keeping previous config: %v", err)
+		}
+	}
+}
+
+func etcdPut(ctx context.Context, key string, value []byte) error {
+	body := map[string]string{"key": etcdKey(key), "value": base64.StdEncoding.EncodeToString(value)}
+	_, err := kvRequest(ctx, http.MethodPost, strings.TrimRight(etcdEndpoint, "/")+"/v3/kv/put", body, nil, nil)
+	return err
+}
+
+func etcdDelete(ctx context.Context, key string) error {
+	body := map[string]string{"key": etcdKey(key)}
+	_, err := kvRequest(ctx, http.MethodPost, strings.TrimRight(etcdEndpoint, "/")+"/v3/kv/deleterange", body, nil, nil)
+	return err
+}
+
+// persistKVProject stores or (when p is nil) removes a project in the
+// configured key/value backend
+func persistKVProject(ctx context.Context, repo string, p *Project) error {
+	key := configKVPrefix + repo
+	if p == nil {
+		if configSource == "consul" {
+			return consulDelete(ctx, key)
+		}
+		return etcdDelete(ctx, key)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project: %w", err)
+	}
+	if configSource == "consul" {
+		return consulPut(ctx, key, data)
+	}
+	return etcdPut(ctx, key, data)
+}
+
+// writeKVConfig replaces every project under the prefix with config in a
+// single transaction, so watchers never observe an empty or partial prefix and
+// a failure leaves the stored configuration unchanged
+func writeKVConfig(ctx context.Context, config Config) error {
+	pairs := make([]kvPair, 0, len(config.Projects))
+	for i := range config.Projects {
+		data, err := json.Marshal(&config.Projects[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal project %s: %w", config.Projects[i].Repo, err)
+		}
+		pairs = append(pairs, kvPair{Key: configKVPrefix + config.Projects[i].Repo, Value: data})
+	}
+
+	var err error
+	if configSource == "consul" {
+		err = consulReplace(ctx, pairs)
+	} else {
+		err = etcdReplace(ctx, pairs)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write projects to %s: %w", configSource, err)
+	}
+	return nil
+}
+
+// consulReplace clears the prefix and writes pairs through /v1/txn, which
+// applies every operation or none of them
+func consulReplace(ctx context.Context, pairs []kvPair) error {
+	type kvOp struct {
+		Verb  string
+		Key   string
+		Value []byte `json:",omitempty"`
+	}
+	ops := []map[string]kvOp{{"KV": {Verb: "delete-tree", Key: configKVPrefix}}}
+	for _, pair := range pairs {
+		ops = append(ops, map[string]kvOp{"KV": {Verb: "set", Key: pair.Key, Value: pair.Value}})
+	}
+	_, err := kvRequest(ctx, http.MethodPut, strings.TrimRight(consulAddr, "/")+"/v1/txn", ops, consulHeader(), nil)
+	return err
+}
+
+// etcdReplace writes pairs and deletes the other keys under the prefix through
+// /v3/kv/txn. etcd rejects a transaction that puts a key inside a range it
+// also deletes, so stale keys are deleted one by one rather than clearing the
+// prefix.
+func etcdReplace(ctx context.Context, pairs []kvPair) error {
+	existing, err := readEtcdProjects(ctx)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(pairs))
+	var ops []map[string]map[string]string
+	for _, pair := range pairs {
+		keep[pair.Key] = true
+		ops = append(ops, map[string]map[string]string{
+			"request_put": {"key": etcdKey(pair.Key), "value": base64.StdEncoding.EncodeToString(pair.Value)},
+		})
+	}
+	for _, pair := range existing {
+		if !keep[pair.Key] {
+			ops = append(ops, map[string]map[string]string{"request_delete_range": {"key": etcdKey(pair.Key)}})
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	var resp struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	body := map[string]any{"success": ops}
+	if _, err := kvRequest(ctx, http.MethodPost, strings.TrimRight(etcdEndpoint, "/")+"/v3/kv/txn", body, nil, &resp); err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("etcd transaction was not applied")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// loadProjectsFromRedis reads project definitions from the configured Redis hash.
+// Each field is a repository identifier and each value a JSON-encoded Project.
+func loadProjectsFromRedis(ctx context.Context) ([]Project, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client not initialised")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	entries, err := redisClient.HGetAll(ctx, configRedisKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read projects from redis hash %s: %w", configRedisKey, err)
+	}
+
+	config := make([]Project, 0, len(entries))
+	for repo, value := range entries {
+		var project Project
+		if err := json.Unmarshal([]byte(value), &project); err != nil {
+			return nil, fmt.Errorf("failed to parse project %s from redis: %w", repo, err)
+		}
+		// The hash field is the source of truth for the repository name
+		project.Repo = repo
+		config = append(config, project)
+	}
+	return config, nil
+}
+
+// watchRedisConfig resyncs the project configuration whenever a message is
+// published on the control channel or a keyspace notification fires for the
+// projects hash. Keyspace notifications require notify-keyspace-events to
+// include hash events (e.g. "Kh") on the Redis server.
+func watchRedisConfig(ctx context.Context, rdb *redis.Client) {
+	keyspaceChannel := fmt.Sprintf("__keyspace@%d__:%s", rdb.Options().DB, configRedisKey)
+	pubsub := rdb.Subscribe(ctx, configRedisChannel, keyspaceChannel)
+
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		var resync <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+				// Coalesce bursts of updates (e.g. several HSETs) into one resync
+				resync = time.After(configReloadDebounce)
+			case <-resync:
+				resync = nil
+				log.Printf("Project configuration in %s changed, resyncing", configRedisKey)
+				if err := loadConfig(); err != nil {
+					log.Printf("Failed to resync configuration, keeping previous config: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Watching redis hash %s and channel %s for configuration changes", configRedisKey, configRedisChannel)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	remoteConfigMu sync.Mutex
+	// remoteConfigETag and remoteConfigCache hold the last successfully fetched
+	// remote configuration so unchanged documents are not downloaded again
+	remoteConfigETag  string
+	remoteConfigCache Config
+
+	// remoteConfigClient does not follow redirects from https:// to http:// so
+	// the bearer token cannot leak through a downgrade
+	remoteConfigClient = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" && req.Header.Get("Authorization") != "" {
+				return fmt.Errorf("refusing to follow redirect to %s with CONFIG_URL_TOKEN", req.URL.Redacted())
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+
+	errRemoteConfigReadOnly = errors.New("remote configuration is read-only")
+)
+
+// isRemoteConfig reports whether the config location is a URL
+func isRemoteConfig(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
+
+// fetchRemoteConfig downloads and parses the configuration at rawURL. It sends
+// the ETag of the previous response and reports changed=false when the server
+// answers 304 Not Modified, in which case the cached configuration is returned.
+func fetchRemoteConfig(ctx context.Context, rawURL string) (config Config, changed bool, err error) {
+	remoteConfigMu.Lock()
+	defer remoteConfigMu.Unlock()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Config{}, false, fmt.Errorf("invalid config URL: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Config{}, false, fmt.Errorf("failed to build config request: %w", err)
+	}
+	if remoteConfigETag != "" {
+		req.Header.Set("If-None-Match", remoteConfigETag)
+	}
+	if configURLToken != "" {
+		// Never send the token in cleartext
+		if u.Scheme != "https" {
+			return Config{}, false, fmt.Errorf("refusing to send CONFIG_URL_TOKEN to %s config URL; use https://", u.Scheme)
+		}
+		req.Header.Set("Authorization", "Bearer "+configURLToken)
+	}
+
+	resp, err := remoteConfigClient.Do(req)
+	if err != nil {
+		return Config{}, false, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return remoteConfigCache, false, nil
+	case http.StatusOK:
+	default:
+		return Config{}, false, fmt.Errorf("failed to fetch config: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return Config{}, false, fmt.Errorf("failed to read config response: %w", err)
+	}
+	config, err = parseConfig(data, path.Ext(u.Path))
+	if err != nil {
+		return Config{}, false, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	remoteConfigETag = resp.Header.Get("ETag")
+	remoteConfigCache = config
+	return config, true, nil
+}
+
+// runRemoteConfigRefresh re-fetches the remote configuration on an interval
+// and applies it whenever it has changed
+func runRemoteConfigRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				config, changed, err := fetchRemoteConfig(ctx, getConfigFile())
+				if err != nil {
+					log.Printf("Failed to refresh remote configuration, keeping previous config: %v", err)
+					continue
+				}
+				if !changed {
+					continue
+				}
+				log.Printf("Remote configuration at %s changed, reloading", getConfigFile())
+				if err := applyConfig(config, "remote refresh"); err != nil {
+					log.Printf("Failed to apply remote configuration, keeping previous config: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Refreshing remote configuration every %s", interval)
+}
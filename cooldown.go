@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cooldown modes for actions that arrive while their cooldown is running
+const (
+	cooldownReject = "reject"
+	cooldownDefer  = "defer"
+)
+
+// cooldownPeriod returns how long after an action is dispatched for the
+// project the same action is held back
+func (p Project) cooldownPeriod() time.Duration {
+	if d, err := time.ParseDuration(p.Cooldown); err == nil {
+		return d
+	}
+	return defaultCooldown
+}
+
+// cooldownModeOrDefault returns whether held-back actions are rejected or deferred
+func (p Project) cooldownModeOrDefault() string {
+	if p.CooldownMode != "" {
+		return p.CooldownMode
+	}
+	return cooldownMode
+}
+
+// validateCooldown checks a project's cooldown settings
+func validateCooldown(p Project) []error {
+	var errs []error
+	if p.Cooldown != "" {
+		if d, err := time.ParseDuration(p.Cooldown); err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("project %s: invalid cooldown %q", p.Repo, p.Cooldown))
+		}
+	}
+	switch p.CooldownMode {
+	case "", cooldownReject, cooldownDefer:
+	default:
+		errs = append(errs, fmt.Errorf("project %s: invalid cooldownMode %q (expected %s or %s)", p.Repo, p.CooldownMode, cooldownReject, cooldownDefer))
+	}
+	return errs
+}
+
+func cooldownKey(action, repo string) string {
+	return cooldownKeyPrefix + action + ":" + repo
+}
+
+// checkCooldown reports whether action may be dispatched for the project now.
+// While its cooldown is running the action is either rejected with an error
+// or scheduled for when the cooldown ends.
+func checkCooldown(ctx context.Context, rdb *redis.Client, msg RedisMessage, project Project, action string) (bool, error) {
+	if project.cooldownPeriod() <= 0 {
+		return true, nil
+	}
+	remaining, err := rdb.PTTL(ctx, cooldownKey(action, project.Repo)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check cooldown of %s for %s: %w", action, project.Repo, err)
+	}
+	if remaining <= 0 {
+		return true, nil
+	}
+
+	if project.cooldownModeOrDefault() != cooldownDefer {
+		return false, fmt.Errorf("%s for %s is cooling down for another %s", action, project.Repo, remaining.Round(time.Second))
+	}
+
+	deferred := RedisMessage{Action: action, Repo: Target(project.Repo), CorrelationID: msg.CorrelationID, MessageOptions: msg.MessageOptions}
+	deferred.IfState = ""
+	if _, err := scheduleMessage(ctx, rdb, deferred, time.Now().Add(remaining)); err != nil {
+		return false, err
+	}
+	log.Printf("[%s] Deferring %s for %s until its cooldown ends in %s", msg.CorrelationID, action, project.Repo, remaining.Round(time.Second))
+	return false, nil
+}
+
+// startCooldown starts the cooldown of action for the project after it has
+// been dispatched
+func startCooldown(ctx context.Context, rdb *redis.Client, msg RedisMessage, project Project, action string) {
+	period := project.cooldownPeriod()
+	if period <= 0 {
+		return
+	}
+	if err := rdb.Set(ctx, cooldownKey(action, project.Repo), msg.CorrelationID, period).Err(); err != nil {
+		log.Printf("[%s] Error starting cooldown of %s for %s: %v", msg.CorrelationID, action, project.Repo, err)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dependencyIndex maps every repo and alias in projects to its repo
+func dependencyIndex(projects []Project) map[string]string {
+	index := make(map[string]string, len(projects))
+	for _, p := range projects {
+		index[p.Repo] = p.Repo
+		for _, alias := range p.Aliases {
+			index[strings.ToLower(alias)] = p.Repo
+		}
+	}
+	return index
+}
+
+// resolveDependency returns the repo a dependsOn entry refers to
+func resolveDependency(index map[string]string, dep string) (string, bool) {
+	if repo, ok := index[dep]; ok {
+		return repo, true
+	}
+	repo, ok := index[strings.ToLower(dep)]
+	return repo, ok
+}
+
+// validateDependencies checks that every dependsOn entry names a configured
+// project and that the dependencies do not form a cycle
+func validateDependencies(projects []Project) []error {
+	var errs []error
+	index := dependencyIndex(projects)
+	edges := make(map[string][]string, len(projects))
+	for _, p := range projects {
+		for _, dep := range p.DependsOn {
+			repo, ok := resolveDependency(index, dep)
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Errorf("project %s: dependsOn %q is not a configured project", p.Repo, dep))
+			case repo == p.Repo:
+				errs = append(errs, fmt.Errorf("project %s: cannot depend on itself", p.Repo))
+			default:
+				edges[p.Repo] = append(edges[p.Repo], repo)
+			}
+		}
+	}
+
+	// Depth-first search for a cycle, reporting each one once
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := make(map[string]int)
+	var visit func(repo string, path []string) bool
+	visit = func(repo string, path []string) bool {
+		switch marks[repo] {
+		case visiting:
+			cycle := append(path[slices.Index(path, repo):], repo)
+			errs = append(errs, fmt.Errorf("project %s: dependency cycle %s", repo, strings.Join(cycle, " -> ")))
+			return true
+		case done:
+			return false
+		}
+		marks[repo] = visiting
+		for _, dep := range edges[repo] {
+			if visit(dep, append(path, repo)) {
+				marks[repo] = done
+				return true
+			}
+		}
+		marks[repo] = done
+		return false
+	}
+	repos := make([]string, 0, len(projects))
+	for _, p := range projects {
+		repos = append(repos, p.Repo)
+	}
+	slices.Sort(repos)
+	for _, repo := range repos {
+		visit(repo, nil)
+	}
+	return errs
+}
+
+// withDependencies adds the transitive dependencies of projects that are not
+// already up or starting, so that bringing a project up brings up what it
+// needs first
+func withDependencies(projects []Project) []Project {
+	included := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		included[p.Repo] = true
+	}
+	result := slices.Clone(projects)
+	for i := 0; i < len(result); i++ {
+		for _, dep := range result[i].DependsOn {
+			p, ok := lookupProject(dep)
+			if !ok || included[p.Repo] {
+				continue
+			}
+			included[p.Repo] = true
+			switch getProjectState(p.Repo).State {
+			case stateUp, stateStarting:
+				continue
+			}
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// dependencyTiers splits projects into tiers so that every project comes
+// after the projects it depends on. Dependencies outside the given projects
+// are ignored. Within a tier projects keep the usual group ordering.
+func dependencyTiers(projects []Project, action string) [][]Project {
+	remaining := make(map[string]Project, len(projects))
+	for _, p := range projects {
+		remaining[p.Repo] = p
+	}
+
+	var tiers [][]Project
+	for len(remaining) > 0 {
+		var tier []Project
+		for _, p := range remaining {
+			ready := true
+			for _, dep := range p.DependsOn {
+				if d, ok := lookupProject(dep); ok && d.Repo != p.Repo {
+					if _, pending := remaining[d.Repo]; pending {
+						ready = false
+						break
+					}
+				}
+			}
+			if ready {
+				tier = append(tier, p)
+			}
+		}
+		if len(tier) == 0 {
+			// A cycle slipped past validation; dispatch the rest together
+			for _, p := range remaining {
+				tier = append(tier, p)
+			}
+		}
+		for _, p := range tier {
+			delete(remaining, p.Repo)
+		}
+		tiers = append(tiers, sortForAction(tier, action))
+	}
+	return tiers
+}
+
+// runningDependents returns the configured projects outside projects that
+// depend on one of them, directly or transitively, and are up or starting
+func runningDependents(projects []Project) []Project {
+	included := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		included[p.Repo] = true
+	}
+
+	all := allProjects()
+	var dependents []Project
+	for found := true; found; {
+		found = false
+		for _, p := range all {
+			if included[p.Repo] {
+				continue
+			}
+			for _, dep := range p.DependsOn {
+				if d, ok := lookupProject(dep); ok && included[d.Repo] {
+					included[p.Repo] = true
+					dependents = append(dependents, p)
+					found = true
+					break
+				}
+			}
+		}
+	}
+
+	running := dependents[:0]
+	for _, p := range dependents {
+		switch getProjectState(p.Repo).State {
+		case stateUp, stateStarting:
+			running = append(running, p)
+		}
+	}
+	return running
+}
+
+// withDependents prepares the projects for a down. Running dependents must be
+// stopped first: they are added when the message sets cascade, and the down is
+// refused unless it sets force. The projects are ordered so that every project
+// is stopped before the projects it depends on.
+func withDependents(projects []Project, msg RedisMessage) ([]Project, error) {
+	if !msg.Force {
+		if dependents := runningDependents(projects); len(dependents) > 0 {
+			if !msg.Cascade {
+				return nil, fmt.Errorf("refusing to stop %s while %s depend on it; set \"cascade\": true to stop them first or \"force\": true to stop it anyway",
+					tierRepos([][]Project{projects}), tierRepos([][]Project{dependents}))
+			}
+			log.Printf("[%s] Cascading down to dependents %s", msg.CorrelationID, tierRepos([][]Project{dependents}))
+			projects = append(dependents, projects...)
+		}
+	}
+
+	tiers := dependencyTiers(projects, "down")
+	slices.Reverse(tiers)
+	ordered := make([]Project, 0, len(projects))
+	for _, tier := range tiers {
+		ordered = append(ordered, tier...)
+	}
+	return ordered, nil
+}
+
+// dispatchTiers brings projects up one dependency tier at a time. The first
+// tier is dispatched straight away; the rest follow in the background, each
+// once the previous tier is ready, so the message consumer is not held up.
+func dispatchTiers(ctx context.Context, rdb *redis.Client, msg RedisMessage, tiers [][]Project) error {
+	if err := dispatchTier(ctx, rdb, msg, tiers[0]); err != nil {
+		return err
+	}
+	go func() {
+		for i := 1; i < len(tiers); i++ {
+			if err := waitForTier(ctx, tiers[i-1], time.Now()); err != nil {
+				log.Printf("[%s] Not bringing up %s: %v", msg.CorrelationID, tierRepos(tiers[i:]), err)
+				return
+			}
+			if err := dispatchTier(ctx, rdb, msg, tiers[i]); err != nil {
+				log.Printf("[%s] Error bringing up dependency tier %d: %v", msg.CorrelationID, i+1, err)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func dispatchTier(ctx context.Context, rdb *redis.Client, msg RedisMessage, tier []Project) error {
+	var errs []error
+	for _, project := range tier {
+		if err := dispatchAction(ctx, rdb, msg, project, "up"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitForTier waits until every project in the tier is ready: projects with a
+// healthCheck once a probe after since has passed, and the others once
+// DEPENDENCY_DELAY has elapsed. It gives up after DEPENDENCY_TIMEOUT.
+func waitForTier(ctx context.Context, tier []Project, since time.Time) error {
+	deadline := since.Add(dependencyTimeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		var waiting []string
+		for _, p := range tier {
+			if p.HealthCheck == nil {
+				if time.Since(since) < dependencyDelay {
+					waiting = append(waiting, p.Repo)
+				}
+				continue
+			}
+			s := getProjectState(p.Repo)
+			if s.Health != healthHealthy || s.HealthCheckedAt.Before(since) {
+				waiting = append(waiting, p.Repo)
+			}
+		}
+		if len(waiting) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %s", strings.Join(waiting, ", "))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func tierRepos(tiers [][]Project) string {
+	var repos []string
+	for _, tier := range tiers {
+		repos = append(repos, projectRepos(tier)...)
+	}
+	return strings.Join(repos, ", ")
+}
+
+// projectRepos returns the repos of the given projects
+func projectRepos(projects []Project) []string {
+	repos := make([]string, 0, len(projects))
+	for _, p := range projects {
+		repos = append(repos, p.Repo)
+	}
+	return repos
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Embed colours for successful and failed commands
+const (
+	discordColorSuccess = 0x2ecc71
+	discordColorFailure = 0xe74c3c
+)
+
+// runDiscordBot connects to Discord with DISCORD_TOKEN and handles commands
+// such as "!svc up InnerGate" posted in DISCORD_CHANNELS, which must be set so
+// that not everyone who can reach the bot can control the projects
+func runDiscordBot() (*discordgo.Session, error) {
+	if len(discordChannels) == 0 {
+		return nil, errors.New("DISCORD_CHANNELS must list the channels commands are accepted in")
+	}
+	dg, err := discordgo.New("Bot " + discordToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Discord session: %w", err)
+	}
+	dg.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentMessageContent
+	dg.AddHandler(handleDiscordMessage)
+
+	if err := dg.Open(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Discord: %w", err)
+	}
+	log.Printf("Listening for %s commands on Discord", discordPrefix)
+	return dg, nil
+}
+
+// handleDiscordMessage runs a command posted in an allowed channel on behalf
+// of discord:<user ID>, and replies with an embed describing the outcome.
+// Direct messages are ignored.
+func handleDiscordMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot || m.GuildID == "" {
+		return
+	}
+	text, ok := strings.CutPrefix(strings.TrimSpace(m.Content), discordPrefix+" ")
+	if !ok {
+		return
+	}
+	if !slices.Contains(discordChannels, m.ChannelID) {
+		return
+	}
+	// Every instance receives the message, so only the leader acts on it
+	if !isLeader() {
+		return
+	}
+
+	log.Printf("Received Discord command from %s: %s", m.Author.Username, text)
+	cmd, err := runChatCommand(strings.TrimSpace(text), "discord:"+m.Author.Username, "discord:"+m.Author.ID)
+	if _, err := s.ChannelMessageSendEmbedReply(m.ChannelID, discordEmbed(cmd, err), m.Reference()); err != nil {
+		log.Printf("Error replying on Discord: %v", err)
+	}
+}
+
+// discordEmbed describes the outcome of a command
+func discordEmbed(cmd chatCommand, err error) *discordgo.MessageEmbed {
+	if err != nil {
+		return &discordgo.MessageEmbed{
+			Title:       "Command failed",
+			Description: err.Error(),
+			Color:       discordColorFailure,
+		}
+	}
+
+	if cmd.Status != nil {
+		if !cmd.Status.Found {
+			return &discordgo.MessageEmbed{
+				Title:       "Project not found",
+				Description: fmt.Sprintf("No project matches %s", cmd.Target),
+				Color:       discordColorFailure,
+			}
+		}
+		embed := &discordgo.MessageEmbed{
+			Title: cmd.Status.Repo,
+			Color: discordColorSuccess,
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "State", Value: cmd.Status.State, Inline: true},
+			},
+		}
+		if cmd.Status.LastAction != "" {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name: "Last action", Value: fmt.Sprintf("%s at %s", cmd.Status.LastAction, cmd.Status.LastActionAt), Inline: true,
+			})
+		}
+		return embed
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s %s", cmd.Action, cmd.Target),
+		Description: cmd.Result.Message,
+		Color:       discordColorSuccess,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Repo", Value: strings.Join(cmd.Repos, "\n")},
+			{Name: "Action", Value: cmd.Action, Inline: true},
+			{Name: "Target queue", Value: cmd.TargetQueue, Inline: true},
+			{Name: "Correlation ID", Value: cmd.Result.CorrelationID},
+		},
+	}
+}
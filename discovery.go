@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// composeFileNames are the file names that mark a directory as a discoverable project
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yml", "docker-compose.yaml"}
+
+// discoverProjects scans root for directories containing a compose file, up to
+// discoveryDepth levels deep, and returns a project for each. The repo name is
+// the directory path relative to root, so a root of ~/github yields names in
+// "owner/repo" form.
+func discoverProjects(root string) ([]Project, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve discovery root: %w", err)
+	}
+
+	var discovered []Project
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		depth := 0
+		if rel != "." {
+			depth = len(strings.Split(rel, string(filepath.Separator)))
+		}
+
+		if depth > 0 && hasComposeFile(path) {
+			discovered = append(discovered, discoveredProject(filepath.ToSlash(rel), path))
+			return filepath.SkipDir
+		}
+		if depth >= discoveryDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan discovery root %s: %w", root, err)
+	}
+	return discovered, nil
+}
+
+func hasComposeFile(dir string) bool {
+	for _, name := range composeFileNames {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// discoveredProject builds the configuration for a discovered directory. If
+// DISCOVERY_TEMPLATE is set the project extends that template; otherwise it
+// gets the default docker compose commands.
+func discoveredProject(repo, dir string) Project {
+	if discoveryTemplate != "" {
+		return Project{Repo: repo, Dir: dir, Extends: discoveryTemplate}
+	}
+	return Project{
+		Repo:            repo,
+		Dir:             dir,
+		UpCommands:      []string{"docker compose up -d"},
+		DownCommands:    []string{"docker compose down"},
+		RestartCommands: []string{"docker compose restart"},
+	}
+}
+
+// mergeDiscoveredProjects adds discovered projects to the configuration unless
+// a project with the same repo is configured explicitly
+func mergeDiscoveredProjects(config Config, discovered []Project) Config {
+	explicit := make(map[string]bool, len(config.Projects))
+	for _, p := range config.Projects {
+		explicit[p.Repo] = true
+	}
+
+	added := 0
+	for _, p := range discovered {
+		if !explicit[p.Repo] {
+			config.Projects = append(config.Projects, p)
+			added++
+		}
+	}
+	log.Printf("Discovered %d projects in %s (%d not explicitly configured)", len(discovered), discoveryRoot, added)
+	return config
+}
+
+// runDiscoveryLoop reloads the configuration periodically so that newly added
+// repositories are discovered without a restart
+func runDiscoveryLoop(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := loadConfig(); err != nil {
+					log.Printf("Failed to rescan projects, keeping previous config: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dockerClient talks to the Docker Engine API over DOCKER_SOCKET
+var dockerClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", dockerSocket)
+		},
+	},
+}
+
+var composeNameInvalid = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// composeProjectName returns the Docker Compose project name for a project,
+// which Compose derives from the directory name unless overridden
+func composeProjectName(p Project) string {
+	if name := p.Env["COMPOSE_PROJECT_NAME"]; name != "" {
+		return name
+	}
+	return composeNameInvalid.ReplaceAllString(strings.ToLower(filepath.Base(p.Dir)), "")
+}
+
+// dockerObservedState reports a project as up when any container of its
+// Compose project is running, and down otherwise
+func dockerObservedState(ctx context.Context, p Project) (string, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"label":  {"com.docker.compose.project=" + composeProjectName(p)},
+		"status": {"running"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json?filters="+url.QueryEscape(string(filters)), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := dockerClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query docker: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query docker: unexpected status %s", resp.Status)
+	}
+
+	var containers []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return "", fmt.Errorf("failed to decode docker response: %w", err)
+	}
+	if len(containers) > 0 {
+		return stateUp, nil
+	}
+	return stateDown, nil
+}
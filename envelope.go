@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+)
+
+// currentMessageVersion is the newest message format the service understands.
+// Messages without a "v" field are version 1, the original flat format.
+const currentMessageVersion = 2
+
+// supportedMessageVersions lists every message format version still accepted
+var supportedMessageVersions = []int{1, 2}
+
+// messageEnvelope is the version 2 message format. The action and its target
+// are always named explicitly and every optional field lives under "options",
+// so new options do not collide with action names.
+type messageEnvelope struct {
+	V              int            `json:"v"`
+	Action         string         `json:"action"`
+	Repo           Target         `json:"repo,omitempty"`
+	Job            string         `json:"job,omitempty"`
+	CorrelationID  string         `json:"correlationId,omitempty"`
+	IdempotencyKey string         `json:"idempotencyKey,omitempty"`
+	Options        MessageOptions `json:"options"`
+}
+
+// decodeMessage parses a JSON message of any supported version
+func decodeMessage(data []byte) (RedisMessage, error) {
+	var probe struct {
+		V int `json:"v"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return RedisMessage{}, err
+	}
+
+	switch probe.V {
+	case 0, 1:
+		var msg RedisMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return RedisMessage{}, err
+		}
+		return msg, nil
+	case 2:
+		var env messageEnvelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return RedisMessage{}, err
+		}
+		return env.message()
+	}
+	return RedisMessage{}, fmt.Errorf("unsupported message version %d (supported versions: %v)", probe.V, supportedMessageVersions)
+}
+
+// message converts a version 2 envelope to the internal message form
+func (env messageEnvelope) message() (RedisMessage, error) {
+	msg := RedisMessage{CorrelationID: env.CorrelationID, IdempotencyKey: env.IdempotencyKey, MessageOptions: env.Options}
+	switch env.Action {
+	case "":
+		return RedisMessage{}, fmt.Errorf("version 2 messages must contain an 'action'")
+	case "cancel":
+		if env.Job == "" {
+			return RedisMessage{}, fmt.Errorf("cancel messages must contain a 'job'")
+		}
+		msg.Cancel = env.Job
+	case "status":
+		if env.Repo == "" {
+			return RedisMessage{}, fmt.Errorf("status messages must contain a 'repo'")
+		}
+		msg.Status = string(env.Repo)
+	default:
+		if env.Repo == "" {
+			return RedisMessage{}, fmt.Errorf("%s messages must contain a 'repo'", env.Action)
+		}
+		msg.Action = env.Action
+		msg.Repo = env.Repo
+	}
+	return msg, nil
+}
+
+// handleMessageVersions handles GET /messages/versions so producers can
+// discover which message formats this instance accepts
+func handleMessageVersions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openapi.MessageVersions{
+		Current:   currentMessageVersion,
+		Supported: slices.Clone(supportedMessageVersions),
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event types published to watchers
+const (
+	eventReceived   = "received"
+	eventResolved   = "resolved"
+	eventDispatched = "dispatched"
+	eventResult     = "result"
+	eventState      = "state"
+	eventAlert      = "alert"
+)
+
+// Event describes something that happened to a project, for clients
+// watching the service in real time
+type Event struct {
+	Type          string    `json:"type"`
+	Repo          string    `json:"repo,omitempty"`
+	Action        string    `json:"action,omitempty"`
+	Target        string    `json:"target,omitempty"`
+	Source        string    `json:"source,omitempty"`
+	Repos         []string  `json:"repos,omitempty"`
+	State         string    `json:"state,omitempty"`
+	PreviousState string    `json:"previousState,omitempty"`
+	Alert         string    `json:"alert,omitempty"`
+	Message       string    `json:"message,omitempty"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// concerns reports whether the event relates to repo
+func (e Event) concerns(repo string) bool {
+	return e.Repo == repo || e.Target == repo || slices.Contains(e.Repos, repo)
+}
+
+// eventBuffer is how many events a watcher may fall behind by before further
+// events are dropped for it
+const eventBuffer = 64
+
+var (
+	eventsMu         sync.Mutex
+	eventSubscribers = make(map[chan Event]struct{})
+)
+
+// publishEvent delivers an event to every watcher. It never blocks: a watcher
+// that is not keeping up misses the event.
+func publishEvent(e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	for ch := range eventSubscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// subscribeEvents registers a watcher. The returned function unsubscribes it.
+func subscribeEvents() (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+	eventsMu.Lock()
+	eventSubscribers[ch] = struct{}{}
+	eventsMu.Unlock()
+
+	return ch, func() {
+		eventsMu.Lock()
+		delete(eventSubscribers, ch)
+		eventsMu.Unlock()
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Executors carry out a project's actions. Poppit, the default, is sent a
+// notification on the target queue and runs the commands itself; the others
+// are handled by the service.
+const (
+	executorPoppit     = "poppit"
+	executorWebhook    = "webhook"
+	executorLocal      = "local"
+	executorSSH        = "ssh"
+	executorDocker     = "docker"
+	executorKubernetes = "kubernetes"
+)
+
+// executor returns the executor that carries out the project's actions
+func (p Project) executor() string {
+	if p.Executor != "" {
+		return p.Executor
+	}
+	return defaultExecutor
+}
+
+// validateExecutor checks the project's executor and its settings
+func validateExecutor(p Project) []error {
+	switch p.executor() {
+	case executorPoppit:
+		return nil
+	case executorWebhook:
+		return validateWebhookExecutor(p)
+	case executorLocal:
+		return validateLocalExecutor(p)
+	case executorSSH:
+		return validateSSHExecutor(p)
+	case executorDocker:
+		return validateDockerExecutor(p)
+	case executorKubernetes:
+		return validateKubernetesExecutor(p)
+	}
+	return []error{fmt.Errorf("project %s: unknown executor %q (expected %s, %s, %s, %s, %s, or %s)", p.Repo, p.executor(), executorPoppit, executorWebhook, executorLocal, executorSSH, executorDocker, executorKubernetes)}
+}
+
+// runsCommands reports whether the project's executor runs the configured
+// commands. The others carry out up, down, and restart themselves.
+func (p Project) runsCommands() bool {
+	switch p.executor() {
+	case executorDocker, executorKubernetes:
+		return false
+	}
+	return true
+}
+
+// canRestart reports whether the project supports the restart action
+func (p Project) canRestart() bool {
+	return len(p.RestartCommands) > 0 || !p.runsCommands()
+}
+
+// deliverAction hands the notification for an action to the project's
+// executor, returning a description of where it went for the logs. Executors
+// that run the action in the service also return a function that starts it
+// in the background, to be called once the action is recorded as dispatched.
+func deliverAction(ctx context.Context, rdb *redis.Client, project Project, queue string, n PoppitNotification) (string, func(), error) {
+	switch project.executor() {
+	case executorWebhook:
+		url := project.webhookURL()
+		return "webhook " + url, nil, executeWebhook(ctx, project, url, n)
+	case executorLocal:
+		return "local commands", startExecution(func() { executeLocal(ctx, rdb, project, n) }), nil
+	case executorSSH:
+		return "commands over ssh to " + project.sshAddr(), startExecution(func() { executeSSH(ctx, rdb, project, n) }), nil
+	case executorDocker:
+		return "docker containers of " + project.dockerSelection(), startExecution(func() { executeDocker(ctx, rdb, project, n) }), nil
+	case executorKubernetes:
+		return "kubernetes " + project.kubernetesWorkloadName(), startExecution(func() { executeKubernetes(ctx, rdb, project, n) }), nil
+	}
+
+	data, err := formatNotification(queue, n)
+	if err != nil {
+		return "", nil, err
+	}
+	return "notification to " + queue, nil, sendNotification(ctx, rdb, n.CorrelationID, queue, data)
+}
+
+// executions tracks the actions being run by the service, so that shutdown
+// can wait for them
+var executions sync.WaitGroup
+
+// startExecution returns a function that runs an action in the background and
+// tracks it in executions
+func startExecution(run func()) func() {
+	return func() {
+		executions.Add(1)
+		go func() {
+			defer executions.Done()
+			run()
+		}()
+	}
+}
+
+// waitExecutions waits for the actions being run by the service to finish,
+// reporting false if they are still running when ctx is done
+func waitExecutions(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		executions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// reportExecution records the outcome of an action run by the service, in
+// the same way as a result reported by Poppit
+func reportExecution(ctx context.Context, rdb *redis.Client, executor string, n PoppitNotification, err error) {
+	result := PoppitResult{Repo: n.Repo, Type: n.Type, CorrelationID: n.CorrelationID}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	recordResult(ctx, rdb, executor, result)
+}
+
+// executionLocks keeps the actions of a project run by the service from
+// running at the same time
+var executionLocks sync.Map
+
+// lockExecution waits for other actions of the project run by the service to
+// finish, returning the function that lets the next one run
+func lockExecution(repo string) func() {
+	lock, _ := executionLocks.LoadOrStore(repo, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// executeCommands runs the commands of an action one after another with run,
+// stopping at the first that fails, and reports the outcome. All the commands
+// share the timeout. Their output is logged line by line and the end of it is
+// kept with a failure.
+func executeCommands(ctx context.Context, rdb *redis.Client, executor string, n PoppitNotification, timeout time.Duration, run func(ctx context.Context, command string) ([]byte, error)) {
+	ctx = context.WithoutCancel(ctx)
+	defer lockExecution(n.Repo)()
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var err error
+	for _, command := range n.Commands {
+		log.Printf("[%s] Running %q for %s in %s (%s)", n.CorrelationID, command, n.Repo, n.Dir, executor)
+		var output []byte
+		output, err = run(runCtx, command)
+		output = redact(output)
+		for line := range strings.Lines(string(output)) {
+			log.Printf("[%s] %s: %s", n.CorrelationID, n.Repo, strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			if runCtx.Err() != nil {
+				err = fmt.Errorf("timed out: %w", runCtx.Err())
+			}
+			if tail := outputTail(output); tail != "" {
+				err = fmt.Errorf("%q failed: %w: %s", command, err, tail)
+			} else {
+				err = fmt.Errorf("%q failed: %w", command, err)
+			}
+			break
+		}
+	}
+	reportExecution(ctx, rdb, executor, n, err)
+}
+
+// outputTail returns the end of a command's output, up to
+// COMMAND_OUTPUT_LIMIT bytes
+func outputTail(output []byte) string {
+	output = bytes.TrimSpace(output)
+	if len(output) > commandOutputLimit {
+		output = append([]byte("..."), output[len(output)-commandOutputLimit:]...)
+	}
+	return string(output)
+}
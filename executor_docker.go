@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DockerExecutor configures carrying out a project's actions through the
+// Docker Engine API on DOCKER_SOCKET instead of running commands. Containers
+// are chosen by name, by label, or by Compose project, in that order.
+type DockerExecutor struct {
+	// Containers are the names or IDs of the project's containers
+	Containers []string `json:"containers,omitempty" yaml:"containers,omitempty" toml:"containers,omitempty"`
+	// Labels select the containers that carry every one of them, each as
+	// key or key=value
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty" toml:"labels,omitempty"`
+	// ComposeProject selects the containers of a Compose project, by default
+	// the one named after the project's directory
+	ComposeProject string `json:"composeProject,omitempty" yaml:"composeProject,omitempty" toml:"composeProject,omitempty"`
+	// StopTimeout is how long containers get to stop before they are
+	// killed, overriding their own setting
+	StopTimeout string `json:"stopTimeout,omitempty" yaml:"stopTimeout,omitempty" toml:"stopTimeout,omitempty"`
+}
+
+// dockerContainer is a container as listed or inspected by the Docker API
+type dockerContainer struct {
+	ID    string
+	Name  string
+	State string
+}
+
+// dockerExecutorClient shares the DOCKER_SOCKET transport of dockerClient,
+// but leaves timeouts to DOCKER_EXECUTOR_TIMEOUT since stopping containers
+// can take a while
+var dockerExecutorClient = &http.Client{Transport: dockerClient.Transport}
+
+func (p Project) dockerExecutor() DockerExecutor {
+	if p.Docker != nil {
+		return *p.Docker
+	}
+	return DockerExecutor{}
+}
+
+// dockerLabels returns the label filters that select the project's
+// containers, unless they are chosen by name
+func (p Project) dockerLabels() []string {
+	d := p.dockerExecutor()
+	if len(d.Labels) > 0 {
+		return d.Labels
+	}
+	if d.ComposeProject != "" {
+		return []string{"com.docker.compose.project=" + d.ComposeProject}
+	}
+	return []string{"com.docker.compose.project=" + composeProjectName(p)}
+}
+
+// dockerSelection describes the project's containers for the logs
+func (p Project) dockerSelection() string {
+	if d := p.dockerExecutor(); len(d.Containers) > 0 {
+		return strings.Join(d.Containers, ", ")
+	}
+	return strings.Join(p.dockerLabels(), ", ")
+}
+
+// validateDockerExecutor checks that the project's containers can be found
+func validateDockerExecutor(p Project) []error {
+	var errs []error
+	d := p.dockerExecutor()
+	if len(d.Containers) == 0 && len(d.Labels) == 0 && d.ComposeProject == "" && composeProjectName(p) == "" {
+		errs = append(errs, fmt.Errorf("project %s: the docker executor needs docker.containers, docker.labels, docker.composeProject, or dir", p.Repo))
+	}
+	if slices.Contains(d.Containers, "") || slices.Contains(d.Labels, "") {
+		errs = append(errs, fmt.Errorf("project %s: docker containers and labels must not be empty", p.Repo))
+	}
+	if d.StopTimeout != "" {
+		if t, err := time.ParseDuration(d.StopTimeout); err != nil || t < 0 {
+			errs = append(errs, fmt.Errorf("project %s: invalid docker stopTimeout %q", p.Repo, d.StopTimeout))
+		}
+	}
+	if len(p.Actions) > 0 {
+		errs = append(errs, fmt.Errorf("project %s: the docker executor only supports up, down, and restart, not custom actions", p.Repo))
+	}
+	return errs
+}
+
+// dockerRequest calls the Docker API, decoding the JSON reply into out if it
+// is not nil. Errors carry the message the API gives.
+func dockerRequest(ctx context.Context, method, path string, out any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://docker"+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := dockerExecutorClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach docker: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		return resp.StatusCode, fmt.Errorf("docker answered %s: %s", resp.Status, apiErr.Message)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode docker response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// dockerContainers returns the project's containers, running or not
+func dockerContainers(ctx context.Context, p Project) ([]dockerContainer, error) {
+	var containers []dockerContainer
+	if names := p.dockerExecutor().Containers; len(names) > 0 {
+		for _, name := range names {
+			var inspected struct {
+				ID    string `json:"Id"`
+				Name  string
+				State struct{ Status string }
+			}
+			if _, err := dockerRequest(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", &inspected); err != nil {
+				return nil, fmt.Errorf("container %s: %w", name, err)
+			}
+			containers = append(containers, dockerContainer{ID: inspected.ID, Name: strings.TrimPrefix(inspected.Name, "/"), State: inspected.State.Status})
+		}
+		return containers, nil
+	}
+
+	filters, err := json.Marshal(map[string][]string{"label": p.dockerLabels()})
+	if err != nil {
+		return nil, err
+	}
+	var listed []struct {
+		ID    string `json:"Id"`
+		Names []string
+		State string
+	}
+	if _, err := dockerRequest(ctx, http.MethodGet, "/containers/json?all=true&filters="+url.QueryEscape(string(filters)), &listed); err != nil {
+		return nil, err
+	}
+	if len(listed) == 0 {
+		return nil, fmt.Errorf("no containers match %s", strings.Join(p.dockerLabels(), ", "))
+	}
+	for _, c := range listed {
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		containers = append(containers, dockerContainer{ID: c.ID, Name: name, State: c.State})
+	}
+	slices.SortFunc(containers, func(a, b dockerContainer) int { return strings.Compare(a.Name, b.Name) })
+	return containers, nil
+}
+
+// runDockerAction starts, stops, or restarts the project's containers, then
+// checks that every one of them ended up running or stopped as it should
+func runDockerAction(ctx context.Context, p Project, action string) error {
+	containers, err := dockerContainers(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	operation := map[string]string{"up": "start", "down": "stop", "restart": "restart"}[action]
+	query := ""
+	if stop := p.dockerExecutor().StopTimeout; stop != "" && action != "up" {
+		t, _ := time.ParseDuration(stop)
+		query = "?t=" + strconv.Itoa(int(t.Seconds()))
+	}
+	var errs []error
+	for _, c := range containers {
+		// 304 means the container was already started or stopped
+		if _, err := dockerRequest(ctx, http.MethodPost, "/containers/"+c.ID+"/"+operation+query, nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to %s %s: %w", operation, c.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	containers, err = dockerContainers(ctx, p)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if running := c.State == "running"; running == (action == "down") {
+			errs = append(errs, fmt.Errorf("container %s is %s after %s", c.Name, c.State, operation))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// executeDocker carries out an action on the project's containers and
+// reports the outcome
+func executeDocker(ctx context.Context, rdb *redis.Client, project Project, n PoppitNotification) {
+	ctx = context.WithoutCancel(ctx)
+	defer lockExecution(n.Repo)()
+
+	runCtx, cancel := context.WithTimeout(ctx, dockerExecutorTimeout)
+	defer cancel()
+	err := runDockerAction(runCtx, project, strings.TrimPrefix(n.Type, "service-"))
+	if err != nil && runCtx.Err() != nil {
+		err = fmt.Errorf("timed out: %w", err)
+	}
+	reportExecution(ctx, rdb, executorDocker, n, err)
+}
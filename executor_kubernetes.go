@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Kinds of workload the kubernetes executor scales
+const (
+	kindDeployment  = "deployment"
+	kindStatefulSet = "statefulset"
+)
+
+// kubernetesPollInterval is how often the kubernetes executor checks whether
+// a workload has finished scaling or rolling out
+const kubernetesPollInterval = 2 * time.Second
+
+// KubernetesExecutor configures carrying out a project's actions by scaling
+// or restarting a Deployment or StatefulSet instead of running commands
+type KubernetesExecutor struct {
+	// Namespace holds the workload, overriding KUBERNETES_NAMESPACE
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty" toml:"namespace,omitempty"`
+	// Kind is deployment (the default) or statefulset
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty" toml:"kind,omitempty"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+	// Replicas is what up scales the workload to (default: 1)
+	Replicas int `json:"replicas,omitempty" yaml:"replicas,omitempty" toml:"replicas,omitempty"`
+}
+
+// kubernetesWorkload is the part of a Deployment or StatefulSet that tells
+// whether it has finished scaling or rolling out
+type kubernetesWorkload struct {
+	Metadata struct {
+		Generation int64 `json:"generation"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas int `json:"replicas"`
+	} `json:"spec"`
+	Status struct {
+		ObservedGeneration int64 `json:"observedGeneration"`
+		Replicas           int   `json:"replicas"`
+		ReadyReplicas      int   `json:"readyReplicas"`
+		UpdatedReplicas    int   `json:"updatedReplicas"`
+	} `json:"status"`
+}
+
+// kubernetesClient trusts the cluster's CA from KUBERNETES_CA_FILE, or the
+// system roots if there is none
+var kubernetesClient = sync.OnceValues(func() (*http.Client, error) {
+	pem, err := os.ReadFile(kubernetesCAFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return &http.Client{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubernetes CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in kubernetes CA file %s", kubernetesCAFile)
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}, nil
+})
+
+func (p Project) kubernetesExecutor() KubernetesExecutor {
+	k := KubernetesExecutor{}
+	if p.Kubernetes != nil {
+		k = *p.Kubernetes
+	}
+	if k.Namespace == "" {
+		k.Namespace = kubernetesNamespace
+	}
+	if k.Kind == "" {
+		k.Kind = kindDeployment
+	}
+	if k.Replicas == 0 {
+		k.Replicas = 1
+	}
+	return k
+}
+
+// kubernetesPath returns the API path of the project's workload
+func (p Project) kubernetesPath() string {
+	k := p.kubernetesExecutor()
+	return "/apis/apps/v1/namespaces/" + url.PathEscape(k.Namespace) + "/" + k.Kind + "s/" + url.PathEscape(k.Name)
+}
+
+// kubernetesWorkloadName describes the project's workload for the logs
+func (p Project) kubernetesWorkloadName() string {
+	k := p.kubernetesExecutor()
+	return k.Kind + " " + k.Namespace + "/" + k.Name
+}
+
+// validateKubernetesExecutor checks the project's workload settings
+func validateKubernetesExecutor(p Project) []error {
+	var errs []error
+	k := p.kubernetesExecutor()
+	if k.Name == "" {
+		errs = append(errs, fmt.Errorf("project %s: the kubernetes executor needs kubernetes.name", p.Repo))
+	}
+	if k.Kind != kindDeployment && k.Kind != kindStatefulSet {
+		errs = append(errs, fmt.Errorf("project %s: invalid kubernetes kind %q (expected %s or %s)", p.Repo, k.Kind, kindDeployment, kindStatefulSet))
+	}
+	if k.Replicas < 0 {
+		errs = append(errs, fmt.Errorf("project %s: kubernetes replicas must not be negative", p.Repo))
+	}
+	if len(p.Actions) > 0 {
+		errs = append(errs, fmt.Errorf("project %s: the kubernetes executor only supports up, down, and restart, not custom actions", p.Repo))
+	}
+	return errs
+}
+
+// kubernetesRequest calls the Kubernetes API with the service account token
+// in KUBERNETES_TOKEN_FILE, decoding the JSON reply into out if it is not
+// nil. Errors carry the message the API gives.
+func kubernetesRequest(ctx context.Context, method, path, contentType string, body []byte, out any) error {
+	client, err := kubernetesClient()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(kubernetesAPIURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// The token is read for every request, since the kubelet rotates it
+	if token, err := os.ReadFile(kubernetesTokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach kubernetes: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("kubernetes answered %s: %s", resp.Status, status.Message)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode kubernetes response: %w", err)
+		}
+	}
+	return nil
+}
+
+// runKubernetesAction scales the project's workload to its replicas for up
+// or to zero for down, or restarts its rollout, then waits until the change
+// has been rolled out
+func runKubernetesAction(ctx context.Context, p Project, action string) error {
+	k := p.kubernetesExecutor()
+	path := p.kubernetesPath()
+	operation := "scale"
+	var err error
+	switch action {
+	case "up", "down":
+		replicas := k.Replicas
+		if action == "down" {
+			replicas = 0
+		}
+		err = kubernetesRequest(ctx, http.MethodPatch, path+"/scale", "application/merge-patch+json", fmt.Appendf(nil, `{"spec":{"replicas":%d}}`, replicas), nil)
+	case "restart":
+		operation = "restart"
+		// The same annotation kubectl rollout restart sets, which replaces
+		// every pod
+		patch := fmt.Appendf(nil, `{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`, time.Now().UTC().Format(time.RFC3339))
+		err = kubernetesRequest(ctx, http.MethodPatch, path, "application/strategic-merge-patch+json", patch, nil)
+	default:
+		err = fmt.Errorf("unsupported action %s", action)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", operation, p.kubernetesWorkloadName(), err)
+	}
+
+	for {
+		var w kubernetesWorkload
+		if err := kubernetesRequest(ctx, http.MethodGet, path, "", nil, &w); err != nil {
+			return err
+		}
+		rolledOut := w.Status.ObservedGeneration >= w.Metadata.Generation
+		if action == "down" {
+			rolledOut = rolledOut && w.Status.Replicas == 0
+		} else {
+			rolledOut = rolledOut && w.Status.Replicas == w.Spec.Replicas && w.Status.UpdatedReplicas == w.Spec.Replicas && w.Status.ReadyReplicas == w.Spec.Replicas
+		}
+		if rolledOut {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not finish rolling out: %d of %d replicas ready, %d updated, %d running", p.kubernetesWorkloadName(), w.Status.ReadyReplicas, w.Spec.Replicas, w.Status.UpdatedReplicas, w.Status.Replicas)
+		case <-time.After(kubernetesPollInterval):
+		}
+	}
+}
+
+// executeKubernetes carries out an action on the project's workload and
+// reports the outcome
+func executeKubernetes(ctx context.Context, rdb *redis.Client, project Project, n PoppitNotification) {
+	ctx = context.WithoutCancel(ctx)
+	defer lockExecution(n.Repo)()
+
+	runCtx, cancel := context.WithTimeout(ctx, kubernetesTimeout)
+	defer cancel()
+	err := runKubernetesAction(runCtx, project, strings.TrimPrefix(n.Type, "service-"))
+	reportExecution(ctx, rdb, executorKubernetes, n, err)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LocalExecutor configures running a project's commands on the service's
+// own host instead of through Poppit
+type LocalExecutor struct {
+	// Shell runs each command as shell -c command, overriding
+	// LOCAL_EXECUTOR_SHELL
+	Shell string `json:"shell,omitempty" yaml:"shell,omitempty" toml:"shell,omitempty"`
+	// Timeout bounds all the commands of an action, overriding
+	// LOCAL_EXECUTOR_TIMEOUT
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+}
+
+func (p Project) localShell() string {
+	if p.Local != nil && p.Local.Shell != "" {
+		return p.Local.Shell
+	}
+	return localExecutorShell
+}
+
+func (p Project) localTimeout() time.Duration {
+	if p.Local != nil {
+		if d, err := time.ParseDuration(p.Local.Timeout); err == nil {
+			return d
+		}
+	}
+	return localExecutorTimeout
+}
+
+// validateLocalExecutor checks the project's local executor settings
+func validateLocalExecutor(p Project) []error {
+	if p.Local != nil && p.Local.Timeout != "" {
+		if d, err := time.ParseDuration(p.Local.Timeout); err != nil || d <= 0 {
+			return []error{fmt.Errorf("project %s: invalid local timeout %q", p.Repo, p.Local.Timeout)}
+		}
+	}
+	return nil
+}
+
+// executeLocal runs the commands of an action on the service's own host
+func executeLocal(ctx context.Context, rdb *redis.Client, project Project, n PoppitNotification) {
+	// Commands do not inherit the service's environment, which holds its
+	// credentials; only what a shell needs is passed on, with the project's
+	// env. Env must not be nil, or it would inherit everything.
+	env := []string{}
+	for _, name := range []string{"PATH", "HOME", "LANG"} {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	for k, v := range n.Env {
+		env = append(env, k+"="+v)
+	}
+	executeCommands(ctx, rdb, executorLocal, n, project.localTimeout(), func(ctx context.Context, command string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, project.localShell(), "-c", command)
+		cmd.Dir = n.Dir
+		cmd.Env = env
+		// Run the command in its own process group, so that a timeout also
+		// kills whatever the shell started
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+		cmd.WaitDelay = 5 * time.Second
+		return cmd.CombinedOutput()
+	})
+}
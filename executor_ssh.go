@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHExecutor configures running a project's commands on a remote host over
+// SSH instead of through Poppit
+type SSHExecutor struct {
+	// Host is the host to connect to, with an optional port
+	Host string `json:"host,omitempty" yaml:"host,omitempty" toml:"host,omitempty"`
+	// User logs in, overriding SSH_EXECUTOR_USER
+	User string `json:"user,omitempty" yaml:"user,omitempty" toml:"user,omitempty"`
+	// Key is the private key to log in with, either a file or the PEM
+	// contents, overriding SSH_EXECUTOR_KEY
+	Key string `json:"key,omitempty" yaml:"key,omitempty" toml:"key,omitempty"`
+	// Timeout bounds all the commands of an action, overriding
+	// SSH_EXECUTOR_TIMEOUT
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+}
+
+func (p Project) sshAddr() string {
+	if p.SSH == nil {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(p.SSH.Host); err == nil {
+		return p.SSH.Host
+	}
+	return net.JoinHostPort(strings.Trim(p.SSH.Host, "[]"), "22")
+}
+
+func (p Project) sshUser() string {
+	if p.SSH != nil && p.SSH.User != "" {
+		return p.SSH.User
+	}
+	return sshExecutorUser
+}
+
+func (p Project) sshKey() string {
+	if p.SSH != nil && p.SSH.Key != "" {
+		return p.SSH.Key
+	}
+	return sshExecutorKey
+}
+
+func (p Project) sshTimeout() time.Duration {
+	if p.SSH != nil {
+		if d, err := time.ParseDuration(p.SSH.Timeout); err == nil {
+			return d
+		}
+	}
+	return sshExecutorTimeout
+}
+
+// validateSSHExecutor requires a host, user, and key for the project
+func validateSSHExecutor(p Project) []error {
+	var errs []error
+	if p.SSH == nil || p.SSH.Host == "" {
+		errs = append(errs, fmt.Errorf("project %s: the ssh executor needs ssh.host", p.Repo))
+	}
+	if p.sshUser() == "" {
+		errs = append(errs, fmt.Errorf("project %s: the ssh executor needs ssh.user or SSH_EXECUTOR_USER", p.Repo))
+	}
+	if p.sshKey() == "" {
+		errs = append(errs, fmt.Errorf("project %s: the ssh executor needs ssh.key or SSH_EXECUTOR_KEY", p.Repo))
+	}
+	if p.SSH != nil && p.SSH.Timeout != "" {
+		if d, err := time.ParseDuration(p.SSH.Timeout); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("project %s: invalid ssh timeout %q", p.Repo, p.SSH.Timeout))
+		}
+	}
+	return errs
+}
+
+// sshSigner reads the private key of the project, given as a file or as its
+// PEM contents
+func sshSigner(key string) (ssh.Signer, error) {
+	pem := []byte(key)
+	if !strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN") {
+		data, err := os.ReadFile(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ssh key: %w", err)
+		}
+		pem = data
+	}
+	signer, err := ssh.ParsePrivateKey(pem)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh key: %w", err)
+	}
+	return signer, nil
+}
+
+// dialSSH connects and logs in to the project's host, checking its key
+// against SSH_KNOWN_HOSTS
+func dialSSH(ctx context.Context, project Project) (*ssh.Client, error) {
+	signer, err := sshSigner(project.sshKey())
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(sshKnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+	config := &ssh.ClientConfig{
+		User:            project.sshUser(),
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeys,
+		Timeout:         sshConnectTimeout,
+	}
+
+	addr := project.sshAddr()
+	conn, err := (&net.Dialer{Timeout: sshConnectTimeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	// Bound the handshake as well as the connection
+	conn.SetDeadline(time.Now().Add(sshConnectTimeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to log in to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sshCommand wraps a command to run in the action's directory with the
+// project's env, which servers do not accept as session variables by default
+func sshCommand(n PoppitNotification, command string) string {
+	var b strings.Builder
+	b.WriteString("cd " + shellQuote(n.Dir) + " || exit 1\n")
+	for _, k := range slices.Sorted(maps.Keys(n.Env)) {
+		b.WriteString("export " + k + "=" + shellQuote(n.Env[k]) + "\n")
+	}
+	b.WriteString(command)
+	return b.String()
+}
+
+// executeSSH runs the commands of an action on the project's host, over one
+// connection
+func executeSSH(ctx context.Context, rdb *redis.Client, project Project, n PoppitNotification) {
+	var client *ssh.Client
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+	executeCommands(ctx, rdb, executorSSH, n, project.sshTimeout(), func(ctx context.Context, command string) ([]byte, error) {
+		if client == nil {
+			c, err := dialSSH(ctx, project)
+			if err != nil {
+				return nil, err
+			}
+			client = c
+		}
+		session, err := client.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ssh session: %w", err)
+		}
+		defer session.Close()
+		// Closing the connection is the only way to stop a command that
+		// ignores signals
+		stop := context.AfterFunc(ctx, func() {
+			session.Signal(ssh.SIGKILL)
+			client.Close()
+		})
+		defer stop()
+		return session.CombinedOutput(sshCommand(n, command))
+	})
+}
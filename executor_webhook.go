@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// WebhookExecutor configures delivery of a project's actions to an HTTPS
+// endpoint instead of Poppit
+type WebhookExecutor struct {
+	// URL receives the actions, overriding WEBHOOK_EXECUTOR_URL
+	URL string `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`
+	// Secret signs the requests, overriding WEBHOOK_EXECUTOR_SECRET
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty" toml:"secret,omitempty"`
+}
+
+var webhookExecutorClient = &http.Client{}
+
+func (p Project) webhookURL() string {
+	if p.Webhook != nil && p.Webhook.URL != "" {
+		return p.Webhook.URL
+	}
+	return webhookExecutorURL
+}
+
+func (p Project) webhookSecret() string {
+	if p.Webhook != nil && p.Webhook.Secret != "" {
+		return p.Webhook.Secret
+	}
+	return webhookExecutorSecret
+}
+
+// validateWebhookExecutor requires an HTTPS URL for the project's actions
+func validateWebhookExecutor(p Project) []error {
+	raw := p.webhookURL()
+	if raw == "" {
+		return []error{fmt.Errorf("project %s: the webhook executor needs webhook.url or WEBHOOK_EXECUTOR_URL", p.Repo)}
+	}
+	if hasReference(raw) {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return []error{fmt.Errorf("project %s: webhook url %q must be an https:// URL", p.Repo, raw)}
+	}
+	return nil
+}
+
+// executeWebhook POSTs the notification to the project's webhook as JSON,
+// signed in the X-Hub-Signature-256 header when a secret is set. Network
+// errors, 429, and 5xx responses are retried; other responses are final.
+func executeWebhook(ctx context.Context, project Project, endpoint string, n PoppitNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	secret := project.webhookSecret()
+
+	return retryWithBackoff(ctx, n.CorrelationID, "deliver "+n.Type+" for "+n.Repo+" to webhook", func() error {
+		reqCtx, cancel := context.WithTimeout(ctx, webhookRequestTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return permanentError{err}
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Correlation-ID", n.CorrelationID)
+		if secret != "" {
+			req.Header.Set(signatureHeader, messageSignature(body, secret))
+		}
+
+		resp, err := webhookExecutorClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			return fmt.Errorf("webhook answered %s: %s", resp.Status, bytes.TrimSpace(reply))
+		}
+		return permanentError{fmt.Errorf("webhook answered %s: %s", resp.Status, bytes.TrimSpace(reply))}
+	})
+}
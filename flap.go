@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	flapMu       sync.Mutex
+	restartTimes = make(map[string][]time.Time)
+)
+
+// recentRestarts drops restarts of repo older than FLAP_WINDOW and returns
+// how many remain. The caller must hold flapMu.
+func recentRestarts(repo string, now time.Time) int {
+	times := restartTimes[repo]
+	kept := times[:0]
+	for _, t := range times {
+		if now.Sub(t) < flapWindow {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		delete(restartTimes, repo)
+	} else {
+		restartTimes[repo] = kept
+	}
+	return len(kept)
+}
+
+// recordRestart counts a restart of repo. A project restarted more than
+// FLAP_MAX_RESTARTS times within FLAP_WINDOW is marked as flapping, which
+// suppresses automatic restarts, and an alert is emitted.
+func recordRestart(repo string) {
+	if flapMaxRestarts <= 0 {
+		return
+	}
+	now := time.Now()
+
+	flapMu.Lock()
+	restartTimes[repo] = append(restartTimes[repo], now)
+	count := recentRestarts(repo, now)
+	flapMu.Unlock()
+
+	if count <= flapMaxRestarts || !setFlapping(repo, true) {
+		return
+	}
+	emitAlert(Alert{
+		Type:    "flapping",
+		Repo:    repo,
+		Message: fmt.Sprintf("restarted %d times within %s; automatic restarts are suppressed", count, flapWindow),
+	})
+}
+
+// isFlapping reports whether automatic restarts of repo are suppressed. A
+// flapping project recovers once its restarts within FLAP_WINDOW are back
+// within FLAP_MAX_RESTARTS.
+func isFlapping(repo string) bool {
+	if !getProjectState(repo).Flapping {
+		return false
+	}
+
+	flapMu.Lock()
+	count := recentRestarts(repo, time.Now())
+	flapMu.Unlock()
+
+	if flapMaxRestarts > 0 && count > flapMaxRestarts {
+		return true
+	}
+	if setFlapping(repo, false) {
+		log.Printf("%s is no longer flapping, automatic restarts resume", repo)
+	}
+	return false
+}
+
+// setFlapping updates the flapping flag of repo, reporting whether it changed
+func setFlapping(repo string, flapping bool) bool {
+	stateMu.Lock()
+	s, ok := projectStates[repo]
+	if !ok {
+		s = ProjectState{Repo: repo, State: stateUnknown}
+	}
+	if s.Flapping == flapping {
+		stateMu.Unlock()
+		return false
+	}
+	s.Flapping = flapping
+	projectStates[repo] = s
+	stateMu.Unlock()
+
+	persistState(s)
+	return true
+}
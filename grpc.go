@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer implements the Tioaoa gRPC service on top of the same processing
+// as the HTTP API
+type grpcServer struct {
+	api.UnimplementedTioaoaServer
+	ctx context.Context
+	rdb *redis.Client
+}
+
+// runGRPCServer serves the gRPC API, with reflection for tools such as
+// grpcurl, on GRPC_PORT until ctx is done. Calls are authenticated like HTTP
+// requests, and served over TLS when the HTTP server is.
+func runGRPCServer(ctx context.Context, rdb *redis.Client) error {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpcUnaryAuth),
+		grpc.StreamInterceptor(grpcStreamAuth),
+	}
+	if tlsEnabled() {
+		tlsConfig, err := serverTLSConfig()
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %w", grpcPort, err)
+	}
+
+	server := grpc.NewServer(opts...)
+	api.RegisterTioaoaServer(server, &grpcServer{ctx: ctx, rdb: rdb})
+	reflection.Register(server)
+
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Printf("gRPC server error: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	log.Printf("Starting gRPC server on port %s", grpcPort)
+	return nil
+}
+
+// grpcAuthenticate identifies the caller of a gRPC method by a verified client
+// certificate or the bearer token in its "authorization" metadata, as
+// authorizeRequest does for HTTP, and adds the identity to ctx. Like GET
+// requests, read-only methods are served without a valid token.
+func grpcAuthenticate(ctx context.Context, method string) (context.Context, error) {
+	identity := grpcClientCertIdentity(ctx)
+	var err error
+	if identity == "" {
+		var authorization string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				authorization = values[0]
+			}
+		}
+		identity, err = authorizeBearer(ctx, authorization)
+	}
+
+	if method == api.Tioaoa_SubmitAction_FullMethodName {
+		if err != nil {
+			addr := ""
+			if p, ok := peer.FromContext(ctx); ok {
+				addr = p.Addr.String()
+			}
+			log.Printf("Rejected gRPC %s from %s: %v", method, addr, err)
+			return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+		}
+		if identity != "" {
+			log.Printf("gRPC %s by %s", method, identity)
+		}
+	}
+	if err != nil || identity == "" {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, identityContextKey{}, identity), nil
+}
+
+// grpcClientCertIdentity returns the common name of the verified client
+// certificate the call was made with, if any
+func grpcClientCertIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+// grpcUnaryAuth authenticates unary calls
+func grpcUnaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := grpcAuthenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// grpcStreamAuth authenticates streaming calls
+func grpcStreamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := grpcAuthenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream is a server stream whose context carries the caller's
+// identity
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// SubmitAction dispatches or schedules an action
+func (s *grpcServer) SubmitAction(ctx context.Context, req *api.SubmitActionRequest) (*api.SubmitActionResponse, error) {
+	action, target := strings.TrimSpace(req.GetAction()), strings.TrimSpace(req.GetTarget())
+	if action == "" || target == "" {
+		return nil, status.Error(codes.InvalidArgument, "action and target are required")
+	}
+	msg, err := parsePlainMessage(action + " " + target)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if _, _, ok := msg.actionTarget(); !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%s is not an action; use GetStatus to query a project", action)
+	}
+
+	msg.CorrelationID = req.GetCorrelationId()
+	msg.Branch = req.GetBranch()
+	msg.Delay = req.GetDelay()
+	msg.At = req.GetAt()
+	msg.IfState = req.GetIfState()
+	msg.Priority = req.GetPriority()
+	msg.TargetQueue = req.GetTargetQueue()
+	msg.Cascade = req.GetCascade()
+	msg.Force = req.GetForce()
+	msg.Confirm = req.GetConfirm()
+	if err := authorizeMessage(contextIdentity(ctx), msg); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	result, err := submitMessage(ctx, s.rdb, msg, "grpc")
+	if errors.Is(err, errInvalidMessage) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &api.SubmitActionResponse{
+		CorrelationId: result.CorrelationID,
+		Message:       result.Message,
+		JobId:         result.JobID,
+		Queued:        result.Queued,
+	}, nil
+}
+
+// GetStatus returns the configuration and tracked state of a project
+func (s *grpcServer) GetStatus(ctx context.Context, req *api.GetStatusRequest) (*api.ProjectStatus, error) {
+	project, exists := lookupProject(req.GetRepo())
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "project %s not found", req.GetRepo())
+	}
+	return s.projectStatus(ctx, project), nil
+}
+
+// ListProjects returns every configured project, sorted by repo
+func (s *grpcServer) ListProjects(ctx context.Context, _ *api.ListProjectsRequest) (*api.ListProjectsResponse, error) {
+	projects := allProjects()
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Repo < projects[j].Repo })
+
+	resp := &api.ListProjectsResponse{}
+	for _, p := range projects {
+		resp.Projects = append(resp.Projects, s.projectStatus(ctx, p))
+	}
+	return resp, nil
+}
+
+// WatchEvents streams events until the client disconnects or the service
+// shuts down
+func (s *grpcServer) WatchEvents(req *api.WatchEventsRequest, stream grpc.ServerStreamingServer[api.Event]) error {
+	events, unsubscribe := subscribeEvents()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case e := <-events:
+			if req.GetRepo() != "" && !e.concerns(req.GetRepo()) {
+				continue
+			}
+			if err := stream.Send(eventProto(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// projectStatus describes a project and its tracked state
+func (s *grpcServer) projectStatus(ctx context.Context, p Project) *api.ProjectStatus {
+	state := getProjectState(p.Repo)
+	ps := &api.ProjectStatus{
+		Repo:        p.Repo,
+		State:       state.State,
+		LastAction:  state.LastAction,
+		Health:      state.Health,
+		HealthError: state.HealthError,
+		Flapping:    state.Flapping,
+		Group:       p.Group,
+		Labels:      p.Labels,
+	}
+	if !state.LastActionAt.IsZero() {
+		ps.LastActionAt = timestamppb.New(state.LastActionAt)
+	}
+	if !state.StateChangedAt.IsZero() {
+		ps.StateChangedAt = timestamppb.New(state.StateChangedAt)
+	}
+	if desired, err := desiredState(ctx, s.rdb, p); err == nil {
+		ps.DesiredState = desired
+	}
+	for name := range p.Actions {
+		ps.Actions = append(ps.Actions, name)
+	}
+	sort.Strings(ps.Actions)
+	return ps
+}
+
+// eventProto converts an event to its gRPC form
+func eventProto(e Event) *api.Event {
+	return &api.Event{
+		Type:          e.Type,
+		Repo:          e.Repo,
+		Action:        e.Action,
+		Target:        e.Target,
+		Source:        e.Source,
+		Repos:         e.Repos,
+		State:         e.State,
+		PreviousState: e.PreviousState,
+		Alert:         e.Alert,
+		Message:       e.Message,
+		CorrelationId: e.CorrelationID,
+		At:            timestamppb.New(e.At),
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// HealthCheck describes how to probe whether a project's service is healthy.
+// Exactly one of HTTP, TCP, or Command is set.
+type HealthCheck struct {
+	// HTTP is a URL that must answer with a 2xx or 3xx status
+	HTTP string `json:"http,omitempty" yaml:"http,omitempty" toml:"http,omitempty"`
+	// TCP is a host:port that must accept connections
+	TCP string `json:"tcp,omitempty" yaml:"tcp,omitempty" toml:"tcp,omitempty"`
+	// Command is run with sh -c in the project's dir and must exit with 0
+	Command  string `json:"command,omitempty" yaml:"command,omitempty" toml:"command,omitempty"`
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty" toml:"interval,omitempty"`
+	Timeout  string `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	// RestartAfter is the number of consecutive failures after which the
+	// project is restarted automatically; 0 disables automatic restarts
+	RestartAfter   int    `json:"restartAfter,omitempty" yaml:"restartAfter,omitempty" toml:"restartAfter,omitempty"`
+	MaxRestarts    int    `json:"maxRestarts,omitempty" yaml:"maxRestarts,omitempty" toml:"maxRestarts,omitempty"`
+	RestartBackoff string `json:"restartBackoff,omitempty" yaml:"restartBackoff,omitempty" toml:"restartBackoff,omitempty"`
+}
+
+// Health results recorded in the project state
+const (
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+)
+
+// interval returns how often the check runs
+func (hc HealthCheck) interval() time.Duration {
+	if d, err := time.ParseDuration(hc.Interval); err == nil && d > 0 {
+		return d
+	}
+	return healthCheckInterval
+}
+
+// timeout returns how long a single probe may take
+func (hc HealthCheck) timeout() time.Duration {
+	if d, err := time.ParseDuration(hc.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return healthCheckTimeout
+}
+
+// validateHealthCheck checks that a project's health check has exactly one
+// probe and valid durations
+func validateHealthCheck(p Project) []error {
+	hc := p.HealthCheck
+	if hc == nil {
+		return nil
+	}
+	var errs []error
+	probes := 0
+	for _, probe := range []string{hc.HTTP, hc.TCP, hc.Command} {
+		if probe != "" {
+			probes++
+		}
+	}
+	if probes != 1 {
+		errs = append(errs, fmt.Errorf("project %s: healthCheck must set exactly one of http, tcp, or command", p.Repo))
+	}
+	if hc.RestartAfter < 0 || hc.MaxRestarts < 0 {
+		errs = append(errs, fmt.Errorf("project %s: healthCheck restartAfter and maxRestarts must not be negative", p.Repo))
+	}
+	for _, f := range []struct{ name, value string }{{"interval", hc.Interval}, {"timeout", hc.Timeout}, {"restartBackoff", hc.RestartBackoff}} {
+		if f.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(f.value); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("project %s: invalid healthCheck %s %q", p.Repo, f.name, f.value))
+		}
+	}
+	return errs
+}
+
+var healthHTTPClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// probe runs the project's health check once, returning nil if it is healthy
+func probe(ctx context.Context, p Project) error {
+	hc := p.HealthCheck
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	defer cancel()
+
+	switch {
+	case hc.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := healthHTTPClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	case hc.TCP != "":
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", hc.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case hc.Command != "":
+		cmd := exec.CommandContext(ctx, "sh", "-c", hc.Command)
+		cmd.Dir = p.Dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if len(out) > 0 {
+				return fmt.Errorf("%w: %s", err, trimOutput(out))
+			}
+			return err
+		}
+		return nil
+	}
+	return errors.New("no probe configured")
+}
+
+// trimOutput shortens command output for the state store
+func trimOutput(out []byte) string {
+	const limit = 200
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// recordHealth stores the result of a probe in the state of repo, logging
+// changes between healthy and unhealthy. A healthy result resets the
+// automatic restart count. Failures are not counted while the project is
+// down or stopping, as it is not expected to be healthy then.
+func recordHealth(repo string, probeErr error) ProjectState {
+	now := time.Now().UTC()
+
+	stateMu.Lock()
+	s, ok := projectStates[repo]
+	if !ok {
+		s = ProjectState{Repo: repo, State: stateUnknown}
+	}
+	health := healthHealthy
+	s.HealthError = ""
+	if probeErr != nil {
+		health = healthUnhealthy
+		s.HealthError = probeErr.Error()
+		s.HealthFailures++
+		if s.State == stateDown || s.State == stateStopping {
+			s.HealthFailures = 0
+		}
+	} else {
+		s.HealthFailures = 0
+		s.AutoRestarts = 0
+		s.AutoRestartGaveUp = false
+	}
+	if s.Health != health {
+		if probeErr != nil {
+			log.Printf("Health of %s: %s -> %s (%v)", repo, s.Health, health, probeErr)
+		} else {
+			log.Printf("Health of %s: %s -> %s", repo, s.Health, health)
+		}
+	}
+	s.Health = health
+	s.HealthCheckedAt = now
+	projectStates[repo] = s
+	stateMu.Unlock()
+
+	persistState(s)
+	return s
+}
+
+// runHealthChecks probes every project with a healthCheck on its interval.
+// Each probe runs in its own goroutine so a slow service does not delay the
+// others, and a project is never probed twice at once.
+func runHealthChecks(ctx context.Context, rdb *redis.Client) {
+	var (
+		mu       sync.Mutex
+		inFlight = make(map[string]bool)
+		lastRun  = make(map[string]time.Time)
+	)
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if !isLeader() {
+					continue
+				}
+				for _, p := range allProjects() {
+					if p.HealthCheck == nil {
+						continue
+					}
+					mu.Lock()
+					due := !inFlight[p.Repo] && now.Sub(lastRun[p.Repo]) >= p.HealthCheck.interval()
+					if due {
+						inFlight[p.Repo] = true
+						lastRun[p.Repo] = now
+					}
+					mu.Unlock()
+					if !due {
+						continue
+					}
+
+					go func(p Project) {
+						defer func() {
+							mu.Lock()
+							delete(inFlight, p.Repo)
+							mu.Unlock()
+						}()
+						err := probe(ctx, p)
+						if ctx.Err() != nil {
+							return
+						}
+						s := recordHealth(p.Repo, err)
+						maybeAutoRestart(ctx, rdb, p, s)
+					}(p)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Running project health checks (default interval: %s)", healthCheckInterval)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// HistoryEntry records what became of an action for one project
+type HistoryEntry = openapi.HistoryEntry
+
+// History outcomes
+const (
+	outcomeDispatched     = openapi.HistoryEntryOutcomeDispatched
+	outcomeFailed         = openapi.HistoryEntryOutcomeFailed
+	outcomeDeferred       = openapi.HistoryEntryOutcomeDeferred
+	outcomeCollapsed      = openapi.HistoryEntryOutcomeCollapsed
+	outcomeSkipped        = openapi.HistoryEntryOutcomeSkipped
+	outcomeCompleted      = openapi.HistoryEntryOutcomeCompleted
+	outcomeUnacknowledged = openapi.HistoryEntryOutcomeUnacknowledged
+)
+
+const (
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 500
+)
+
+// historyCursorPattern matches the stream entry IDs used as cursors
+var historyCursorPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// HistoryPage is one page of the action history, newest first. NextCursor is
+// set when older entries may follow.
+type HistoryPage = openapi.HistoryPage
+
+// recordHistory appends an entry to the HISTORY_KEY stream, which is capped
+// at about HISTORY_LIMIT entries. Failures are logged but never block the
+// caller.
+func recordHistory(ctx context.Context, rdb *redis.Client, entry HistoryEntry) {
+	if historyLimit <= 0 {
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[%s] Error recording history: %v", entry.CorrelationID, err)
+		return
+	}
+	// Record the outcome even if the message's context has been cancelled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	err = rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: historyKey,
+		MaxLen: int64(historyLimit),
+		Approx: true,
+		Values: map[string]any{"entry": data},
+	}).Err()
+	if err != nil {
+		log.Printf("[%s] Error recording history: %v", entry.CorrelationID, err)
+	}
+}
+
+// historyFilter selects entries from the action history
+type historyFilter struct {
+	Repo   string
+	Action string
+	Since  time.Time
+}
+
+func (f historyFilter) matches(e HistoryEntry) bool {
+	return (f.Repo == "" || e.Repo == f.Repo) && (f.Action == "" || e.Action == f.Action)
+}
+
+// listHistory returns up to limit entries matching the filter, newest first,
+// starting after the entry with ID cursor when one is given
+func listHistory(ctx context.Context, rdb *redis.Client, filter historyFilter, cursor string, limit int) (HistoryPage, error) {
+	page := HistoryPage{Entries: []HistoryEntry{}}
+	end := "+"
+	if cursor != "" {
+		end = "(" + cursor
+	}
+	start := "-"
+	if !filter.Since.IsZero() {
+		start = strconv.FormatInt(filter.Since.UnixMilli(), 10)
+	}
+
+	for {
+		batch, err := rdb.XRevRangeN(ctx, historyKey, end, start, int64(limit)).Result()
+		if err != nil {
+			return page, fmt.Errorf("failed to read history: %w", err)
+		}
+		for _, m := range batch {
+			end = "(" + m.ID
+			raw, _ := m.Values["entry"].(string)
+			var entry HistoryEntry
+			if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+				log.Printf("Skipping unreadable history entry %s: %v", m.ID, err)
+				continue
+			}
+			if !filter.matches(entry) {
+				continue
+			}
+			entry.ID = m.ID
+			page.Entries = append(page.Entries, entry)
+			if len(page.Entries) == limit {
+				page.NextCursor = m.ID
+				return page, nil
+			}
+		}
+		if len(batch) < limit {
+			return page, nil
+		}
+	}
+}
+
+// parseSince accepts an RFC3339 timestamp or a duration before now, such as 24h
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid since %q: expected an RFC3339 timestamp or a duration", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// handleListHistory handles GET /history
+func handleListHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := historyFilter{Repo: query.Get("repo"), Action: query.Get("action")}
+	if filter.Repo != "" {
+		// Accept aliases and short names for configured projects
+		if project, exists := lookupProject(filter.Repo); exists {
+			filter.Repo = project.Repo
+		}
+	}
+	if s := query.Get("since"); s != "" {
+		since, err := parseSince(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Since = since
+	}
+
+	limit := defaultHistoryPageSize
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxHistoryPageSize {
+			http.Error(w, fmt.Sprintf("Invalid limit %q: expected 1 to %d", s, maxHistoryPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	cursor := query.Get("cursor")
+	if cursor != "" && !historyCursorPattern.MatchString(cursor) {
+		http.Error(w, fmt.Sprintf("Invalid cursor %q", cursor), http.StatusBadRequest)
+		return
+	}
+
+	page, err := listHistory(r.Context(), redisClient, filter, cursor, limit)
+	if err != nil {
+		log.Printf("Error listing history: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"gopkg.in/yaml.v3"
+)
+
+// writeJSON writes v as the JSON body of a response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeSuccess writes the StatusResponse that answers a successful request
+func writeSuccess(w http.ResponseWriter, status int, resp openapi.StatusResponse) {
+	resp.Status = openapi.StatusResponseStatusSuccess
+	writeJSON(w, status, resp)
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
+)
+
+// handleOpenAPI handles GET /openapi.json, serving the OpenAPI document that
+// the request and response types are generated from
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		var doc any
+		if openAPIErr = yaml.Unmarshal(openapi.Spec, &doc); openAPIErr == nil {
+			openAPIJSON, openAPIErr = json.Marshal(doc)
+		}
+	})
+	if openAPIErr != nil {
+		log.Printf("Error converting OpenAPI document: %v", openAPIErr)
+		http.Error(w, "Failed to load OpenAPI document", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIJSON)
+}
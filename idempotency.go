@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyHeader carries the idempotency key of an HTTP submission
+const idempotencyHeader = "Idempotency-Key"
+
+// releaseIdempotencyKeyScript deletes an idempotency key only if the given
+// message still holds it
+var releaseIdempotencyKeyScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// claimIdempotencyKey records msg as the holder of its idempotency key for
+// IDEMPOTENCY_WINDOW. It returns the correlation ID of the earlier message
+// holding the key if there is one, or "" when msg may go ahead because it has
+// no key, is the first with it, or already holds it. Only action messages
+// are checked.
+func claimIdempotencyKey(ctx context.Context, rdb *redis.Client, msg RedisMessage) (string, error) {
+	if _, _, ok := msg.actionTarget(); !ok || msg.IdempotencyKey == "" || idempotencyWindow <= 0 {
+		return "", nil
+	}
+	key := idempotencyKeyPrefix + msg.IdempotencyKey
+	first, err := rdb.SetNX(ctx, key, msg.CorrelationID, idempotencyWindow).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	if first {
+		return "", nil
+	}
+	holder, err := rdb.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		// The key expired in between, so nothing holds it any more
+		return claimIdempotencyKey(ctx, rdb, msg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	if holder == msg.CorrelationID {
+		return "", nil
+	}
+	return holder, nil
+}
+
+// releaseIdempotencyKey frees the idempotency key of a message that failed,
+// so that retrying it is not mistaken for a duplicate
+func releaseIdempotencyKey(ctx context.Context, rdb *redis.Client, msg RedisMessage) {
+	if msg.IdempotencyKey == "" || idempotencyWindow <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := releaseIdempotencyKeyScript.Run(ctx, rdb, []string{idempotencyKeyPrefix + msg.IdempotencyKey}, msg.CorrelationID).Err(); err != nil {
+		log.Printf("[%s] Error releasing idempotency key %q: %v", msg.CorrelationID, msg.IdempotencyKey, err)
+	}
+}
+
+// writeIdempotentReplay answers a submission whose idempotency key was
+// already used, naming the message that used it
+func writeIdempotentReplay(w http.ResponseWriter, msg RedisMessage, original string) {
+	log.Printf("[%s] Not dispatching again: idempotency key %q was used by %s", msg.CorrelationID, msg.IdempotencyKey, original)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.Header().Set("X-Correlation-ID", original)
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: "Already submitted with this idempotency key; not dispatched again", CorrelationID: original})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ActivitySignal tells the idle policy when a project was last used. The
+// signal is a value that changes whenever the project is used, such as a hit
+// counter. Exactly one of HTTP or RedisKey is set.
+type ActivitySignal struct {
+	// HTTP is a URL whose response body changes with activity
+	HTTP string `json:"http,omitempty" yaml:"http,omitempty" toml:"http,omitempty"`
+	// RedisKey is a Redis string key whose value changes with activity
+	RedisKey string `json:"redisKey,omitempty" yaml:"redisKey,omitempty" toml:"redisKey,omitempty"`
+}
+
+// validateIdlePolicy checks a project's idleTimeout and activity signal
+func validateIdlePolicy(p Project) []error {
+	if p.IdleTimeout == "" && p.Activity == nil {
+		return nil
+	}
+	var errs []error
+	if d, err := time.ParseDuration(p.IdleTimeout); err != nil || d <= 0 {
+		errs = append(errs, fmt.Errorf("project %s: invalid idleTimeout %q", p.Repo, p.IdleTimeout))
+	}
+	if p.Activity == nil || (p.Activity.HTTP == "") == (p.Activity.RedisKey == "") {
+		errs = append(errs, fmt.Errorf("project %s: idleTimeout requires an activity signal with exactly one of http or redisKey", p.Repo))
+	}
+	return errs
+}
+
+// readActivity returns the current value of a project's activity signal
+func readActivity(ctx context.Context, rdb *redis.Client, a ActivitySignal) (string, error) {
+	if a.RedisKey != "" {
+		value, err := rdb.Get(ctx, a.RedisKey).Result()
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return value, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.HTTP, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// runIdlePolicy downs projects that have seen no activity for their
+// idleTimeout, and brings them back up when activity is seen again
+func runIdlePolicy(ctx context.Context, rdb *redis.Client) {
+	lastValues := make(map[string]string)
+
+	go func() {
+		ticker := time.NewTicker(idleCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if isLeader() {
+					checkIdleProjects(ctx, rdb, lastValues)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Checking project activity every %s", idleCheckInterval)
+}
+
+func checkIdleProjects(ctx context.Context, rdb *redis.Client, lastValues map[string]string) {
+	for _, p := range allProjects() {
+		timeout, err := time.ParseDuration(p.IdleTimeout)
+		if err != nil || p.Activity == nil {
+			continue
+		}
+
+		value, err := readActivity(ctx, rdb, *p.Activity)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error reading activity of %s: %v", p.Repo, err)
+			}
+			continue
+		}
+		last, seen := lastValues[p.Repo]
+		lastValues[p.Repo] = value
+		now := time.Now().UTC()
+		s := getProjectState(p.Repo)
+
+		switch {
+		case seen && value != last:
+			recordActivity(p.Repo, now)
+			if s.IdleStopped {
+				log.Printf("Activity seen for idle project %s, bringing it up", p.Repo)
+				dispatchIdleAction(ctx, rdb, p.Repo, "up")
+			}
+		case !seen:
+			// Measure idleness from when the service started watching, not
+			// from a stale activity time
+			if s.LastActivityAt.IsZero() {
+				recordActivity(p.Repo, now)
+			}
+		case s.State == stateUp && !s.IdleStopped:
+			since := s.LastActivityAt
+			if s.LastActionAt.After(since) {
+				since = s.LastActionAt
+			}
+			if now.Sub(since) >= timeout {
+				log.Printf("No activity for %s since %s, bringing it down", p.Repo, since.Format(time.RFC3339))
+				dispatchIdleAction(ctx, rdb, p.Repo, "down")
+			}
+		}
+	}
+}
+
+// dispatchIdleAction dispatches an up or down for the idle policy and records
+// whether the project is now down because it was idle
+func dispatchIdleAction(ctx context.Context, rdb *redis.Client, repo, action string) {
+	message, err := json.Marshal(RedisMessage{Action: action, Repo: Target(repo), CorrelationID: "idle-" + newCorrelationID()})
+	if err != nil {
+		log.Printf("Error dispatching %s for idle project %s: %v", action, repo, err)
+		return
+	}
+	if err := processMessage(ctx, rdb, string(message)); err != nil {
+		log.Printf("Error dispatching %s for idle project %s: %v", action, repo, err)
+		return
+	}
+
+	stateMu.Lock()
+	s := projectStates[repo]
+	s.IdleStopped = action == "down"
+	projectStates[repo] = s
+	stateMu.Unlock()
+	persistState(s)
+}
+
+// recordActivity stores the time repo was last seen in use
+func recordActivity(repo string, at time.Time) {
+	stateMu.Lock()
+	s, ok := projectStates[repo]
+	if !ok {
+		s = ProjectState{Repo: repo, State: stateUnknown}
+	}
+	s.LastActivityAt = at
+	projectStates[repo] = s
+	stateMu.Unlock()
+	persistState(s)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envVarPattern matches ${VAR} references, and $${VAR} escapes that produce a
+// literal ${VAR} so commands can still use shell expansion on the Poppit side
+var envVarPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references in s with values from the environment.
+// Names of variables that are not set are appended to missing.
+func expandEnv(s string, missing *[]string) string {
+	return envVarPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		name := match[2 : len(match)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			*missing = append(*missing, name)
+			return match
+		}
+		return value
+	})
+}
+
+// interpolateProject expands environment variable references in the project's
+// directories, commands (including custom actions), target queue, and env
+// values. It fails if any variable is unset.
+func interpolateProject(p Project) (Project, error) {
+	var missing []string
+	p = transformProject(p, func(v string) string {
+		return expandEnv(v, &missing)
+	})
+
+	if len(missing) > 0 {
+		return p, fmt.Errorf("project %s references unset environment variables: %s", p.Repo, strings.Join(missing, ", "))
+	}
+	return p, nil
+}
+
+// checkProjectReferences reports the ${VAR} and secret:// references in the
+// project that could not be resolved, without resolving any of them, so that
+// untrusted configuration can be checked without revealing the values
+func checkProjectReferences(p Project) []error {
+	var missing, unresolved []string
+	transformProject(p, func(v string) string {
+		for _, match := range envVarPattern.FindAllStringSubmatch(v, -1) {
+			if _, ok := os.LookupEnv(match[1]); !ok && !strings.HasPrefix(match[0], "$$") {
+				missing = append(missing, match[1])
+			}
+		}
+		for _, match := range secretPattern.FindAllStringSubmatch(v, -1) {
+			if _, err := lookupSecret(match[1]); err != nil {
+				unresolved = append(unresolved, match[1])
+			}
+		}
+		return v
+	})
+
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("project %s references unset environment variables: %s", p.Repo, strings.Join(missing, ", ")))
+	}
+	if len(unresolved) > 0 {
+		errs = append(errs, fmt.Errorf("project %s references unresolved secrets: %s", p.Repo, strings.Join(unresolved, ", ")))
+	}
+	return errs
+}
+
+// hasReference reports whether v still contains a ${VAR} or secret://
+// reference, whose value is not known when configuration is only checked
+func hasReference(v string) bool {
+	return envVarPattern.MatchString(v) || secretPattern.MatchString(v)
+}
+
+// transformProject applies fn to every value field of the project that may
+// contain references: directories, commands, target queue, and env values
+func transformProject(p Project, fn func(string) string) Project {
+	p.Dir = fn(p.Dir)
+	p.UpDir = fn(p.UpDir)
+	p.DownDir = fn(p.DownDir)
+	p.RestartDir = fn(p.RestartDir)
+	p.UpCommands = transformAll(p.UpCommands, fn)
+	p.DownCommands = transformAll(p.DownCommands, fn)
+	p.RestartCommands = transformAll(p.RestartCommands, fn)
+	if p.Actions != nil {
+		actions := make(map[string][]string, len(p.Actions))
+		for name, commands := range p.Actions {
+			actions[name] = transformAll(commands, fn)
+		}
+		p.Actions = actions
+	}
+	p.TargetQueue = fn(p.TargetQueue)
+	if p.HealthCheck != nil {
+		hc := *p.HealthCheck
+		hc.HTTP = fn(hc.HTTP)
+		hc.TCP = fn(hc.TCP)
+		hc.Command = fn(hc.Command)
+		p.HealthCheck = &hc
+	}
+	if p.Webhook != nil {
+		wh := *p.Webhook
+		wh.URL = fn(wh.URL)
+		wh.Secret = fn(wh.Secret)
+		p.Webhook = &wh
+	}
+	if p.SSH != nil {
+		s := *p.SSH
+		s.Host = fn(s.Host)
+		s.User = fn(s.User)
+		s.Key = fn(s.Key)
+		p.SSH = &s
+	}
+	if p.Activity != nil {
+		a := *p.Activity
+		a.HTTP = fn(a.HTTP)
+		a.RedisKey = fn(a.RedisKey)
+		p.Activity = &a
+	}
+	if p.Env != nil {
+		env := make(map[string]string, len(p.Env))
+		for k, v := range p.Env {
+			env[k] = fn(v)
+		}
+		p.Env = env
+	}
+	return p
+}
+
+func transformAll(values []string, fn func(string) string) []string {
+	if values == nil {
+		return nil
+	}
+	transformed := make([]string, len(values))
+	for i, v := range values {
+		transformed[i] = fn(v)
+	}
+	return transformed
+}
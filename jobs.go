@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// Job tracks an action submitted asynchronously. Its ID is the correlation
+// ID of the message.
+type Job = openapi.Job
+
+// Job states
+const (
+	jobQueued     = openapi.JobStateQueued
+	jobScheduled  = openapi.JobStateScheduled
+	jobDispatched = openapi.JobStateDispatched
+	jobCompleted  = openapi.JobStateCompleted
+	jobFailed     = openapi.JobStateFailed
+)
+
+// asyncRequested reports whether the caller asked for the message to be
+// queued rather than processed before the response, with ?async=true or a
+// Prefer: respond-async header
+func asyncRequested(r *http.Request) bool {
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		return true
+	}
+	for _, pref := range strings.Split(r.Header.Get("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+			return true
+		}
+	}
+	return false
+}
+
+func jobKey(id string) string {
+	return jobKeyPrefix + id
+}
+
+// createJob starts tracking the action of msg as queued
+func createJob(ctx context.Context, rdb *redis.Client, msg RedisMessage) error {
+	action, target, _ := msg.actionTarget()
+	now := time.Now().UTC()
+	data, err := json.Marshal(Job{ID: msg.CorrelationID, State: jobQueued, Action: action, Target: target, CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	if err := rdb.Set(ctx, jobKey(msg.CorrelationID), data, jobTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store job: %w", err)
+	}
+	return nil
+}
+
+// getJob returns the job with the given ID, or redis.Nil if there is none
+func getJob(ctx context.Context, rdb *redis.Client, id string) (Job, error) {
+	var job Job
+	data, err := rdb.Get(ctx, jobKey(id)).Bytes()
+	if err != nil {
+		return job, err
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, fmt.Errorf("invalid job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// updateJob moves the job tracking a message to a new state. Messages that
+// were not submitted asynchronously have no job and are ignored. Failures are
+// logged but never block the caller.
+func updateJob(ctx context.Context, rdb *redis.Client, id string, state openapi.JobState, jobErr error) {
+	if id == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	job, err := getJob(ctx, rdb, id)
+	if errors.Is(err, redis.Nil) {
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] Error updating job: %v", id, err)
+		return
+	}
+
+	job.State = state
+	job.UpdatedAt = time.Now().UTC()
+	job.Error = ""
+	if jobErr != nil {
+		job.Error = jobErr.Error()
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("[%s] Error updating job: %v", id, err)
+		return
+	}
+	if err := rdb.Set(ctx, jobKey(id), data, redis.KeepTTL).Err(); err != nil {
+		log.Printf("[%s] Error updating job: %v", id, err)
+	}
+}
+
+// handleAsyncMessage queues a validated action message for the consumer and
+// answers 202 with the job tracking it in the Location header
+func handleAsyncMessage(w http.ResponseWriter, r *http.Request, msg RedisMessage) {
+	if err := createJob(r.Context(), redisClient, msg); err != nil {
+		releaseIdempotencyKey(r.Context(), redisClient, msg)
+		log.Printf("Error creating job %s: %v", msg.CorrelationID, err)
+		http.Error(w, fmt.Sprintf("Failed to queue message: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := enqueueMessage(r.Context(), redisClient, msg); err != nil {
+		releaseIdempotencyKey(r.Context(), redisClient, msg)
+		log.Printf("Error queueing message %s: %v", msg.CorrelationID, err)
+		http.Error(w, fmt.Sprintf("Failed to queue message: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+msg.CorrelationID)
+	writeSuccess(w, http.StatusAccepted, openapi.StatusResponse{Message: "Message queued", CorrelationID: msg.CorrelationID})
+}
+
+// handleGetJob handles GET /jobs/{id}
+func handleGetJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	job, err := getJob(r.Context(), redisClient, id)
+	if errors.Is(err, redis.Nil) {
+		http.Error(w, fmt.Sprintf("Job %s not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading job %s: %v", id, err)
+		http.Error(w, fmt.Sprintf("Failed to read job: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// runKafkaConsumer consumes commands from KAFKA_TOPIC as a member of the
+// KAFKA_GROUP_ID consumer group. With leader election, only the leader joins
+// the group, so partitions are never assigned to an instance that does not
+// consume them.
+func runKafkaConsumer(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		for ctx.Err() == nil {
+			if !isLeader() {
+				time.Sleep(time.Second)
+				continue
+			}
+			if err := consumeKafka(ctx, rdb); err != nil {
+				log.Printf("Kafka consumer stopped: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}()
+
+	log.Printf("Consuming Kafka topic %s in group %s from %s", kafkaTopic, kafkaGroupID, strings.Join(kafkaBrokers, ","))
+}
+
+// consumeKafka consumes until ctx is done or this instance stops leading.
+// Offsets are committed once a record has been processed, including when it
+// failed in a way that processing it again cannot fix. When a record still
+// fails for a passing reason after KAFKA_MAX_RETRIES attempts, the consumer
+// stops without committing it, so that it is consumed again on reconnecting.
+func consumeKafka(ctx context.Context, rdb *redis.Client) error {
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(kafkaBrokers...),
+		kgo.ConsumerGroup(kafkaGroupID),
+		kgo.ConsumeTopics(kafkaTopic),
+		kgo.DisableAutoCommit(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+	defer client.Close()
+
+	leaderCtx, cancel := whileLeading(ctx)
+	defer cancel()
+
+	for {
+		fetches := client.PollFetches(leaderCtx)
+		if leaderCtx.Err() != nil {
+			return nil
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			log.Printf("Error fetching from Kafka topic %s partition %d: %v", topic, partition, err)
+		})
+		if errors.Is(fetches.Err0(), kgo.ErrClientClosed) {
+			return fetches.Err0()
+		}
+
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			record := iter.Next()
+			if err := processKafkaRecord(leaderCtx, rdb, record); err != nil {
+				return err
+			}
+			if leaderCtx.Err() != nil {
+				return nil
+			}
+			if err := client.CommitRecords(ctx, record); err != nil {
+				log.Printf("Error committing Kafka offset %d on partition %d: %v", record.Offset, record.Partition, err)
+			}
+		}
+	}
+}
+
+// processKafkaRecord processes a record. Failures that may pass are retried
+// with a growing delay, and returned after KAFKA_MAX_RETRIES attempts; other
+// failures are logged, as processing the record again would either fail again
+// or dispatch projects that were dispatched already.
+func processKafkaRecord(ctx context.Context, rdb *redis.Client, record *kgo.Record) error {
+	log.Printf("Received message from Kafka topic %s (partition %d, offset %d): %s", record.Topic, record.Partition, record.Offset, record.Value)
+	source := fmt.Sprintf("kafka:%s/%d", record.Topic, record.Partition)
+	for attempt := 1; ; attempt++ {
+		err := receiveMessage(withSource(ctx, source), rdb, string(record.Value))
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		if !retryableError(err) {
+			log.Printf("Error processing Kafka offset %d on partition %d: %v", record.Offset, record.Partition, err)
+			return nil
+		}
+		if attempt >= kafkaMaxRetries {
+			return fmt.Errorf("failed to process offset %d on partition %d after %d attempts: %w", record.Offset, record.Partition, attempt, err)
+		}
+		log.Printf("Error processing Kafka offset %d on partition %d (attempt %d of %d): %v", record.Offset, record.Partition, attempt, kafkaMaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+}
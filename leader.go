@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// leading is set while this instance holds the leader lock
+var leading atomic.Bool
+
+// leaderRenewedAt is when the lock was last acquired or renewed. It is only
+// used by the election loop.
+var leaderRenewedAt time.Time
+
+// renewLeaderScript extends the lock only if this instance still holds it
+var renewLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaderScript deletes the lock only if this instance holds it
+var releaseLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// isLeader reports whether this instance should consume the source lists and
+// run the background loops. Without LEADER_ELECTION every instance leads.
+func isLeader() bool {
+	return !leaderElection || leading.Load()
+}
+
+// whileLeading returns a context that is cancelled once this instance stops
+// leading, for consumers that must let go of their subscription on failover
+func whileLeading(ctx context.Context) (context.Context, context.CancelFunc) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-leaderCtx.Done():
+				return
+			case <-ticker.C:
+				if !isLeader() {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return leaderCtx, cancel
+}
+
+// defaultInstanceID identifies this instance in the leader lock
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// runLeaderElection competes for the LEADER_KEY lock. The leader renews the
+// lock every third of LEADER_TTL; if it stops doing so, another instance takes
+// over once the lock expires. The lock is released on shutdown so failover is
+// immediate.
+func runLeaderElection(ctx context.Context, rdb *redis.Client) {
+	tryLead(ctx, rdb)
+
+	go func() {
+		ticker := time.NewTicker(leaderTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if leading.Load() {
+					releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					if err := releaseLeaderScript.Run(releaseCtx, rdb, []string{leaderKey}, instanceID).Err(); err != nil {
+						log.Printf("Error releasing leadership: %v", err)
+					}
+					cancel()
+					leading.Store(false)
+				}
+				return
+			case <-ticker.C:
+				tryLead(ctx, rdb)
+			}
+		}
+	}()
+
+	log.Printf("Leader election enabled as %s (key: %s, ttl: %s)", instanceID, leaderKey, leaderTTL)
+}
+
+// tryLead renews the lock when leading, and tries to acquire it otherwise.
+// An error renewing the lock does not end the leadership until LEADER_TTL has
+// passed since the last successful renewal, by when the lock may have expired.
+func tryLead(ctx context.Context, rdb *redis.Client) {
+	// Measured before the request, so leadership ends no later than the lock
+	started := time.Now()
+	if leading.Load() {
+		renewed, err := renewLeaderScript.Run(ctx, rdb, []string{leaderKey}, instanceID, leaderTTL.Milliseconds()).Int()
+		switch {
+		case err == nil && renewed == 1:
+			leaderRenewedAt = started
+		case ctx.Err() != nil:
+		case err == nil:
+			leading.Store(false)
+			log.Printf("Lost leadership: lock %s is no longer held by %s", leaderKey, instanceID)
+		case time.Since(leaderRenewedAt) < leaderTTL:
+			log.Printf("Error renewing leadership, retrying: %v", err)
+		default:
+			leading.Store(false)
+			log.Printf("Lost leadership: lock not renewed within %s: %v", leaderTTL, err)
+		}
+		return
+	}
+
+	acquired, err := rdb.SetNX(ctx, leaderKey, instanceID, leaderTTL).Result()
+	if err == nil && !acquired {
+		// The lock may still be this instance's, e.g. after giving up on
+		// renewing it while Redis was unreachable
+		var renewed int
+		renewed, err = renewLeaderScript.Run(ctx, rdb, []string{leaderKey}, instanceID, leaderTTL.Milliseconds()).Int()
+		acquired = renewed == 1
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Error acquiring leadership: %v", err)
+		}
+		return
+	}
+	if !acquired {
+		return
+	}
+	leaderRenewedAt = started
+
+	// Pick up the state persisted by the previous leader
+	if err := loadStates(ctx); err != nil {
+		log.Printf("Error loading project state: %v", err)
+	}
+	leading.Store(true)
+	log.Printf("Became leader as %s", instanceID)
+}
+
+// handleQueueForLeader queues a message posted to a follower on the source
+// list, where the leader picks it up
+func handleQueueForLeader(w http.ResponseWriter, r *http.Request, body []byte) {
+	list := getSourceList()
+	var correlationID string
+	var msg RedisMessage
+	if !isBatch(body) {
+		var err error
+		if msg, err = decodeMessage(body); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid message: %v", err), http.StatusBadRequest)
+			return
+		}
+		if list, err = priorityList(msg.Priority); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := authorizeMessage(contextIdentity(r.Context()), msg); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if msg.CorrelationID == "" {
+			msg.CorrelationID = newCorrelationID()
+		}
+		msg.RequestID = requestID(r.Context())
+		if msg.IdempotencyKey == "" {
+			msg.IdempotencyKey = r.Header.Get(idempotencyHeader)
+		}
+		if identity := contextIdentity(r.Context()); identity != "" {
+			msg.OnBehalfOf = identity
+		}
+		original, err := claimIdempotencyKey(r.Context(), redisClient, msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if original != "" {
+			writeIdempotentReplay(w, msg, original)
+			return
+		}
+		if body, err = json.Marshal(msg); err != nil {
+			releaseIdempotencyKey(r.Context(), redisClient, msg)
+			http.Error(w, fmt.Sprintf("Failed to queue message: %v", err), http.StatusInternalServerError)
+			return
+		}
+		correlationID = msg.CorrelationID
+		w.Header().Set("X-Correlation-ID", correlationID)
+		if _, _, ok := msg.actionTarget(); ok && asyncRequested(r) {
+			if err := createJob(r.Context(), redisClient, msg); err != nil {
+				releaseIdempotencyKey(r.Context(), redisClient, msg)
+				log.Printf("Error creating job %s: %v", correlationID, err)
+				http.Error(w, fmt.Sprintf("Failed to queue message: %v", err), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Location", "/jobs/"+correlationID)
+		}
+	} else {
+		if err := authorizeBatch(contextIdentity(r.Context()), body); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		body = withOnBehalfOf(body, contextIdentity(r.Context()))
+	}
+
+	// The leader processes it on behalf of the service, held to the caller's
+	// permissions by OnBehalfOf
+	if err := redisClient.RPush(r.Context(), list, signMessage(body)).Err(); err != nil {
+		releaseIdempotencyKey(r.Context(), redisClient, msg)
+		log.Printf("Error queueing message for the leader: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to queue message: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeSuccess(w, http.StatusAccepted, openapi.StatusResponse{Message: fmt.Sprintf("Message queued on %s for the leader", list), CorrelationID: correlationID})
+}
+
+// requireLeader rejects a request that must be handled by the leader, returning
+// false if this instance is not the leader
+func requireLeader(w http.ResponseWriter, r *http.Request) bool {
+	if isLeader() {
+		return true
+	}
+	leader, err := redisClient.Get(r.Context(), leaderKey).Result()
+	if err != nil {
+		leader = "unknown"
+	}
+	http.Error(w, fmt.Sprintf("This instance is not the leader (leader: %s)", leader), http.StatusServiceUnavailable)
+	return false
+}
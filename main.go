@@ -3,68 +3,445 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 )
 
-// Config represents the overall configuration
-type Config struct {
-	Projects []Project `json:"projects"`
+// RedisMessage represents incoming messages from Redis. This is the flat
+// version 1 format; version 2 envelopes are converted to it on decode.
+type RedisMessage struct {
+	Up      Target `json:"up,omitempty"`
+	Down    Target `json:"down,omitempty"`
+	Restart Target `json:"restart,omitempty"`
+	Toggle  Target `json:"toggle,omitempty"`
+	// Cancel removes a pending scheduled or delayed job by its ID
+	Cancel string `json:"cancel,omitempty"`
+	// Status queries a project's state, replying on the ReplyTo list
+	Status string `json:"status,omitempty"`
+	// Action and Repo request a custom project action, e.g. {"action":"migrate","repo":"..."}
+	Action string `json:"action,omitempty"`
+	Repo   Target `json:"repo,omitempty"`
+	// CorrelationID ties the notification and logs back to the original request
+	CorrelationID string `json:"correlationId,omitempty"`
+	// RequestID is the ID of the HTTP request the message was submitted with
+	RequestID string `json:"requestId,omitempty"`
+	// IdempotencyKey stops retries of the message within IDEMPOTENCY_WINDOW
+	// from dispatching its action again
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// OnBehalfOf is the identity the service queued or scheduled the message
+	// for, so that it is held to that identity's permissions when processed
+	OnBehalfOf string `json:"onBehalfOf,omitempty"`
+	MessageOptions
 }
 
-// Project represents a single project configuration
-type Project struct {
-	Repo            string   `json:"repo"`
-	Dir             string   `json:"dir"`
-	UpCommands      []string `json:"upCommands"`
-	DownCommands    []string `json:"downCommands"`
-	RestartCommands []string `json:"restartCommands,omitempty"`
-	TargetQueue     string   `json:"targetQueue,omitempty"`
+// MessageOptions are the optional fields that modify how an action is
+// dispatched. In version 1 messages they sit alongside the action; in
+// version 2 envelopes they are grouped under "options".
+type MessageOptions struct {
+	TargetQueue string `json:"target-queue,omitempty"`
+	ReplyTo     string `json:"replyTo,omitempty"`
+	// At is an optional RFC3339 time at which to dispatch the action
+	At string `json:"at,omitempty"`
+	// Delay is an optional duration (e.g. "10m") to wait before dispatching
+	Delay string `json:"delay,omitempty"`
+	// Priority selects the source list scheduled messages are queued on
+	Priority string `json:"priority,omitempty"`
+	// ExpiresAt is an optional RFC3339 time after which the message is discarded
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	// IfState only dispatches to projects whose tracked state matches
+	IfState string `json:"ifState,omitempty"`
+	// Branch overrides the branch sent to Poppit (default refs/heads/main)
+	Branch string `json:"branch,omitempty"`
+	// ExtraCommands are appended (or prepended) to the configured commands
+	ExtraCommands        []string `json:"extraCommands,omitempty"`
+	PrependExtraCommands bool     `json:"prependExtraCommands,omitempty"`
+	// Confirm must be set to stop every project with {"down":"all"}
+	Confirm bool `json:"confirm,omitempty"`
+	// Cascade also stops running dependents before stopping a project, and
+	// Force stops it even though dependents are running
+	Cascade bool `json:"cascade,omitempty"`
+	Force   bool `json:"force,omitempty"`
 }
 
-// RedisMessage represents incoming messages from Redis
-type RedisMessage struct {
-	Up          string `json:"up,omitempty"`
-	Down        string `json:"down,omitempty"`
-	Restart     string `json:"restart,omitempty"`
-	TargetQueue string `json:"target-queue,omitempty"`
+// actionTarget returns the action requested by the message and its target
+func (msg RedisMessage) actionTarget() (action, target string, ok bool) {
+	switch {
+	case msg.Up != "":
+		return "up", string(msg.Up), true
+	case msg.Down != "":
+		return "down", string(msg.Down), true
+	case msg.Restart != "":
+		return "restart", string(msg.Restart), true
+	case msg.Toggle != "":
+		return "toggle", string(msg.Toggle), true
+	case msg.Action != "" && msg.Repo != "":
+		return msg.Action, string(msg.Repo), true
+	}
+	return "", "", false
 }
 
 // PoppitNotification represents the notification format for Poppit
 type PoppitNotification struct {
-	Repo     string   `json:"repo"`
-	Branch   string   `json:"branch"`
-	Type     string   `json:"type"`
-	Dir      string   `json:"dir"`
-	Commands []string `json:"commands"`
+	Repo     string            `json:"repo"`
+	Branch   string            `json:"branch"`
+	Type     string            `json:"type"`
+	Dir      string            `json:"dir"`
+	Commands []string          `json:"commands"`
+	Env      map[string]string `json:"env,omitempty"`
+	// CorrelationID and RequestID are copied from the message that triggered
+	// the notification
+	CorrelationID string `json:"correlationId,omitempty"`
+	RequestID     string `json:"requestId,omitempty"`
 }
 
 var (
-	redisAddr          string
-	redisPassword      string
-	sourceList         string
-	configFile         string
-	defaultTargetQueue string
-	httpPort           string
-	projects           map[string]Project
-	redisClient        *redis.Client
+	redisAddr             string
+	redisPassword         string
+	redisURL              string
+	sourceLists           []string
+	priorityLevels        []string
+	configFile            string
+	configDir             string
+	defaultTargetQueue    string
+	httpPort              string
+	configWatch           bool
+	maxGlobMatches        int
+	allDispatchInterval   time.Duration
+	scheduleKey           string
+	schedulePollInterval  time.Duration
+	schedulesPausedKey    string
+	allowExtraCommands    bool
+	dedupWindow           time.Duration
+	dedupKeyPrefix        string
+	stateAssumeSuccess    bool
+	stateKey              string
+	stateSettleDelay      time.Duration
+	desiredStateKey       string
+	reconcileInterval     time.Duration
+	reconcileObserver     string
+	dockerSocket          string
+	healthCheckInterval   time.Duration
+	healthCheckTimeout    time.Duration
+	autoRestartRetries    int
+	autoRestartBackoff    time.Duration
+	idleCheckInterval     time.Duration
+	dependencyDelay       time.Duration
+	dependencyTimeout     time.Duration
+	defaultCooldown       time.Duration
+	cooldownMode          string
+	cooldownKeyPrefix     string
+	flapMaxRestarts       int
+	flapWindow            time.Duration
+	alertList             string
+	alertmanagerToken     string
+	oidcIssuer            string
+	oidcAudience          string
+	oidcIdentityClaim     string
+	rateLimit             int
+	rateLimitBurst        int
+	alertmanagerCooldown  time.Duration
+	alertmanagerKeyPrefix string
+	leaderElection        bool
+	leaderKey             string
+	leaderTTL             time.Duration
+	instanceID            string
+	bootUpDelay           time.Duration
+	shutdownDown          string
+	shutdownTimeout       time.Duration
+	sourceStream          string
+	sourceStreamGroup     string
+	streamClaimIdle       time.Duration
+	sourceChannel         string
+	reliableConsumption   bool
+	processingListPrefix  string
+	natsURL               string
+	natsSubject           string
+	kafkaBrokers          []string
+	kafkaTopic            string
+	kafkaGroupID          string
+	kafkaMaxRetries       int
+	mqttBroker            string
+	mqttTopic             string
+	mqttActionTopic       string
+	mqttQoS               int
+	mqttClientID          string
+	mqttUsername          string
+	mqttPassword          string
+	amqpURL               string
+	amqpQueue             string
+	amqpDeadLetterQueue   string
+	amqpMaxRetries        int
+	grpcPort              string
+	wsAllowedOrigins      []string
+	corsAllowedOrigins    []string
+	corsAllowedMethods    []string
+	corsAllowedHeaders    []string
+	corsMaxAge            time.Duration
+	httpSocket            string
+	httpSocketMode        string
+	httpSocketGroup       string
+	httpSocketOnly        bool
+	accessLog             bool
+	uiEnabled             bool
+	tlsCertFile           string
+	tlsKeyFile            string
+	tlsClientCAFile       string
+	tlsClientAuth         string
+	signedMessages        string
+	messageSigningKey     string
+	jobKeyPrefix          string
+	idempotencyWindow     time.Duration
+	idempotencyKeyPrefix  string
+	jobTTL                time.Duration
+	slackSigningSecret    string
+	discordToken          string
+	discordChannels       []string
+	discordPrefix         string
+	targetRedisAddr       string
+	targetRedisPassword   string
+	targetRedisURL        string
+	redisHealthInterval   time.Duration
+	livenessTimeout       time.Duration
+	historyKey            string
+	resultsQueue          string
+	ackTimeout            time.Duration
+	ackPendingKey         string
+	notifyRetryTimeout    time.Duration
+	notifyRetryBackoff    time.Duration
+	notifyRetryMaxBackoff time.Duration
+	outboxKey             string
+	outboxFlushInterval   time.Duration
+	defaultExecutor       string
+	webhookExecutorURL    string
+	webhookExecutorSecret string
+	webhookRequestTimeout time.Duration
+	localExecutorShell    string
+	localExecutorTimeout  time.Duration
+	commandOutputLimit    int
+	sshExecutorUser       string
+	sshExecutorKey        string
+	sshExecutorTimeout    time.Duration
+	sshConnectTimeout     time.Duration
+	sshKnownHosts         string
+	dockerExecutorTimeout time.Duration
+	kubernetesAPIURL      string
+	kubernetesTokenFile   string
+	kubernetesCAFile      string
+	kubernetesNamespace   string
+	kubernetesTimeout     time.Duration
+	historyLimit          int
+	secretsDir            string
+	discoveryRoot         string
+	discoveryDepth        int
+	discoveryTemplate     string
+	discoveryInterval     time.Duration
+	projectsAPIPersist    bool
+	configHistoryKey      string
+	configHistoryLimit    int
+	configURLToken        string
+	configRefresh         time.Duration
+	configSource          string
+	configRedisKey        string
+	configRedisChannel    string
+	configKVPrefix        string
+	consulAddr            string
+	consulToken           string
+	etcdEndpoint          string
+	projects              map[string]Project
+	redisClient           *redis.Client
 )
 
 func init() {
+	// Keep resolved secrets out of the logs
+	log.SetOutput(redactingWriter{w: os.Stderr})
+
+	// Optionally load additional environment variables from a file
+	envFile = os.Getenv("ENV_FILE")
+	if err := loadEnvFile(); err != nil {
+		log.Printf("Failed to load env file: %v", err)
+	}
+
 	// Load configuration from environment variables with defaults
 	redisAddr = getEnv("REDIS_ADDR", "localhost:6379")
 	redisPassword = getEnv("REDIS_PASSWORD", "")
-	sourceList = getEnv("SOURCE_LIST", "service:commands")
-	configFile = getEnv("CONFIG_FILE", "projects.json")
-	defaultTargetQueue = getEnv("TARGET_QUEUE", "poppit:notifications")
+	redisURL = getEnv("REDIS_URL", "")
 	httpPort = getEnv("PORT", "8080")
+	configWatch = getEnv("CONFIG_WATCH", "true") == "true"
+	maxGlobMatches = getEnvInt("MAX_GLOB_MATCHES", 20)
+	allDispatchInterval = getEnvDuration("ALL_DISPATCH_INTERVAL", time.Second)
+	scheduleKey = getEnv("SCHEDULE_KEY", "tioaoa:scheduled")
+	schedulePollInterval = getEnvDuration("SCHEDULE_POLL_INTERVAL", time.Second)
+	schedulesPausedKey = getEnv("SCHEDULES_PAUSED_KEY", "tioaoa:schedules:paused")
+	allowExtraCommands = getEnv("ALLOW_EXTRA_COMMANDS", "false") == "true"
+	dedupWindow = getEnvDuration("DEDUP_WINDOW", 0)
+	dedupKeyPrefix = getEnv("DEDUP_KEY_PREFIX", "tioaoa:dedup:")
+	stateAssumeSuccess = getEnv("STATE_ASSUME_SUCCESS", "true") == "true"
+	stateKey = getEnv("STATE_KEY", "tioaoa:state")
+	stateSettleDelay = getEnvDuration("STATE_SETTLE_DELAY", 0)
+	desiredStateKey = getEnv("DESIRED_STATE_KEY", "tioaoa:desired")
+	reconcileInterval = getEnvDuration("RECONCILE_INTERVAL", 0)
+	reconcileObserver = getEnv("RECONCILE_OBSERVER", "state")
+	dockerSocket = getEnv("DOCKER_SOCKET", "/var/run/docker.sock")
+	healthCheckInterval = getEnvDuration("HEALTH_CHECK_INTERVAL", 30*time.Second)
+	healthCheckTimeout = getEnvDuration("HEALTH_CHECK_TIMEOUT", 5*time.Second)
+	autoRestartRetries = getEnvInt("AUTO_RESTART_MAX_RETRIES", 3)
+	autoRestartBackoff = getEnvDuration("AUTO_RESTART_BACKOFF", 30*time.Second)
+	idleCheckInterval = getEnvDuration("IDLE_CHECK_INTERVAL", time.Minute)
+	dependencyDelay = getEnvDuration("DEPENDENCY_DELAY", 10*time.Second)
+	dependencyTimeout = getEnvDuration("DEPENDENCY_TIMEOUT", 5*time.Minute)
+	defaultCooldown = getEnvDuration("COOLDOWN", 0)
+	cooldownMode = getEnv("COOLDOWN_MODE", cooldownReject)
+	cooldownKeyPrefix = getEnv("COOLDOWN_KEY_PREFIX", "tioaoa:cooldown:")
+	flapMaxRestarts = getEnvInt("FLAP_MAX_RESTARTS", 5)
+	flapWindow = getEnvDuration("FLAP_WINDOW", 10*time.Minute)
+	alertList = getEnv("ALERT_LIST", "tioaoa:alerts")
+	alertmanagerToken = getEnv("ALERTMANAGER_TOKEN", "")
+	oidcIssuer = getEnv("OIDC_ISSUER", "")
+	oidcAudience = getEnv("OIDC_AUDIENCE", "")
+	oidcIdentityClaim = getEnv("OIDC_IDENTITY_CLAIM", "email")
+	rateLimit = getEnvInt("RATE_LIMIT", 0)
+	rateLimitBurst = getEnvInt("RATE_LIMIT_BURST", 10)
+	alertmanagerCooldown = getEnvDuration("ALERTMANAGER_COOLDOWN", 10*time.Minute)
+	alertmanagerKeyPrefix = getEnv("ALERTMANAGER_KEY_PREFIX", "tioaoa:alertmanager:")
+	leaderElection = getEnv("LEADER_ELECTION", "false") == "true"
+	leaderKey = getEnv("LEADER_KEY", "tioaoa:leader")
+	leaderTTL = getEnvDuration("LEADER_TTL", 15*time.Second)
+	instanceID = getEnv("INSTANCE_ID", defaultInstanceID())
+	bootUpDelay = getEnvDuration("BOOT_UP_DELAY", 30*time.Second)
+	shutdownDown = getEnv("SHUTDOWN_DOWN", shutdownDownOff)
+	shutdownTimeout = getEnvDuration("SHUTDOWN_TIMEOUT", 5*time.Minute)
+	sourceStream = getEnv("SOURCE_STREAM", "")
+	sourceStreamGroup = getEnv("SOURCE_STREAM_GROUP", "tioaoa")
+	streamClaimIdle = getEnvDuration("STREAM_CLAIM_IDLE", time.Minute)
+	sourceChannel = getEnv("SOURCE_CHANNEL", "")
+	reliableConsumption = getEnv("RELIABLE_CONSUMPTION", "false") == "true"
+	processingListPrefix = getEnv("PROCESSING_LIST_PREFIX", "tioaoa:processing:")
+	natsURL = getEnv("NATS_URL", "")
+	natsSubject = getEnv("NATS_SUBJECT", "tioaoa.commands")
+	for _, broker := range strings.Split(getEnv("KAFKA_BROKERS", ""), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			kafkaBrokers = append(kafkaBrokers, broker)
+		}
+	}
+	kafkaTopic = getEnv("KAFKA_TOPIC", "tioaoa-commands")
+	kafkaGroupID = getEnv("KAFKA_GROUP_ID", "tioaoa")
+	kafkaMaxRetries = getEnvInt("KAFKA_MAX_RETRIES", 3)
+	mqttBroker = getEnv("MQTT_BROKER", "")
+	mqttTopic = getEnv("MQTT_TOPIC", "tioaoa/commands")
+	mqttActionTopic = strings.TrimSuffix(getEnv("MQTT_ACTION_TOPIC", "tioaoa/actions"), "/")
+	mqttQoS = getEnvInt("MQTT_QOS", 1)
+	mqttClientID = getEnv("MQTT_CLIENT_ID", "tioaoa-"+instanceID)
+	mqttUsername = getEnv("MQTT_USERNAME", "")
+	mqttPassword = getEnv("MQTT_PASSWORD", "")
+	amqpURL = getEnv("AMQP_URL", "")
+	amqpQueue = getEnv("AMQP_QUEUE", "tioaoa.commands")
+	amqpDeadLetterQueue = getEnv("AMQP_DEAD_LETTER_QUEUE", "")
+	amqpMaxRetries = getEnvInt("AMQP_MAX_RETRIES", 3)
+	grpcPort = getEnv("GRPC_PORT", "")
+	httpSocket = getEnv("HTTP_SOCKET", "")
+	httpSocketMode = getEnv("HTTP_SOCKET_MODE", "0660")
+	httpSocketGroup = getEnv("HTTP_SOCKET_GROUP", "")
+	httpSocketOnly = getEnv("HTTP_SOCKET_ONLY", "false") == "true"
+	accessLog = getEnv("ACCESS_LOG", "true") == "true"
+	uiEnabled = getEnv("UI_ENABLED", "true") == "true"
+	tlsCertFile = getEnv("TLS_CERT_FILE", "")
+	tlsKeyFile = getEnv("TLS_KEY_FILE", "")
+	tlsClientCAFile = getEnv("TLS_CLIENT_CA_FILE", "")
+	tlsClientAuth = getEnv("TLS_CLIENT_AUTH", clientAuthRequire)
+	signedMessages = getEnv("SIGNED_MESSAGES", signedMessagesOptional)
+	messageSigningKey = getEnv("MESSAGE_SIGNING_KEY", "")
+	jobKeyPrefix = getEnv("JOB_KEY_PREFIX", "tioaoa:job:")
+	jobTTL = getEnvDuration("JOB_TTL", 24*time.Hour)
+	idempotencyWindow = getEnvDuration("IDEMPOTENCY_WINDOW", 24*time.Hour)
+	idempotencyKeyPrefix = getEnv("IDEMPOTENCY_KEY_PREFIX", "tioaoa:idempotency:")
+	slackSigningSecret = getEnv("SLACK_SIGNING_SECRET", "")
+	discordToken = getEnv("DISCORD_TOKEN", "")
+	for _, channel := range strings.Split(getEnv("DISCORD_CHANNELS", ""), ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			discordChannels = append(discordChannels, channel)
+		}
+	}
+	discordPrefix = getEnv("DISCORD_PREFIX", "!svc")
+	targetRedisAddr = getEnv("TARGET_REDIS_ADDR", "")
+	targetRedisPassword = getEnv("TARGET_REDIS_PASSWORD", "")
+	targetRedisURL = getEnv("TARGET_REDIS_URL", "")
+	redisHealthInterval = getEnvDuration("REDIS_HEALTH_INTERVAL", 30*time.Second)
+	livenessTimeout = getEnvDuration("LIVENESS_TIMEOUT", 10*time.Minute)
+	historyKey = getEnv("HISTORY_KEY", "tioaoa:history")
+	resultsQueue = getEnv("RESULTS_QUEUE", "")
+	ackTimeout = getEnvDuration("ACK_TIMEOUT", 0)
+	ackPendingKey = getEnv("ACK_PENDING_KEY", "tioaoa:acks")
+	notifyRetryTimeout = getEnvDuration("NOTIFY_RETRY_TIMEOUT", 30*time.Second)
+	notifyRetryBackoff = max(getEnvDuration("NOTIFY_RETRY_BACKOFF", 200*time.Millisecond), time.Millisecond)
+	notifyRetryMaxBackoff = max(getEnvDuration("NOTIFY_RETRY_MAX_BACKOFF", 5*time.Second), notifyRetryBackoff)
+	outboxKey = getEnv("OUTBOX_KEY", "tioaoa:outbox")
+	outboxFlushInterval = max(getEnvDuration("OUTBOX_FLUSH_INTERVAL", 30*time.Second), time.Second)
+	defaultExecutor = getEnv("DEFAULT_EXECUTOR", executorPoppit)
+	webhookExecutorURL = getEnv("WEBHOOK_EXECUTOR_URL", "")
+	webhookExecutorSecret = getEnv("WEBHOOK_EXECUTOR_SECRET", "")
+	webhookRequestTimeout = getEnvDuration("WEBHOOK_EXECUTOR_TIMEOUT", 10*time.Second)
+	localExecutorShell = getEnv("LOCAL_EXECUTOR_SHELL", "/bin/sh")
+	localExecutorTimeout = getEnvDuration("LOCAL_EXECUTOR_TIMEOUT", 10*time.Minute)
+	commandOutputLimit = max(getEnvInt("COMMAND_OUTPUT_LIMIT", 4096), 0)
+	sshExecutorUser = getEnv("SSH_EXECUTOR_USER", "")
+	sshExecutorKey = getEnv("SSH_EXECUTOR_KEY", "")
+	sshExecutorTimeout = getEnvDuration("SSH_EXECUTOR_TIMEOUT", 10*time.Minute)
+	sshConnectTimeout = getEnvDuration("SSH_CONNECT_TIMEOUT", 10*time.Second)
+	dockerExecutorTimeout = getEnvDuration("DOCKER_EXECUTOR_TIMEOUT", 2*time.Minute)
+	kubernetesAPIURL = getEnv("KUBERNETES_API_URL", "https://"+net.JoinHostPort(getEnv("KUBERNETES_SERVICE_HOST", "kubernetes.default.svc"), getEnv("KUBERNETES_SERVICE_PORT", "443")))
+	kubernetesTokenFile = getEnv("KUBERNETES_TOKEN_FILE", "/var/run/secrets/kubernetes.io/serviceaccount/token")
+	kubernetesCAFile = getEnv("KUBERNETES_CA_FILE", "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt")
+	kubernetesNamespace = getEnv("KUBERNETES_NAMESPACE", "default")
+	kubernetesTimeout = getEnvDuration("KUBERNETES_EXECUTOR_TIMEOUT", 5*time.Minute)
+	if home, err := os.UserHomeDir(); err == nil {
+		sshKnownHosts = getEnv("SSH_KNOWN_HOSTS", filepath.Join(home, ".ssh", "known_hosts"))
+	} else {
+		sshKnownHosts = getEnv("SSH_KNOWN_HOSTS", "")
+	}
+	historyLimit = getEnvInt("HISTORY_LIMIT", 10000)
+	for _, origin := range strings.Split(getEnv("WS_ALLOWED_ORIGINS", ""), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			wsAllowedOrigins = append(wsAllowedOrigins, origin)
+		}
+	}
+	corsAllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", "")
+	corsAllowedMethods = getEnvList("CORS_ALLOWED_METHODS", "GET, POST, PUT, DELETE")
+	corsAllowedHeaders = getEnvList("CORS_ALLOWED_HEADERS", "Authorization, Content-Type")
+	corsMaxAge = getEnvDuration("CORS_MAX_AGE", 10*time.Minute)
+	secretsDir = getEnv("SECRETS_DIR", "/run/secrets")
+	discoveryRoot = getEnv("DISCOVERY_ROOT", "")
+	discoveryDepth = getEnvInt("DISCOVERY_DEPTH", 2)
+	discoveryTemplate = getEnv("DISCOVERY_TEMPLATE", "")
+	discoveryInterval = getEnvDuration("DISCOVERY_INTERVAL", 0)
+	projectsAPIPersist = getEnv("PROJECTS_API_PERSIST", "false") == "true"
+	configHistoryKey = getEnv("CONFIG_HISTORY_KEY", "tioaoa:config:history")
+	configHistoryLimit = getEnvInt("CONFIG_HISTORY_LIMIT", 20)
+	configURLToken = getEnv("CONFIG_URL_TOKEN", "")
+	configRefresh = getEnvDuration("CONFIG_REFRESH_INTERVAL", time.Minute)
+	configSource = getEnv("CONFIG_SOURCE", "file")
+	configRedisKey = getEnv("CONFIG_REDIS_KEY", "tioaoa:projects")
+	configRedisChannel = getEnv("CONFIG_REDIS_CHANNEL", "tioaoa:projects:changed")
+	configKVPrefix = getEnv("CONFIG_KV_PREFIX", "tioaoa/projects/")
+	consulAddr = getEnv("CONSUL_HTTP_ADDR", "http://127.0.0.1:8500")
+	consulToken = getEnv("CONSUL_HTTP_TOKEN", "")
+	etcdEndpoint = getEnv("ETCD_ENDPOINT", "http://127.0.0.1:2379")
+	loadReloadableSettings()
 }
 
 func getEnv(key, defaultValue string) string {
@@ -74,25 +451,41 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func loadConfig() error {
-	data, err := os.ReadFile(configFile)
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+// getEnvList splits a comma-separated setting, dropping empty entries
+func getEnvList(key, defaultValue string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, defaultValue), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
 	}
+	return values
+}
 
-	var config []Project
-	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-
-	// Build a map for quick lookups
-	projects = make(map[string]Project)
-	for _, p := range config {
-		projects[p.Repo] = p
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %d", key, value, defaultValue)
+		return defaultValue
 	}
+	return n
+}
 
-	log.Printf("Loaded %d project configurations", len(projects))
-	return nil
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
 }
 
 // handlePostMessage handles HTTP POST requests for message ingestion
@@ -102,54 +495,166 @@ func handlePostMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var msg RedisMessage
-	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Followers leave dispatching to the leader
+	if !isLeader() {
+		handleQueueForLeader(w, r, body)
+		return
+	}
+
+	// A JSON array is processed as a batch of messages
+	if isBatch(body) {
+		handleBatchMessages(w, r, body, false)
+		return
+	}
+
+	msg, err := decodeMessage(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Assign a correlation ID so the caller can trace the request downstream
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = newCorrelationID()
+	}
+	w.Header().Set("X-Correlation-ID", msg.CorrelationID)
+	msg.RequestID = requestID(r.Context())
+	if msg.IdempotencyKey == "" {
+		msg.IdempotencyKey = r.Header.Get(idempotencyHeader)
+	}
+
+	if msg.Cancel != "" {
+		handleCancelMessage(w, r, msg)
+		return
+	}
+	if msg.Status != "" {
+		handleStatusMessage(w, r, msg)
+		return
+	}
+
+	// Validate message has either 'up', 'down', 'restart', 'toggle', or a custom action
+	if _, _, ok := msg.actionTarget(); !ok {
+		http.Error(w, "Message must contain either 'up', 'down', 'restart', or 'toggle' field, or 'action' and 'repo'", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := priorityList(msg.Priority); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateIfState(msg.IfState); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := authorizeMessage(contextIdentity(r.Context()), msg); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	expired, err := msg.expired()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if expired {
+		http.Error(w, fmt.Sprintf("Message expired at %s", msg.ExpiresAt), http.StatusGone)
+		return
+	}
+
+	at, err := msg.dispatchTime()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	original, err := claimIdempotencyKey(r.Context(), redisClient, msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if original != "" {
+		writeIdempotentReplay(w, msg, original)
 		return
 	}
 
-	// Validate message has either 'up' or 'down' or 'restart' field
-	if msg.Up == "" && msg.Down == "" && msg.Restart == "" {
-		http.Error(w, "Message must contain either 'up', 'down', or 'restart' field", http.StatusBadRequest)
+	// Dispatching to every project is paced and would outlast the request,
+	// so it is always processed as a job
+	if _, target, _ := msg.actionTarget(); asyncRequested(r) || target == allTarget {
+		handleAsyncMessage(w, r, msg)
+		return
+	}
+	if at.After(time.Now()) {
+		id, err := scheduleMessage(r.Context(), redisClient, msg, at)
+		if err != nil {
+			releaseIdempotencyKey(r.Context(), redisClient, msg)
+			log.Printf("Error scheduling message %s: %v", msg.CorrelationID, err)
+			http.Error(w, fmt.Sprintf("Failed to schedule message: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: fmt.Sprintf("Message scheduled for %s", at.Format(time.RFC3339)), JobID: id, CorrelationID: msg.CorrelationID})
 		return
 	}
 
 	// Process the message
 	messageJSON, err := json.Marshal(msg)
 	if err != nil {
+		releaseIdempotencyKey(r.Context(), redisClient, msg)
 		log.Printf("Error marshaling message: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to process message: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	if err := processMessage(context.Background(), redisClient, string(messageJSON)); err != nil {
-		log.Printf("Error processing message: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to process message: %v", err), http.StatusInternalServerError)
+	if err := processMessage(withSource(context.WithoutCancel(r.Context()), requestSource(r, "http")), redisClient, string(messageJSON)); err != nil {
+		log.Printf("Error processing message %s: %v", msg.CorrelationID, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errForbidden) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, fmt.Sprintf("Failed to process message: %v", err), status)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Message processed successfully",
-	})
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: "Message processed successfully", CorrelationID: msg.CorrelationID})
 }
 
-func main() {
-	log.Println("Starting TurnItOffAndOnAgain service...")
+// handleCancelMessage handles a {"cancel": "<jobId>"} message posted to /messages
+func handleCancelMessage(w http.ResponseWriter, r *http.Request, msg RedisMessage) {
+	if err := cancelScheduledMessage(r.Context(), redisClient, msg.Cancel); err != nil {
+		if errors.Is(err, errJobNotFound) {
+			http.Error(w, fmt.Sprintf("Scheduled job %s not found", msg.Cancel), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		log.Printf("Error cancelling scheduled job %s: %v", msg.Cancel, err)
+		http.Error(w, fmt.Sprintf("Failed to cancel job: %v", err), http.StatusInternalServerError)
+		return
+	}
 
-	// Load project configuration
-	if err := loadConfig(); err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: fmt.Sprintf("Cancelled scheduled job %s", msg.Cancel), CorrelationID: msg.CorrelationID})
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
 	}
 
+	log.Println("Starting TurnItOffAndOnAgain service...")
+
 	// Create Redis client
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: redisPassword,
-		DB:       0,
-	})
+	redisOpts, err := redisOptions(redisURL, redisAddr, redisPassword)
+	if err != nil {
+		log.Fatalf("Failed to configure Redis: %v", err)
+	}
+	rdb := redis.NewClient(redisOpts)
 	defer rdb.Close()
 	redisClient = rdb
 
@@ -160,33 +665,291 @@ func main() {
 	if err := rdb.Ping(ctx).Err(); err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
-	log.Printf("Connected to Redis at %s", redisAddr)
-	log.Printf("Listening for messages on list: %s", sourceList)
+	log.Printf("Connected to Redis at %s (db %d)", redisOpts.Addr, redisOpts.DB)
+
+	// Poppit's target queues may live on a Redis server of their own
+	if targetRedisURL != "" || targetRedisAddr != "" {
+		targetOpts, err := redisOptions(targetRedisURL, targetRedisAddr, targetRedisPassword)
+		if err != nil {
+			log.Fatalf("Failed to configure target Redis: %v", err)
+		}
+		targetRedisClient = redis.NewClient(targetOpts)
+		defer targetRedisClient.Close()
+		if err := targetRedisClient.Ping(ctx).Err(); err != nil {
+			log.Fatalf("Failed to connect to target Redis: %v", err)
+		}
+		log.Printf("Connected to target Redis at %s (db %d)", targetOpts.Addr, targetOpts.DB)
+	}
+	if redisHealthInterval > 0 {
+		runRedisHealthChecks(ctx, rdb)
+	}
+
+	// Load project configuration
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Load who may do what before taking messages signed by them
+	if err := loadAPIKeys(); err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+	if err := loadPermissions(); err != nil {
+		log.Fatalf("Failed to load permissions: %v", err)
+	}
+	if err := checkMessageSigning(); err != nil {
+		log.Fatalf("Invalid message signing settings: %v", err)
+	}
+	registerSecret(webhookExecutorSecret)
+	if strings.HasPrefix(strings.TrimSpace(sshExecutorKey), "-----BEGIN") {
+		registerSecret(sshExecutorKey)
+	}
+
+	// Restore tracked project state from before the restart
+	if err := loadStates(ctx); err != nil {
+		log.Printf("Starting with empty project state: %v", err)
+	}
+
+	// Only the leader consumes messages and runs the background loops
+	if leaderElection {
+		runLeaderElection(ctx, rdb)
+	}
+
+	// Periodically rescan for new projects
+	if discoveryRoot != "" && discoveryInterval > 0 {
+		runDiscoveryLoop(ctx, discoveryInterval)
+	}
+
+	// Dispatch actions scheduled with "at" or "delay"
+	runScheduler(ctx, rdb)
+
+	// Run recurring project schedules
+	runCronSchedules(ctx, rdb)
+
+	// Probe project health
+	runHealthChecks(ctx, rdb)
+
+	// Down idle projects and wake them on activity
+	runIdlePolicy(ctx, rdb)
+
+	// Converge projects on their desired state
+	if reconcileInterval > 0 {
+		runReconciler(ctx, rdb)
+	}
+
+	// Consume commands from a Redis Stream alongside the source lists
+	if sourceStream != "" {
+		if err := runStreamConsumer(ctx, rdb); err != nil {
+			log.Fatalf("Failed to start stream consumer: %v", err)
+		}
+	}
+
+	// Accept fire-and-forget commands published on a channel
+	if sourceChannel != "" {
+		subscribeSourceChannel(ctx, rdb)
+	}
+
+	// Accept commands published on NATS
+	if natsURL != "" {
+		if err := runNATSListener(ctx, rdb); err != nil {
+			log.Fatalf("Failed to start NATS listener: %v", err)
+		}
+	}
+
+	// Consume commands from Kafka
+	if len(kafkaBrokers) > 0 {
+		runKafkaConsumer(ctx, rdb)
+	}
+
+	// Accept commands published over MQTT
+	if mqttBroker != "" {
+		if err := runMQTTListener(ctx, rdb); err != nil {
+			log.Fatalf("Failed to start MQTT listener: %v", err)
+		}
+	}
+
+	// Consume commands from RabbitMQ
+	if amqpURL != "" {
+		runAMQPConsumer(ctx, rdb)
+	}
+
+	// Take commands from Discord
+	if discordToken != "" {
+		dg, err := runDiscordBot()
+		if err != nil {
+			log.Fatalf("Failed to start Discord bot: %v", err)
+		}
+		defer dg.Close()
+	}
+
+	// Follow the results Poppit reports for dispatched actions
+	if resultsQueue != "" {
+		runResultsConsumer(ctx, rdb)
+	}
+	if ackTimeout > 0 {
+		if resultsQueue == "" {
+			log.Fatal("ACK_TIMEOUT requires RESULTS_QUEUE, which Poppit reports results on")
+		}
+		runAckWatcher(ctx, rdb)
+	}
+
+	// Bring up projects flagged with bootUp
+	runBootUp(ctx, rdb)
+
+	// Reload configuration on SIGHUP
+	handleReloadSignals(ctx)
+
+	// Keep project configuration in sync with its source
+	switch configSource {
+	case "redis":
+		watchRedisConfig(ctx, rdb)
+	case "consul":
+		watchConsulConfig(ctx)
+	case "etcd":
+		watchEtcdConfig(ctx)
+	default:
+		if isRemoteConfig(getConfigFile()) {
+			if configRefresh > 0 {
+				runRemoteConfigRefresh(ctx, configRefresh)
+			}
+		} else if configWatch {
+			if err := watchConfig(ctx); err != nil {
+				log.Printf("Config hot-reload disabled: %v", err)
+			}
+		}
+	}
+
+	log.Printf("Listening for messages on lists: %s", strings.Join(getSourceLists(), ", "))
+
+	// Requeue messages a crashed instance took but did not finish
+	if reliableConsumption {
+		recoverOrphanedMessages(ctx, rdb)
+	}
+
+	// Deliver notifications that were built but not pushed before a crash
+	if outboxKey != "" {
+		runOutboxFlusher(ctx, rdb)
+	}
 
 	// Start HTTP server
+	if err := loadAllowedNetworks(); err != nil {
+		log.Fatalf("Failed to load allowed networks: %v", err)
+	}
+	if err := loadWebhookSecrets(); err != nil {
+		log.Fatalf("Failed to load webhook secrets: %v", err)
+	}
+	if err := setupOIDC(ctx); err != nil {
+		log.Fatalf("Failed to set up OIDC: %v", err)
+	}
+	if len(getAPIKeys()) == 0 && oidcVerifier == nil {
+		log.Println("No API keys or OIDC issuer configured; the HTTP API accepts unauthenticated requests")
+	}
+	if rateLimit > 0 {
+		go pruneRateLimiters(ctx)
+	}
 	http.HandleFunc("/messages", handlePostMessage)
+	http.HandleFunc("POST /messages/batch", handlePostBatch)
+	http.HandleFunc("GET /messages/versions", handleMessageVersions)
+	http.HandleFunc("GET /jobs/{id}", handleGetJob)
+	http.HandleFunc("GET /ws", handleWebSocket)
+	http.HandleFunc("GET /events", handleEvents)
+	if slackSigningSecret != "" {
+		http.HandleFunc("POST /slack/commands", handleSlackCommand)
+	}
+	http.HandleFunc("POST /projects/{owner}/{name}", handleCreateProject)
+	http.HandleFunc("PUT /projects/{owner}/{name}", handleUpdateProject)
+	http.HandleFunc("DELETE /projects/{owner}/{name}", handleDeleteProject)
+	http.HandleFunc("GET /state", handleListStates)
+	http.HandleFunc("GET /state/{owner}/{name}", handleGetState)
+	http.HandleFunc("PUT /state", handleApplyState)
+	http.HandleFunc("GET /snapshot", handleExportSnapshot)
+	http.HandleFunc("POST /snapshot", handleImportSnapshot)
+	http.HandleFunc("GET /projects/{owner}/{name}/status", handleGetProjectStatus)
+	http.HandleFunc("PUT /projects/{owner}/{name}/desired", handleSetDesiredState)
+	http.HandleFunc("GET /scheduled", handleListScheduled)
+	http.HandleFunc("DELETE /scheduled/{id}", handleCancelScheduled)
+	http.HandleFunc("GET /schedules", handleListSchedules)
+	http.HandleFunc("POST /projects/{owner}/{name}/schedules/{schedule}/pause", handlePauseSchedule)
+	http.HandleFunc("POST /projects/{owner}/{name}/schedules/{schedule}/resume", handleResumeSchedule)
+	http.HandleFunc("GET /config/revisions", handleListConfigRevisions)
+	http.HandleFunc("GET /config/revisions/{id}", handleGetConfigRevision)
+	http.HandleFunc("POST /config/revisions/{id}/rollback", handleRollbackConfig)
+	http.HandleFunc("POST /config/validate", handleValidateConfig)
+	http.HandleFunc("POST /webhooks/alertmanager", handleAlertmanagerWebhook)
+	http.HandleFunc("GET /healthz", handleHealthz)
+	http.HandleFunc("GET /readyz", handleReadyz)
+	http.Handle("GET /metrics", promhttp.Handler())
+	http.HandleFunc("GET /history", handleListHistory)
+	http.HandleFunc("GET /openapi.json", handleOpenAPI)
+	http.HandleFunc("GET /queues", handleListQueues)
+	if uiEnabled {
+		http.Handle("GET /ui/", uiHandler())
+		http.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+	}
 	httpServer := &http.Server{
 		Addr:         ":" + httpPort,
-		Handler:      nil,
+		Handler:      tagRequest(allowClients(handleCORS(requireSignature(requireAuth(limitRate(http.DefaultServeMux)))))),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
+	httpServer.RegisterOnShutdown(func() { close(sseShutdown) })
 
-	go func() {
-		log.Printf("Starting HTTP server on port %s", httpPort)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+	// Serve the gRPC API alongside HTTP
+	if grpcPort != "" {
+		if err := runGRPCServer(ctx, rdb); err != nil {
+			log.Fatalf("Failed to start gRPC server: %v", err)
 		}
-	}()
+	}
+
+	// Serve the same API on a unix socket for host-local tooling
+	if httpSocket != "" {
+		l, err := listenUnixSocket()
+		if err != nil {
+			log.Fatalf("Failed to listen on HTTP socket: %v", err)
+		}
+		go func() {
+			log.Printf("Starting HTTP server on socket %s", httpSocket)
+			if err := httpServer.Serve(l); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("HTTP server error: %v", err)
+			}
+		}()
+	}
+
+	if !httpSocketOnly || httpSocket == "" {
+		if tlsEnabled() {
+			tlsConfig, err := serverTLSConfig()
+			if err != nil {
+				log.Fatalf("Failed to configure TLS: %v", err)
+			}
+			httpServer.TLSConfig = tlsConfig
+		}
+		go func() {
+			var err error
+			if tlsEnabled() {
+				log.Printf("Starting HTTPS server on port %s", httpPort)
+				err = httpServer.ListenAndServeTLS("", "")
+			} else {
+				log.Printf("Starting HTTP server on port %s", httpPort)
+				err = httpServer.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("HTTP server error: %v", err)
+			}
+		}()
+	}
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		<-sigChan
+		sig := <-sigChan
 		log.Println("Received shutdown signal, cleaning up...")
 
+		// Stop the stack first on SIGTERM, e.g. when the host shuts down
+		if sig == syscall.SIGTERM {
+			shutdownProjects(ctx, rdb)
+		}
+
 		// Shutdown HTTP server
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer shutdownCancel()
@@ -204,8 +967,21 @@ func main() {
 			log.Println("Shutting down...")
 			return
 		default:
-			// BLPOP blocks until a message is available or timeout occurs
-			result, err := rdb.BLPop(ctx, 5*time.Second, sourceList).Result()
+			markConsumerAlive()
+			if !isLeader() {
+				time.Sleep(time.Second)
+				continue
+			}
+
+			// Keep messages in a processing list until they have been handled
+			if reliableConsumption {
+				consumeReliably(ctx, rdb)
+				continue
+			}
+
+			// BLPOP blocks until a message is available or timeout occurs,
+			// taking from the highest priority list that has one
+			result, err := rdb.BLPop(ctx, 5*time.Second, getSourceLists()...).Result()
 			if err != nil {
 				if err == redis.Nil {
 					// Timeout, continue loop
@@ -226,85 +1002,347 @@ func main() {
 
 			// result[0] is the list name, result[1] is the message
 			message := result[1]
-			log.Printf("Received message: %s", message)
+			log.Printf("Received message from %s: %s", result[0], message)
 
-			if err := processMessage(ctx, rdb, message); err != nil {
+			if err := receiveMessage(withSource(ctx, result[0]), rdb, message); err != nil {
 				log.Printf("Error processing message: %v", err)
 			}
 		}
 	}
 }
 
-func processMessage(ctx context.Context, rdb *redis.Client, message string) error {
+func processMessage(ctx context.Context, rdb *redis.Client, message string) (err error) {
+	if isBatch([]byte(message)) {
+		return processBatchMessage(ctx, rdb, message)
+	}
+
 	var msg RedisMessage
-	if err := json.Unmarshal([]byte(message), &msg); err != nil {
-		return fmt.Errorf("failed to parse message: %w", err)
-	}
-
-	var repo string
-	var commands []string
-	var action string
-
-	if msg.Up != "" {
-		repo = msg.Up
-		action = "up"
-	} else if msg.Down != "" {
-		repo = msg.Down
-		action = "down"
-	} else if msg.Restart != "" {
-		repo = msg.Restart
-		action = "restart"
+	if isPlainText(message) {
+		var err error
+		if msg, err = parsePlainMessage(message); err != nil {
+			return err
+		}
 	} else {
-		return fmt.Errorf("message must contain either 'up', 'down', or 'restart' field")
+		var err error
+		if msg, err = decodeMessage([]byte(message)); err != nil {
+			return fmt.Errorf("failed to parse message: %w", err)
+		}
+	}
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = newCorrelationID()
+	}
+	if msg.RequestID == "" {
+		msg.RequestID = requestID(ctx)
+	}
+	if msg.OnBehalfOf != "" && contextIdentity(ctx) == "" {
+		ctx = context.WithValue(ctx, identityContextKey{}, msg.OnBehalfOf)
+	}
+
+	if msg.Cancel != "" {
+		return cancelScheduledMessage(ctx, rdb, msg.Cancel)
+	}
+	if msg.Status != "" {
+		return replyStatus(ctx, rdb, msg)
+	}
+
+	action, target, ok := msg.actionTarget()
+	if !ok {
+		return fmt.Errorf("message must contain either 'up', 'down', 'restart', or 'toggle' field, or 'action' and 'repo'")
+	}
+	publishEvent(Event{Type: eventReceived, Action: action, Target: target, Source: messageSource(ctx), CorrelationID: msg.CorrelationID})
+	messagesReceived.WithLabelValues(action, target).Inc()
+	defer func(start time.Time) { recordMessageMetrics(action, target, start, err) }(time.Now())
+
+	// Report the outcome to the job tracking the message, if it has one
+	jobState, jobErr := jobDispatched, error(nil)
+	defer func() {
+		if err != nil {
+			jobState, jobErr = jobFailed, err
+		}
+		updateJob(ctx, rdb, msg.CorrelationID, jobState, jobErr)
+	}()
+
+	if err := authorizeAction(contextIdentity(ctx), action, target); err != nil {
+		return err
+	}
+
+	// Ignore retries of a message that has been handled already
+	original, err := claimIdempotencyKey(ctx, rdb, msg)
+	if err != nil {
+		return err
+	}
+	if original != "" {
+		log.Printf("[%s] Ignoring %s for %s: idempotency key %q was used by %s", msg.CorrelationID, action, target, msg.IdempotencyKey, original)
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			releaseIdempotencyKey(ctx, rdb, msg)
+		}
+	}()
+	if _, err := priorityList(msg.Priority); err != nil {
+		return err
+	}
+	if err := validateIfState(msg.IfState); err != nil {
+		return err
+	}
+
+	// Discard stale messages, e.g. from a backed-up queue
+	expired, err := msg.expired()
+	if err != nil {
+		return err
+	}
+	if expired {
+		log.Printf("[%s] Discarding expired %s message for %s (expired at %s)", msg.CorrelationID, action, target, msg.ExpiresAt)
+		jobState, jobErr = jobFailed, fmt.Errorf("message expired at %s", msg.ExpiresAt)
+		return nil
+	}
+
+	// Hold delayed actions and actions for a future time in the schedule
+	at, err := msg.dispatchTime()
+	if err != nil {
+		return err
+	}
+	if at.After(time.Now()) {
+		jobState = jobScheduled
+		_, err := scheduleMessage(ctx, rdb, msg, at)
+		return err
+	}
+	if msg.At != "" {
+		log.Printf("[%s] Scheduled time %s for %s of %s has passed, dispatching now", msg.CorrelationID, msg.At, action, target)
+	}
+
+	if target == allTarget && (action == "down" || action == "toggle") && !msg.Confirm {
+		return fmt.Errorf("refusing to %s all projects without \"confirm\": true", action)
 	}
 
 	// Look up project configuration
-	project, exists := projects[repo]
-	if !exists {
-		fmt.Printf("no configuration found for repository: %s\n", repo)
+	targets, err := resolveTargets(target, action)
+	if err != nil {
+		return err
+	}
+	publishEvent(Event{Type: eventResolved, Action: action, Target: target, Repos: projectRepos(targets), CorrelationID: msg.CorrelationID})
+	if len(targets) == 0 {
+		fmt.Printf("[%s] no configuration found for repository: %s\n", msg.CorrelationID, target)
 		return nil
 	}
 
-	if action == "up" {
-		commands = project.UpCommands
-	} else if action == "down" {
-		commands = project.DownCommands
-	} else if action == "restart" {
-		commands = project.RestartCommands
-		if len(commands) == 0 {
-			return fmt.Errorf("no restartCommands configured for repository: %s", repo)
+	// Bring dependencies up first, one tier at a time
+	if action == "up" && msg.IfState == "" {
+		expanded := withDependencies(targets)
+		// The caller must also be allowed to bring up the dependencies
+		if err := authorizeProjects(contextIdentity(ctx), action, expanded); err != nil {
+			return err
+		}
+		if tiers := dependencyTiers(expanded, action); len(tiers) > 1 {
+			log.Printf("[%s] Bringing up %s in %d dependency tiers", msg.CorrelationID, target, len(tiers))
+			return dispatchFailed(dispatchTiers(ctx, rdb, msg, tiers))
 		}
 	}
 
-	log.Printf("Processing %s command for %s", action, repo)
+	// Stop dependents before the projects they depend on. A toggle stops the
+	// projects that are up, which must respect their dependents in the same way.
+	if action == "down" || action == "toggle" {
+		stopping, starting := targets, []Project(nil)
+		if action == "toggle" {
+			stopping = nil
+			for _, project := range targets {
+				if toggledAction(project.Repo) == "down" {
+					stopping = append(stopping, project)
+				} else {
+					starting = append(starting, project)
+				}
+			}
+		}
+		if stopping, err = withDependents(stopping, msg); err != nil {
+			return err
+		}
+		targets = append(stopping, starting...)
+		// Cascading also stops the dependents, which the caller must be allowed to do
+		if err := authorizeProjects(contextIdentity(ctx), action, targets); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for i, project := range targets {
+		// Space out dispatches to every project so Poppit is not flooded
+		if target == allTarget && i > 0 && allDispatchInterval > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(append(errs, ctx.Err())...)
+			case <-time.After(allDispatchInterval):
+			}
+		}
+		if msg.IfState != "" {
+			if state := getProjectState(project.Repo).State; state != msg.IfState {
+				log.Printf("[%s] Skipping %s for %s: state is %s, not %s", msg.CorrelationID, action, project.Repo, state, msg.IfState)
+				recordHistory(ctx, rdb, HistoryEntry{Source: messageSource(ctx), Repo: project.Repo, Action: action, Outcome: outcomeSkipped, Error: fmt.Sprintf("state is %s, not %s", state, msg.IfState), CorrelationID: msg.CorrelationID})
+				continue
+			}
+		}
+
+		projectAction := action
+		if action == "toggle" {
+			projectAction = toggledAction(project.Repo)
+		}
+		if err := dispatchAction(ctx, rdb, msg, project, projectAction); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return dispatchFailed(errors.Join(errs...))
+}
+
+// dispatchError marks a failure to dispatch a message's action, by which time
+// other projects it addresses may have been dispatched already. Deliveries
+// are retried while dispatching, so the message is not worth processing again.
+type dispatchError struct{ err error }
+
+func (e dispatchError) Error() string { return e.err.Error() }
+func (e dispatchError) Unwrap() error { return e.err }
+
+// dispatchFailed wraps a non-nil dispatch error in a dispatchError
+func dispatchFailed(err error) error {
+	if err == nil {
+		return nil
+	}
+	return dispatchError{err}
+}
+
+// retryableError reports whether a message that failed to process may
+// succeed when processed again: it failed for a reason that can pass, such
+// as Redis being unreachable, and before any project was dispatched. Invalid
+// and forbidden messages would only fail again.
+func retryableError(err error) bool {
+	if err == nil || errors.As(err, &dispatchError{}) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// dispatchAction sends the notification for a single project and action
+func dispatchAction(ctx context.Context, rdb *redis.Client, msg RedisMessage, project Project, action string) (err error) {
+	repo := project.Repo
+	var targetQueue string
+	outcome := outcomeDispatched
+	defer func() {
+		entry := HistoryEntry{Source: messageSource(ctx), Repo: repo, Action: action, Outcome: outcome, TargetQueue: targetQueue, CorrelationID: msg.CorrelationID}
+		if err != nil {
+			entry.Outcome = outcomeFailed
+			entry.Error = err.Error()
+		}
+		recordHistory(ctx, rdb, entry)
+	}()
+
+	commands, err := project.actionCommands(action)
+	if err != nil {
+		return err
+	}
+	if len(msg.ExtraCommands) > 0 {
+		if !project.runsCommands() {
+			return fmt.Errorf("extraCommands are not supported by the %s executor", project.executor())
+		}
+		if !allowExtraCommands {
+			return fmt.Errorf("extraCommands are disabled; set ALLOW_EXTRA_COMMANDS=true to enable them")
+		}
+		if msg.PrependExtraCommands {
+			commands = append(slices.Clone(msg.ExtraCommands), commands...)
+		} else {
+			commands = append(slices.Clone(commands), msg.ExtraCommands...)
+		}
+	}
+	if msg.RequestID != "" {
+		log.Printf("[%s] Processing %s command for %s (state: %s) from HTTP request %s", msg.CorrelationID, action, repo, getProjectState(repo).State, msg.RequestID)
+	} else {
+		log.Printf("[%s] Processing %s command for %s (state: %s)", msg.CorrelationID, action, repo, getProjectState(repo).State)
+	}
 
 	// Send notification to Poppit (Poppit will execute the commands)
 	// Priority: message target-queue > project targetQueue > default target queue
-	targetQueue := msg.TargetQueue
+	targetQueue = msg.TargetQueue
 	if targetQueue == "" {
 		targetQueue = project.TargetQueue
 	}
 	if targetQueue == "" {
-		targetQueue = defaultTargetQueue
+		targetQueue = getDefaultTargetQueue()
 	}
 
-	notification := PoppitNotification{
-		Repo:     repo,
-		Branch:   "refs/heads/main",
-		Type:     fmt.Sprintf("service-%s", action),
-		Dir:      project.Dir,
-		Commands: commands,
+	branch := "refs/heads/main"
+	if msg.Branch != "" {
+		branch = msg.Branch
+		// Accept short branch names such as "release/1.2"
+		if !strings.HasPrefix(branch, "refs/") {
+			branch = "refs/heads/" + branch
+		}
 	}
+	dir := project.actionDir(action)
 
-	notificationJSON, err := json.Marshal(notification)
+	// Expand templates such as {{.RepoShort}} in the configured commands
+	data := newCommandData(project, action, branch, targetQueue, msg, contextIdentity(ctx))
+	data.Dir = dir
+	commands, err = renderCommands(commands, data)
 	if err != nil {
-		return fmt.Errorf("failed to marshal notification: %w", err)
+		return err
 	}
 
-	if err := rdb.RPush(ctx, targetQueue, notificationJSON).Err(); err != nil {
-		return fmt.Errorf("failed to push notification to %s: %w", targetQueue, err)
+	notification := PoppitNotification{
+		Repo:          repo,
+		Branch:        branch,
+		Type:          fmt.Sprintf("service-%s", action),
+		Dir:           dir,
+		Commands:      commands,
+		Env:           project.Env,
+		CorrelationID: msg.CorrelationID,
+		RequestID:     msg.RequestID,
 	}
 
-	log.Printf("Sent notification to %s for %s (%s)", targetQueue, repo, action)
+	// Hold back repeats of the action within the project's cooldown
+	if ok, err := checkCooldown(ctx, rdb, msg, project, action); !ok {
+		outcome = outcomeDeferred
+		return err
+	}
+
+	// Collapse repeats of the same action for the same project
+	if dedupWindow > 0 {
+		first, err := rdb.SetNX(ctx, dedupKeyPrefix+action+":"+repo, msg.CorrelationID, dedupWindow).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check for duplicate %s of %s: %w", action, repo, err)
+		}
+		if !first {
+			log.Printf("[%s] Collapsing duplicate %s for %s within %s", msg.CorrelationID, action, repo, dedupWindow)
+			outcome = outcomeCollapsed
+			return nil
+		}
+	}
+
+	if project.executor() != executorPoppit {
+		// No queue is involved in the history of other executors
+		targetQueue = ""
+	}
+	destination, start, err := deliverAction(ctx, rdb, project, targetQueue, notification)
+	if err != nil {
+		// The action was never sent, so it must not hold back a resubmission
+		if dedupWindow > 0 {
+			rdb.Del(context.WithoutCancel(ctx), dedupKeyPrefix+action+":"+repo)
+		}
+		return err
+	}
+	recordDispatch(repo, action, msg.CorrelationID)
+	if project.executor() == executorPoppit {
+		expectAck(ctx, rdb, pendingAck{CorrelationID: msg.CorrelationID, Repo: repo, Action: action})
+	}
+	startCooldown(ctx, rdb, msg, project, action)
+	actionsDispatched.WithLabelValues(action, repo).Inc()
+	publishEvent(Event{Type: eventDispatched, Repo: repo, Action: action, Source: messageSource(ctx), CorrelationID: msg.CorrelationID})
+
+	if source := messageSource(ctx); source != "" {
+		log.Printf("[%s] Sent %s for %s (%s) from %s", msg.CorrelationID, destination, repo, action, source)
+	} else {
+		log.Printf("[%s] Sent %s for %s (%s)", msg.CorrelationID, destination, repo, action)
+	}
+	if start != nil {
+		start()
+	}
 	return nil
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	messagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tioaoa_messages_received_total",
+		Help: "Action messages received, by action and target repo.",
+	}, []string{"action", "repo"})
+	messagesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tioaoa_messages_processed_total",
+		Help: "Action messages processed without error, by action and target repo.",
+	}, []string{"action", "repo"})
+	messagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tioaoa_messages_failed_total",
+		Help: "Action messages whose processing failed, by action and target repo.",
+	}, []string{"action", "repo"})
+	messageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tioaoa_message_processing_seconds",
+		Help:    "Time taken to process an action message, by action.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"action"})
+	actionsDispatched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tioaoa_actions_dispatched_total",
+		Help: "Actions sent to Poppit, by action and project repo.",
+	}, []string{"action", "repo"})
+	actionResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tioaoa_action_results_total",
+		Help: "Results of actions reported by Poppit on RESULTS_QUEUE or run by the service, by action, project repo, and outcome.",
+	}, []string{"action", "repo", "outcome"})
+	notificationRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tioaoa_notification_retries_total",
+		Help: "Failed deliveries of notifications to a target queue or webhook that were retried.",
+	})
+	actionsUnacknowledged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tioaoa_actions_unacknowledged_total",
+		Help: "Actions sent to Poppit without a result on RESULTS_QUEUE within ACK_TIMEOUT, by action and project repo.",
+	}, []string{"action", "repo"})
+	rateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tioaoa_http_rate_limited_total",
+		Help: "HTTP requests refused because the client exceeded RATE_LIMIT.",
+	})
+	clientsDenied = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tioaoa_http_clients_denied_total",
+		Help: "HTTP requests refused because the client is outside HTTP_ALLOWED_CIDRS.",
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "tioaoa_projects_loaded",
+		Help: "Projects in the active configuration.",
+	}, func() float64 { return float64(len(allProjects())) })
+
+	queueDepthDesc = prometheus.NewDesc(
+		"tioaoa_source_queue_depth",
+		"Messages waiting on each source list.",
+		[]string{"list"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(queueDepthCollector{})
+}
+
+// recordMessageMetrics counts a processed action message as succeeded or
+// failed and records how long it took
+func recordMessageMetrics(action, target string, start time.Time, err error) {
+	if err != nil {
+		messagesFailed.WithLabelValues(action, target).Inc()
+	} else {
+		messagesProcessed.WithLabelValues(action, target).Inc()
+	}
+	messageDuration.WithLabelValues(action).Observe(time.Since(start).Seconds())
+}
+
+// queueDepthCollector reads the length of every source list from Redis when
+// the metrics are scraped
+type queueDepthCollector struct{}
+
+func (queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	if redisClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	depths, err := queueDepths(ctx, redisClient)
+	if err != nil {
+		log.Printf("Error reading queue depths for metrics: %v", err)
+		return
+	}
+	for _, q := range depths {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(q.Depth), q.List)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/redis/go-redis/v9"
+)
+
+// runMQTTListener subscribes to MQTT_TOPIC for complete messages and to the
+// topics under MQTT_ACTION_TOPIC, where the topic names the action, so that
+// buttons and home-automation flows can publish commands. Subscriptions are
+// renewed whenever the client reconnects.
+func runMQTTListener(ctx context.Context, rdb *redis.Client) error {
+	if mqttQoS < 0 || mqttQoS > 2 {
+		return fmt.Errorf("invalid MQTT_QOS %d: must be 0, 1, or 2", mqttQoS)
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(mqttBroker).
+		SetClientID(mqttClientID).
+		SetUsername(mqttUsername).
+		SetPassword(mqttPassword).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("Disconnected from MQTT broker: %v", err)
+		})
+
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		filters := map[string]byte{}
+		if mqttTopic != "" {
+			filters[mqttTopic] = byte(mqttQoS)
+		}
+		if mqttActionTopic != "" {
+			filters[mqttActionTopic+"/#"] = byte(mqttQoS)
+		}
+		token := c.SubscribeMultiple(filters, func(_ mqtt.Client, m mqtt.Message) {
+			handleMQTTMessage(ctx, rdb, m)
+		})
+		if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+			log.Printf("Error subscribing to MQTT topics: %v", token.Error())
+			return
+		}
+		log.Printf("Subscribed to MQTT topics at %s", mqttBroker)
+	})
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+		return fmt.Errorf("failed to connect to MQTT broker at %s: %w", mqttBroker, token.Error())
+	}
+
+	go func() {
+		<-ctx.Done()
+		client.Disconnect(250)
+	}()
+
+	log.Printf("Listening for messages on MQTT topics %s and %s/# at %s", mqttTopic, mqttActionTopic, mqttBroker)
+	return nil
+}
+
+// handleMQTTMessage processes a message received on one of the subscribed
+// topics
+func handleMQTTMessage(ctx context.Context, rdb *redis.Client, m mqtt.Message) {
+	// Every instance receives the message, so only the leader acts on it
+	if !isLeader() {
+		return
+	}
+	log.Printf("Received message from MQTT topic %s: %s", m.Topic(), m.Payload())
+
+	message, err := mqttMessage(m.Topic(), string(m.Payload()))
+	if err == nil {
+		err = receiveMessage(withSource(ctx, "mqtt:"+m.Topic()), rdb, message)
+	}
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+	}
+}
+
+// mqttMessage returns the message to process for a payload received on
+// topic. Payloads on MQTT_TOPIC are complete messages. Under
+// MQTT_ACTION_TOPIC, the next topic level is the action and the target is
+// either the remaining levels, e.g. tioaoa/actions/restart/owner/name, in
+// which case the payload is ignored, or the payload itself.
+func mqttMessage(topic, payload string) (string, error) {
+	prefix := mqttActionTopic + "/"
+	if mqttActionTopic == "" || !strings.HasPrefix(topic, prefix) {
+		return payload, nil
+	}
+
+	action, target, _ := strings.Cut(strings.TrimPrefix(topic, prefix), "/")
+	if target == "" {
+		target = strings.TrimSpace(payload)
+	}
+	if action == "" || target == "" || strings.ContainsAny(target, " \t\n") {
+		return "", fmt.Errorf("MQTT topic %s needs an action and a target in the topic or payload", topic)
+	}
+	return action + " " + target, nil
+}
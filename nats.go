@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// runNATSListener feeds messages published on NATS_SUBJECT into the same
+// processing pipeline as the source lists. Requests (messages with a reply
+// subject) are answered with the outcome.
+func runNATSListener(ctx context.Context, rdb *redis.Client) error {
+	nc, err := nats.Connect(natsURL,
+		nats.Name("TurnItOffAndOnAgain"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Printf("Reconnected to NATS at %s", nc.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS at %s: %w", natsURL, err)
+	}
+
+	_, err = nc.Subscribe(natsSubject, func(m *nats.Msg) {
+		// Every instance receives the message, so only the leader acts on it
+		if !isLeader() {
+			return
+		}
+		log.Printf("Received message from NATS subject %s: %s", m.Subject, m.Data)
+		err := receiveMessage(withSource(ctx, "nats:"+m.Subject), rdb, string(m.Data))
+		if err != nil {
+			log.Printf("Error processing message: %v", err)
+		}
+		if m.Reply != "" {
+			replyNATS(m, err)
+		}
+	})
+	if err != nil {
+		nc.Close()
+		return fmt.Errorf("failed to subscribe to NATS subject %s: %w", natsSubject, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		nc.Drain()
+	}()
+
+	log.Printf("Listening for messages on NATS subject %s at %s", natsSubject, natsURL)
+	return nil
+}
+
+// replyNATS answers a NATS request with the outcome of processing it
+func replyNATS(m *nats.Msg, err error) {
+	reply := map[string]string{"status": "success", "message": "Message processed successfully"}
+	if err != nil {
+		reply = map[string]string{"status": "error", "message": err.Error()}
+	}
+	data, _ := json.Marshal(reply)
+	if err := m.Respond(data); err != nil {
+		log.Printf("Error replying to NATS request: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// QueueConfig shapes the notifications pushed onto one target queue, for
+// consumers other than Poppit. Either Template or Fields may be set; queues
+// with neither receive the PoppitNotification format.
+type QueueConfig struct {
+	// Template is a Go template rendered with NotificationData to produce
+	// the payload, e.g. {"service": {{json .Repo}}, "op": {{json .Action}}}
+	Template string `json:"template,omitempty" yaml:"template,omitempty" toml:"template,omitempty"`
+	// Fields maps each key of a JSON object payload to the notification
+	// field it is taken from, e.g. {"service": "repo", "steps": "commands"}
+	Fields map[string]string `json:"fields,omitempty" yaml:"fields,omitempty" toml:"fields,omitempty"`
+}
+
+// NotificationData is the data available to notification templates: the
+// Poppit notification and the action it is for
+type NotificationData struct {
+	PoppitNotification
+	Action string
+}
+
+// notificationFields are the names Fields may map from
+var notificationFields = []string{"repo", "branch", "type", "dir", "commands", "env", "correlationId", "requestId", "action"}
+
+// notificationFormat renders the payloads for one target queue
+type notificationFormat struct {
+	template *template.Template
+	fields   map[string]string
+}
+
+var notificationFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": strings.Join,
+}
+
+// buildNotificationFormats parses the configured queue formats, returning
+// every problem found
+func buildNotificationFormats(queues map[string]QueueConfig) (map[string]*notificationFormat, []error) {
+	formats := make(map[string]*notificationFormat, len(queues))
+	var errs []error
+	for _, queue := range slices.Sorted(maps.Keys(queues)) {
+		qc := queues[queue]
+		switch {
+		case qc.Template != "" && len(qc.Fields) > 0:
+			errs = append(errs, fmt.Errorf("queue %s: set either template or fields, not both", queue))
+			continue
+		case qc.Template != "":
+			tmpl, err := template.New(queue).Funcs(notificationFuncs).Option("missingkey=error").Parse(qc.Template)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("queue %s: invalid template: %w", queue, err))
+				continue
+			}
+			formats[queue] = &notificationFormat{template: tmpl}
+		case len(qc.Fields) > 0:
+			for key, field := range qc.Fields {
+				if !slices.Contains(notificationFields, field) {
+					errs = append(errs, fmt.Errorf("queue %s: field %s maps from unknown notification field %q (expected one of %s)", queue, key, field, strings.Join(notificationFields, ", ")))
+				}
+			}
+			formats[queue] = &notificationFormat{fields: qc.Fields}
+		default:
+			continue
+		}
+
+		// Render sample data so mistakes surface at load time
+		sample := PoppitNotification{Repo: "owner/name", Branch: "refs/heads/main", Type: "service-up", Dir: "/srv/name", Commands: []string{"true"}, Env: map[string]string{}}
+		if _, err := formats[queue].render(sample); err != nil {
+			errs = append(errs, fmt.Errorf("queue %s: %w", queue, err))
+		}
+	}
+	return formats, errs
+}
+
+// render builds the payload for a notification
+func (f *notificationFormat) render(n PoppitNotification) ([]byte, error) {
+	data := NotificationData{PoppitNotification: n, Action: strings.TrimPrefix(n.Type, "service-")}
+	if f.template != nil {
+		var sb strings.Builder
+		if err := f.template.Execute(&sb, data); err != nil {
+			return nil, fmt.Errorf("failed to render notification: %w", err)
+		}
+		return []byte(sb.String()), nil
+	}
+
+	values := map[string]any{
+		"repo":          n.Repo,
+		"branch":        n.Branch,
+		"type":          n.Type,
+		"dir":           n.Dir,
+		"commands":      n.Commands,
+		"env":           n.Env,
+		"correlationId": n.CorrelationID,
+		"requestId":     n.RequestID,
+		"action":        data.Action,
+	}
+	payload := make(map[string]any, len(f.fields))
+	for key, field := range f.fields {
+		payload[key] = values[field]
+	}
+	return json.Marshal(payload)
+}
+
+// formatNotification builds the payload pushed onto queue: the notification
+// in the queue's configured format, or as JSON when it has none
+func formatNotification(queue string, n PoppitNotification) ([]byte, error) {
+	projectsMu.RLock()
+	format := notificationFormats[queue]
+	projectsMu.RUnlock()
+	if format == nil {
+		data, err := json.Marshal(n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal notification: %w", err)
+		}
+		return data, nil
+	}
+	return format.render(n)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// permanentError marks a failed delivery that retrying cannot fix, such as a
+// request the receiver rejected
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// retryWithBackoff calls attempt until it succeeds. Failures are retried with
+// exponential backoff and jitter, starting at NOTIFY_RETRY_BACKOFF and capped
+// at NOTIFY_RETRY_MAX_BACKOFF, until NOTIFY_RETRY_TIMEOUT has passed since the
+// first attempt or attempt returns a permanentError. what describes the
+// attempt for logs and errors, e.g. "push notification to poppit:notifications".
+func retryWithBackoff(ctx context.Context, correlationID, what string, attempt func() error) error {
+	deadline := time.Now().Add(notifyRetryTimeout)
+	backoff := notifyRetryBackoff
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			if n > 1 {
+				log.Printf("[%s] Managed to %s on attempt %d", correlationID, what, n)
+			}
+			return nil
+		}
+		if errors.As(err, &permanentError{}) {
+			return fmt.Errorf("failed to %s: %w", what, err)
+		}
+
+		// Wait between half and all of the backoff, so that instances
+		// retrying at once do not all hit the receiver together
+		wait := backoff/2 + rand.N(backoff/2+1)
+		if ctx.Err() != nil || time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("failed to %s after %d attempts: %w", what, n, err)
+		}
+		log.Printf("[%s] Failed to %s (attempt %d), retrying in %s: %v", correlationID, what, n, wait.Round(time.Millisecond), err)
+		notificationRetries.Inc()
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to %s after %d attempts: %w", what, n, err)
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, notifyRetryMaxBackoff)
+	}
+}
+
+// pushNotification pushes a notification onto a Poppit target queue,
+// retrying failed pushes
+func pushNotification(ctx context.Context, rdb *redis.Client, correlationID, queue string, data []byte) error {
+	return retryWithBackoff(ctx, correlationID, "push notification to "+queue, func() error {
+		return targetRedis(rdb).RPush(ctx, queue, data).Err()
+	})
+}
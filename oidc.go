@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// oidcVerifier checks bearer tokens issued by OIDC_ISSUER; nil when OIDC is
+// disabled
+var oidcVerifier *oidc.IDTokenVerifier
+
+// setupOIDC discovers OIDC_ISSUER's signing keys. The keys are refetched in
+// the background until ctx is done, whenever a token is signed with one that
+// is not known yet.
+func setupOIDC(ctx context.Context) error {
+	if oidcIssuer == "" {
+		return nil
+	}
+	if oidcAudience == "" {
+		return errors.New("OIDC_AUDIENCE is required with OIDC_ISSUER")
+	}
+	provider, err := oidc.NewProvider(ctx, oidcIssuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover OIDC issuer %s: %w", oidcIssuer, err)
+	}
+	oidcVerifier = provider.Verifier(&oidc.Config{ClientID: oidcAudience})
+	log.Printf("Accepting tokens from OIDC issuer %s for audience %s", oidcIssuer, oidcAudience)
+	return nil
+}
+
+// verifyOIDCToken checks the token's signature, issuer, audience, and expiry,
+// and returns the identity recorded for its bearer: the OIDC_IDENTITY_CLAIM
+// claim, or the subject when the token does not have it
+func verifyOIDCToken(ctx context.Context, raw string) (string, error) {
+	token, err := oidcVerifier.Verify(ctx, raw)
+	if err != nil {
+		return "", err
+	}
+	var claims map[string]any
+	if err := token.Claims(&claims); err != nil {
+		return "", fmt.Errorf("failed to read token claims: %w", err)
+	}
+	if identity, ok := claims[oidcIdentityClaim].(string); ok && identity != "" {
+		return identity, nil
+	}
+	return token.Subject, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// outboxGracePeriod is how much longer than NOTIFY_RETRY_TIMEOUT a
+// notification must have been in the outbox before it is flushed, so that
+// notifications still being pushed are left alone
+const outboxGracePeriod = 30 * time.Second
+
+// outboxEntry is a notification waiting in the OUTBOX_KEY hash to be pushed
+// onto its target queue
+type outboxEntry struct {
+	Queue         string    `json:"queue"`
+	Payload       string    `json:"payload"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// outboxFlush asks the outbox flusher to run straight away, for example when
+// a Redis connection recovers
+var outboxFlush = make(chan struct{}, 1)
+
+func requestOutboxFlush() {
+	select {
+	case outboxFlush <- struct{}{}:
+	default:
+	}
+}
+
+// sendNotification delivers a notification to its target queue. With
+// OUTBOX_KEY set, the notification is stored in the outbox on the source
+// Redis first and removed once it has been pushed. A notification that could
+// not be pushed, or was being pushed when the service stopped, stays there
+// for flushOutbox to deliver.
+func sendNotification(ctx context.Context, rdb *redis.Client, correlationID, queue string, data []byte) error {
+	if outboxKey == "" {
+		return pushNotification(ctx, rdb, correlationID, queue, data)
+	}
+
+	id := newCorrelationID()
+	entry, err := json.Marshal(outboxEntry{Queue: queue, Payload: string(data), CorrelationID: correlationID, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+	if err := rdb.HSet(ctx, outboxKey, id, entry).Err(); err != nil {
+		return fmt.Errorf("failed to store notification in the outbox: %w", err)
+	}
+
+	if err := pushNotification(ctx, rdb, correlationID, queue, data); err != nil {
+		log.Printf("[%s] Keeping notification for %s in the outbox to deliver later: %v", correlationID, queue, err)
+		return nil
+	}
+	if err := rdb.HDel(context.WithoutCancel(ctx), outboxKey, id).Err(); err != nil {
+		// It will be delivered again, which consumers must tolerate anyway
+		log.Printf("[%s] Error removing delivered notification from the outbox: %v", correlationID, err)
+	}
+	return nil
+}
+
+// runOutboxFlusher delivers the notifications left in the outbox on startup,
+// every OUTBOX_FLUSH_INTERVAL, and whenever a Redis connection recovers
+func runOutboxFlusher(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		ticker := time.NewTicker(outboxFlushInterval)
+		defer ticker.Stop()
+		for {
+			if isLeader() {
+				flushOutbox(ctx, rdb)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-outboxFlush:
+			}
+		}
+	}()
+
+	log.Printf("Delivering undelivered notifications from %s every %s", outboxKey, outboxFlushInterval)
+}
+
+// flushOutbox pushes the notifications that have been in the outbox for
+// longer than a push is retried for. It stops at the first push that fails,
+// as the target Redis is most likely still unreachable.
+func flushOutbox(ctx context.Context, rdb *redis.Client) {
+	entries, err := rdb.HGetAll(ctx, outboxKey).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Error reading outbox: %v", err)
+		}
+		return
+	}
+
+	// remaining counts the notifications still in the outbox
+	remaining := len(entries)
+	for id, raw := range entries {
+		var entry outboxEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			log.Printf("Discarding unreadable outbox entry %s: %v", id, err)
+			rdb.HDel(ctx, outboxKey, id)
+			remaining--
+			continue
+		}
+		if time.Since(entry.CreatedAt) < notifyRetryTimeout+outboxGracePeriod {
+			continue
+		}
+
+		if err := targetRedis(rdb).RPush(ctx, entry.Queue, entry.Payload).Err(); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[%s] Error delivering notification to %s from the outbox, %d left undelivered: %v", entry.CorrelationID, entry.Queue, remaining, err)
+			}
+			return
+		}
+		remaining--
+		if err := rdb.HDel(ctx, outboxKey, id).Err(); err != nil {
+			log.Printf("[%s] Error removing delivered notification from the outbox: %v", entry.CorrelationID, err)
+		}
+		log.Printf("[%s] Delivered notification to %s from the outbox, %s after it was built", entry.CorrelationID, entry.Queue, time.Since(entry.CreatedAt).Round(time.Second))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isPlainText reports whether a raw message uses the compact text format,
+// e.g. "up its-the-vibe/InnerGate", rather than JSON
+func isPlainText(message string) bool {
+	trimmed := strings.TrimSpace(message)
+	return trimmed != "" && trimmed[0] != '{' && trimmed[0] != '['
+}
+
+// parsePlainMessage parses a "<verb> <target>" text message into the same
+// message as its JSON form. The verb is up, down, restart, toggle, status
+// (followed by the reply list), cancel (with a job ID as the target), or the
+// name of a custom action.
+func parsePlainMessage(message string) (RedisMessage, error) {
+	fields := strings.Fields(message)
+	if len(fields) == 3 && strings.EqualFold(fields[0], "status") {
+		msg := RedisMessage{Status: fields[1]}
+		msg.ReplyTo = fields[2]
+		return msg, nil
+	}
+	if len(fields) != 2 {
+		return RedisMessage{}, fmt.Errorf("text messages must have the form \"<action> <target>\", got %q", strings.TrimSpace(message))
+	}
+
+	verb, target := strings.ToLower(fields[0]), fields[1]
+	var msg RedisMessage
+	switch verb {
+	case "up":
+		msg.Up = Target(target)
+	case "down":
+		msg.Down = Target(target)
+	case "restart":
+		msg.Restart = Target(target)
+	case "toggle":
+		msg.Toggle = Target(target)
+	case "status":
+		msg.Status = target
+	case "cancel":
+		msg.Cancel = target
+	default:
+		msg.Action = fields[0]
+		msg.Repo = Target(target)
+	}
+	return msg, nil
+}
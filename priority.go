@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// normalPriority is the priority level consumed from SOURCE_LIST itself
+const normalPriority = "normal"
+
+// parsePriorityLevels parses the comma-separated PRIORITY_LEVELS setting,
+// highest priority first. The normal level is always included.
+func parsePriorityLevels(value string) []string {
+	var levels []string
+	for _, level := range strings.Split(value, ",") {
+		level = strings.TrimSpace(level)
+		if level != "" && !slices.Contains(levels, level) {
+			levels = append(levels, level)
+		}
+	}
+	if !slices.Contains(levels, normalPriority) {
+		levels = append(levels, normalPriority)
+	}
+	return levels
+}
+
+// parseSourceLists parses the comma-separated SOURCE_LIST setting. The first
+// list is the primary one.
+func parseSourceLists(value string) []string {
+	var lists []string
+	for _, list := range strings.Split(value, ",") {
+		list = strings.TrimSpace(list)
+		if list != "" && !slices.Contains(lists, list) {
+			lists = append(lists, list)
+		}
+	}
+	if len(lists) == 0 {
+		lists = []string{"service:commands"}
+	}
+	return lists
+}
+
+// priorityList returns the primary source list for a priority level
+func priorityList(priority string) (string, error) {
+	return sourcePriorityList(getSourceList(), priority)
+}
+
+// sourcePriorityList returns the list for a priority level of a source list.
+// The normal level (or no priority) uses the source list itself and other
+// levels use <list>:<level>.
+func sourcePriorityList(list, priority string) (string, error) {
+	if priority == "" || priority == normalPriority {
+		return list, nil
+	}
+	if !slices.Contains(getPriorityLevels(), priority) {
+		return "", fmt.Errorf("unknown priority %q (expected one of %s)", priority, strings.Join(getPriorityLevels(), ", "))
+	}
+	return list + ":" + priority, nil
+}
+
+// getSourceLists returns every source list in the order they are consumed,
+// highest priority first and then in SOURCE_LIST order. BLPOP pops from the
+// first non-empty list, so a higher priority message is always taken before a
+// lower priority one.
+func getSourceLists() []string {
+	settingsMu.RLock()
+	sources := sourceLists
+	settingsMu.RUnlock()
+
+	levels := getPriorityLevels()
+	lists := make([]string, 0, len(levels)*len(sources))
+	for _, level := range levels {
+		for _, source := range sources {
+			list, _ := sourcePriorityList(source, level)
+			lists = append(lists, list)
+		}
+	}
+	return lists
+}
+
+type sourceContextKey struct{}
+
+// withSource records the list, stream, or channel a message came from
+func withSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceContextKey{}, source)
+}
+
+// messageSource returns where the message being processed came from, if known
+func messageSource(ctx context.Context) string {
+	source, _ := ctx.Value(sourceContextKey{}).(string)
+	return source
+}
+
+// enqueueMessage pushes msg onto the source list for its priority, to be
+// picked up by the main processing loop
+func enqueueMessage(ctx context.Context, rdb *redis.Client, msg RedisMessage) error {
+	list, err := priorityList(msg.Priority)
+	if err != nil {
+		return err
+	}
+	if identity := contextIdentity(ctx); identity != "" {
+		msg.OnBehalfOf = identity
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if err := rdb.RPush(ctx, list, signMessage(data)).Err(); err != nil {
+		return fmt.Errorf("failed to push message to %s: %w", list, err)
+	}
+	return nil
+}
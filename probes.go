@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+)
+
+// consumerHeartbeat is when the main consumer loop last went round, in Unix
+// nanoseconds
+var consumerHeartbeat atomic.Int64
+
+// markConsumerAlive records that the consumer loop is making progress
+func markConsumerAlive() {
+	consumerHeartbeat.Store(time.Now().UnixNano())
+}
+
+// configLoaded reports whether a project configuration has been loaded
+func configLoaded() bool {
+	projectsMu.RLock()
+	defer projectsMu.RUnlock()
+	return projects != nil
+}
+
+// handleHealthz handles GET /healthz. The service is live while its consumer
+// loop has gone round within LIVENESS_TIMEOUT; a loop wedged on a message
+// fails the probe so the orchestrator restarts the service.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	last := consumerHeartbeat.Load()
+	if last == 0 {
+		http.Error(w, "Consumer loop has not started", http.StatusServiceUnavailable)
+		return
+	}
+	if since := time.Since(time.Unix(0, last)); since > livenessTimeout {
+		http.Error(w, fmt.Sprintf("Consumer loop has made no progress for %s", since.Round(time.Second)), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: "Live"})
+}
+
+// handleReadyz handles GET /readyz. The service is ready once its
+// configuration is loaded and while the source and target Redis servers
+// answer.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	var problems []string
+	if !configLoaded() {
+		problems = append(problems, "configuration not loaded")
+	}
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		problems = append(problems, fmt.Sprintf("source Redis unreachable: %v", err))
+	}
+	if targetRedisClient != nil {
+		if err := targetRedisClient.Ping(ctx).Err(); err != nil {
+			problems = append(problems, fmt.Sprintf("target Redis unreachable: %v", err))
+		}
+	}
+	if len(problems) > 0 {
+		http.Error(w, "Not ready: "+strings.Join(problems, "; "), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: "Ready"})
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"gopkg.in/yaml.v3"
+)
+
+// projectsAPIMu serialises changes made through the projects API so that
+// existence checks and updates cannot interleave
+var projectsAPIMu sync.Mutex
+
+// handleCreateProject handles POST /projects/{owner}/{name}
+func handleCreateProject(w http.ResponseWriter, r *http.Request) {
+	saveProjectFromRequest(w, r, true)
+}
+
+// handleUpdateProject handles PUT /projects/{owner}/{name}, creating the
+// project if it does not exist yet
+func handleUpdateProject(w http.ResponseWriter, r *http.Request) {
+	saveProjectFromRequest(w, r, false)
+}
+
+func saveProjectFromRequest(w http.ResponseWriter, r *http.Request, createOnly bool) {
+	repo := r.PathValue("owner") + "/" + r.PathValue("name")
+
+	var raw Project
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if raw.Repo != "" && raw.Repo != repo {
+		http.Error(w, fmt.Sprintf("Repo in body (%s) does not match URL (%s)", raw.Repo, repo), http.StatusBadRequest)
+		return
+	}
+	raw.Repo = repo
+
+	projectsAPIMu.Lock()
+	defer projectsAPIMu.Unlock()
+
+	existing, exists := lookupProject(repo)
+	if exists && createOnly {
+		http.Error(w, fmt.Sprintf("Project %s already exists", repo), http.StatusConflict)
+		return
+	}
+
+	resolved, errs := prepareRuntimeProject(raw)
+	if len(errs) > 0 {
+		http.Error(w, string(redact([]byte(fmt.Sprintf("Invalid project: %v", errors.Join(errs...))))), http.StatusBadRequest)
+		return
+	}
+	// Both the project as it is and as it will be must be covered, so that a
+	// change of group or labels cannot move it out of reach of the grant
+	covered := []Project{resolved}
+	if exists {
+		covered = append(covered, existing)
+	}
+	if err := authorizeConfig(contextIdentity(r.Context()), covered...); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if projectsAPIPersist {
+		if err := persistProject(r.Context(), repo, &raw); err != nil {
+			log.Printf("Error persisting project %s: %v", repo, err)
+			http.Error(w, fmt.Sprintf("Failed to persist project: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	setProject(raw, resolved)
+
+	status, message := http.StatusOK, "Project updated"
+	if !exists {
+		status, message = http.StatusCreated, "Project created"
+	}
+	log.Printf("%s %s via API", message, repo)
+
+	writeSuccess(w, status, openapi.StatusResponse{Message: message})
+}
+
+// handleDeleteProject handles DELETE /projects/{owner}/{name}
+func handleDeleteProject(w http.ResponseWriter, r *http.Request) {
+	repo := r.PathValue("owner") + "/" + r.PathValue("name")
+
+	projectsAPIMu.Lock()
+	defer projectsAPIMu.Unlock()
+
+	project, exists := lookupProject(repo)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Project %s not found", repo), http.StatusNotFound)
+		return
+	}
+	if err := authorizeConfig(contextIdentity(r.Context()), project); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// The URL may name the project by an alias
+	if projectsAPIPersist {
+		if err := persistProject(r.Context(), project.Repo, nil); err != nil {
+			log.Printf("Error persisting removal of project %s: %v", project.Repo, err)
+			http.Error(w, fmt.Sprintf("Failed to persist project removal: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if !removeProject(project.Repo) {
+		http.Error(w, fmt.Sprintf("Project %s not found", project.Repo), http.StatusNotFound)
+		return
+	}
+	log.Printf("Project deleted %s via API", project.Repo)
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: "Project deleted"})
+}
+
+// prepareRuntimeProject resolves a project submitted through the API the same
+// way loadConfig does, and validates it against the other active projects.
+// Only the templates it extends may reference environment variables and
+// secrets; the submitted project itself may not.
+func prepareRuntimeProject(raw Project) (Project, []error) {
+	if err := checkUntrustedReferences(raw); err != nil {
+		return Project{}, []error{err}
+	}
+	resolved, errs := applyTemplates(Config{Templates: activeTemplates(), Projects: []Project{raw}})
+	if len(errs) > 0 {
+		return Project{}, errs
+	}
+	p := resolved[0]
+
+	p, err := interpolateProject(p)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	p, err = resolveProjectSecrets(p)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	others := []Project{p}
+	for _, existing := range allProjects() {
+		if existing.Repo != p.Repo {
+			others = append(others, existing)
+		}
+	}
+	errs = append(errs, validateProjects(others)...)
+	return p, errs
+}
+
+// checkUntrustedReferences rejects ${VAR} and secret:// references in a project
+// submitted through the API, which would otherwise let callers read the
+// service's environment and secrets back from its commands and errors
+func checkUntrustedReferences(p Project) error {
+	var refs []string
+	transformProject(p, func(v string) string {
+		for _, match := range envVarPattern.FindAllString(v, -1) {
+			if !strings.HasPrefix(match, "$$") {
+				refs = append(refs, match)
+			}
+		}
+		refs = append(refs, secretPattern.FindAllString(v, -1)...)
+		return v
+	})
+	if len(refs) > 0 {
+		return fmt.Errorf("project %s may not reference environment variables or secrets through the API: %s", p.Repo, strings.Join(refs, ", "))
+	}
+	return nil
+}
+
+// persistProject writes a raw project definition back to the configuration
+// source, or removes it when p is nil
+func persistProject(ctx context.Context, repo string, p *Project) error {
+	if configSource == "redis" {
+		if p == nil {
+			if err := redisClient.HDel(ctx, configRedisKey, repo).Err(); err != nil {
+				return fmt.Errorf("failed to remove project from redis: %w", err)
+			}
+		} else {
+			data, err := json.Marshal(p)
+			if err != nil {
+				return fmt.Errorf("failed to marshal project: %w", err)
+			}
+			if err := redisClient.HSet(ctx, configRedisKey, repo, data).Err(); err != nil {
+				return fmt.Errorf("failed to store project in redis: %w", err)
+			}
+		}
+		// Let other instances sharing the hash pick up the change
+		return redisClient.Publish(ctx, configRedisChannel, repo).Err()
+	}
+	if configSource == "consul" || configSource == "etcd" {
+		return persistKVProject(ctx, repo, p)
+	}
+
+	dir := getConfigDir()
+	if dir == "" {
+		if isRemoteConfig(getConfigFile()) {
+			return errRemoteConfigReadOnly
+		}
+		return rewriteConfigFile(getConfigFile(), repo, p)
+	}
+
+	path, err := findProjectFile(dir, repo)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		if p == nil {
+			return nil
+		}
+		path = filepath.Join(dir, strings.ReplaceAll(repo, "/", "_")+".json")
+	}
+	return rewriteConfigFile(path, repo, p)
+}
+
+// findProjectFile returns the file in dir that defines repo, or "" if none does
+func findProjectFile(dir, repo string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFileName(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		config, err := readConfigFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		for _, existing := range config.Projects {
+			if existing.Repo == repo {
+				return path, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// rewriteConfigFile replaces, adds, or (when p is nil) removes repo in the
+// given config file, keeping the file's format and overall shape. The file is
+// written atomically so the config watcher never sees a partial write.
+func rewriteConfigFile(path, repo string, p *Project) error {
+	ext := filepath.Ext(path)
+
+	var config Config
+	shape := "object"
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		shape = "single"
+	case err != nil:
+		return fmt.Errorf("failed to read config file: %w", err)
+	default:
+		if config, err = parseConfig(data, ext); err != nil {
+			return fmt.Errorf("failed to parse config file: %w", err)
+		}
+		if isListDocument(data, ext) {
+			shape = "list"
+		} else if len(config.Projects) == 1 && config.Templates == nil {
+			if probe, _ := decodeConfig(data, ext); len(probe.Projects) == 0 {
+				shape = "single"
+			}
+		}
+	}
+
+	config = withRawProject(config, repo, p)
+
+	var doc any = config
+	switch shape {
+	case "list":
+		doc = config.Projects
+	case "single":
+		if len(config.Projects) == 0 {
+			return os.Remove(path)
+		}
+		if len(config.Projects) == 1 {
+			doc = config.Projects[0]
+		}
+	}
+
+	out, err := encodeDocument(doc, ext)
+	if err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+
+	return writeFileAtomic(path, out)
+}
+
+// writeFileAtomic replaces path with data via a temporary file and rename
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
+	return nil
+}
+
+// isListDocument reports whether the document's top level is a list of projects
+func isListDocument(data []byte, ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return false
+		}
+		return len(root.Content) > 0 && root.Content[0].Kind == yaml.SequenceNode
+	case ".toml":
+		return false
+	default:
+		trimmed := strings.TrimSpace(string(data))
+		return strings.HasPrefix(trimmed, "[")
+	}
+}
+
+// encodeDocument encodes v in the format implied by ext
+func encodeDocument(v any, ext string) ([]byte, error) {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		return yaml.Marshal(v)
+	case ".toml":
+		var buf strings.Builder
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+		return []byte(buf.String()), nil
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// subscribeSourceChannel processes fire-and-forget commands published on
+// SOURCE_CHANNEL, alongside the source lists. Pub/sub has no delivery
+// guarantee: commands published while the service is not subscribed are lost.
+func subscribeSourceChannel(ctx context.Context, rdb *redis.Client) {
+	pubsub := rdb.Subscribe(ctx, sourceChannel)
+
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-messages:
+				if !ok {
+					return
+				}
+				// Every instance receives the message, so only the leader acts on it
+				if !isLeader() {
+					continue
+				}
+				log.Printf("Received message from channel %s: %s", m.Channel, m.Payload)
+				if err := receiveMessage(withSource(ctx, m.Channel), rdb, m.Payload); err != nil {
+					log.Printf("Error processing message: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Subscribed to channel %s for messages", sourceChannel)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// QueueDepth is the number of messages waiting on a source list
+type QueueDepth = openapi.QueueDepth
+
+// queueDepths returns the length of every source list, in priority order
+func queueDepths(ctx context.Context, rdb *redis.Client) ([]QueueDepth, error) {
+	lists := getSourceLists()
+	cmds := make([]*redis.IntCmd, len(lists))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, list := range lists {
+			cmds[i] = pipe.LLen(ctx, list)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue depths: %w", err)
+	}
+
+	depths := make([]QueueDepth, len(lists))
+	for i, list := range lists {
+		depths[i] = QueueDepth{List: list, Depth: cmds[i].Val()}
+	}
+	return depths, nil
+}
+
+// handleListQueues handles GET /queues
+func handleListQueues(w http.ResponseWriter, r *http.Request) {
+	depths, err := queueDepths(r.Context(), redisClient)
+	if err != nil {
+		log.Printf("Error listing queues: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list queues: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, depths)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitIdle is how long a client's bucket is kept after its last request
+const rateLimitIdle = 10 * time.Minute
+
+// rateLimitExempt are probe and scrape endpoints, which are never limited
+var rateLimitExempt = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+var (
+	rateLimitMu sync.Mutex
+	// rateLimiters holds a token bucket for every client seen recently
+	rateLimiters = make(map[string]*clientLimiter)
+)
+
+// rateLimitKey identifies the client of a request by the identity that
+// authorized it or, failing that, its IP address
+func rateLimitKey(r *http.Request) string {
+	if name, _ := r.Context().Value(identityContextKey{}).(string); name != "" {
+		return "id:" + name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// reserveRequest takes a token from the client's bucket and returns how long
+// the client must wait when the bucket is empty
+func reserveRequest(key string) time.Duration {
+	rateLimitMu.Lock()
+	cl, ok := rateLimiters[key]
+	if !ok {
+		cl = &clientLimiter{limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(rateLimit)), max(rateLimitBurst, 1))}
+		rateLimiters[key] = cl
+	}
+	cl.lastSeen = time.Now()
+	rateLimitMu.Unlock()
+
+	res := cl.limiter.Reserve()
+	if delay := res.Delay(); delay > 0 {
+		// The request is refused, so give the token back
+		res.Cancel()
+		return delay
+	}
+	return 0
+}
+
+// limitRate answers 429 Too Many Requests, with the seconds to wait in
+// Retry-After, to clients that make more than RATE_LIMIT requests a minute
+// beyond a burst of RATE_LIMIT_BURST
+func limitRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimit <= 0 || rateLimitExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if delay := reserveRequest(rateLimitKey(r)); delay > 0 {
+			rateLimited.Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pruneRateLimiters forgets clients that have been idle for rateLimitIdle, so
+// the buckets do not grow without bound, until ctx is done
+func pruneRateLimiters(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitIdle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rateLimitMu.Lock()
+			for key, cl := range rateLimiters {
+				if time.Since(cl.lastSeen) > rateLimitIdle {
+					delete(rateLimiters, key)
+				}
+			}
+			rateLimitMu.Unlock()
+		}
+	}
+}
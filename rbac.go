@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// anyPermission grants every action, or every project, in a permission
+const anyPermission = "*"
+
+// configAction is the action of grants that allow changing the configuration:
+// config:its-the-vibe/* for those projects, or config:* for all of it
+const configAction = "config"
+
+// errForbidden marks an action the caller is not permitted to take
+var errForbidden = errors.New("forbidden")
+
+// permission allows an identity to take an action, or any action with "*",
+// on the projects a target addresses, such as restart:its-the-vibe/* or
+// down:group:core
+type permission struct {
+	Action string
+	Target string
+}
+
+var (
+	permissionsMu sync.RWMutex
+	// permissions maps an identity to what it may do. Actions are only
+	// restricted when at least one permission is configured.
+	permissions map[string][]permission
+)
+
+// loadPermissions reads identity=action:target grants from PERMISSIONS and
+// PERMISSIONS_FILE. The current permissions are kept if either cannot be
+// parsed.
+func loadPermissions() error {
+	grants := make(map[string][]permission)
+	add := func(entry, where string) error {
+		identity, grant, ok := strings.Cut(entry, "=")
+		identity = strings.TrimSpace(identity)
+		action, target, _ := strings.Cut(strings.TrimSpace(grant), ":")
+		p := permission{Action: strings.TrimSpace(action), Target: strings.TrimSpace(target)}
+		if !ok || identity == "" || p.Action == "" || p.Target == "" {
+			return fmt.Errorf("invalid permission in %s: expected identity=action:target", where)
+		}
+		if err := validatePermissionTarget(p.Target); err != nil {
+			return fmt.Errorf("invalid permission in %s: %w", where, err)
+		}
+		grants[identity] = append(grants[identity], p)
+		return nil
+	}
+
+	for _, entry := range strings.Split(getEnv("PERMISSIONS", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if err := add(entry, "PERMISSIONS"); err != nil {
+			return err
+		}
+	}
+
+	if file := getEnv("PERMISSIONS_FILE", ""); file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("failed to open permissions file: %w", err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := add(line, fmt.Sprintf("%s line %d", file, lineNum)); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read permissions file: %w", err)
+		}
+	}
+
+	permissionsMu.Lock()
+	permissions = grants
+	permissionsMu.Unlock()
+	return nil
+}
+
+// validatePermissionTarget rejects targets that could never match a project
+func validatePermissionTarget(target string) error {
+	if selector, ok := strings.CutPrefix(target, selectorPrefix); ok {
+		_, err := parseSelector(selector)
+		return err
+	}
+	if target != anyPermission && isGlob(target) {
+		if _, err := path.Match(target, ""); err != nil {
+			return fmt.Errorf("invalid repo pattern %q: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// rbacEnabled reports whether any permissions are configured
+func rbacEnabled() bool {
+	permissionsMu.RLock()
+	defer permissionsMu.RUnlock()
+	return len(permissions) > 0
+}
+
+func permissionsOf(identity string) []permission {
+	permissionsMu.RLock()
+	defer permissionsMu.RUnlock()
+	return permissions[identity]
+}
+
+func (p permission) allowsAction(action string) bool {
+	return p.Action == anyPermission || p.Action == action
+}
+
+// allows reports whether the permission covers taking action on the project
+func (p permission) allows(action string, project Project) bool {
+	return p.allowsAction(action) && (p.Target == anyPermission || targetIncludes(p.Target, project))
+}
+
+// authorizeProjects checks that identity may take action on every one of the
+// projects. Callers without an identity, such as the service itself and
+// requests on the unix socket, are not restricted.
+func authorizeProjects(identity, action string, projects []Project) error {
+	if identity == "" || !rbacEnabled() {
+		return nil
+	}
+	grants := permissionsOf(identity)
+	for _, project := range projects {
+		if !slices.ContainsFunc(grants, func(p permission) bool { return p.allows(action, project) }) {
+			return fmt.Errorf("%w: %s may not %s %s", errForbidden, identity, action, project.Repo)
+		}
+	}
+	return nil
+}
+
+// authorizeAction checks that identity may take action on every project the
+// target addresses. A target that addresses no projects needs a permission
+// for the target exactly as written.
+func authorizeAction(identity, action, target string) error {
+	if identity == "" || !rbacEnabled() {
+		return nil
+	}
+	var addressed []Project
+	for _, p := range allProjects() {
+		if targetIncludes(target, p) {
+			addressed = append(addressed, p)
+		}
+	}
+	if len(addressed) > 0 {
+		return authorizeProjects(identity, action, addressed)
+	}
+	if !slices.ContainsFunc(permissionsOf(identity), func(p permission) bool {
+		return p.allowsAction(action) && (p.Target == anyPermission || p.Target == target)
+	}) {
+		return fmt.Errorf("%w: %s may not %s %s", errForbidden, identity, action, target)
+	}
+	return nil
+}
+
+// authorizeConfig checks that identity may change the configuration of every
+// one of the projects, which needs a config grant or one for any action
+func authorizeConfig(identity string, projects ...Project) error {
+	return authorizeProjects(identity, configAction, projects)
+}
+
+// authorizeConfigAll checks that identity may change the configuration as a
+// whole, such as rolling it back or importing a snapshot, which needs config:*
+// or *:*
+func authorizeConfigAll(identity string) error {
+	if identity == "" || !rbacEnabled() {
+		return nil
+	}
+	if !slices.ContainsFunc(permissionsOf(identity), func(p permission) bool {
+		return p.allowsAction(configAction) && p.Target == anyPermission
+	}) {
+		return fmt.Errorf("%w: %s may not change the configuration", errForbidden, identity)
+	}
+	return nil
+}
+
+// authorizeMessage checks the action of a message. Status queries are not
+// restricted, and cancellations are checked against the job they cancel by
+// cancelScheduledMessage.
+func authorizeMessage(identity string, msg RedisMessage) error {
+	action, target, ok := msg.actionTarget()
+	if !ok {
+		return nil
+	}
+	return authorizeAction(identity, action, target)
+}
+
+// authorizeBatch checks every message of a JSON array. Items that cannot be
+// parsed are left to fail when the batch is processed.
+func authorizeBatch(identity string, data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+	for i, item := range items {
+		msg, err := decodeMessage(item)
+		if err != nil {
+			continue
+		}
+		if err := authorizeMessage(identity, msg); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// contextIdentity returns the identity of whoever sent the request or message
+// being handled, if known
+func contextIdentity(ctx context.Context) string {
+	identity, _ := ctx.Value(identityContextKey{}).(string)
+	return identity
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// desiredState returns the state the project should be in: an override set
+// through the API takes precedence over the project's desiredState setting.
+// It returns "" when no desired state is declared.
+func desiredState(ctx context.Context, rdb *redis.Client, p Project) (string, error) {
+	override, err := rdb.HGet(ctx, desiredStateKey, p.Repo).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("failed to read desired state of %s: %w", p.Repo, err)
+	}
+	if override != "" {
+		return override, nil
+	}
+	return p.DesiredState, nil
+}
+
+// observedState returns the state the project is actually in, using the
+// observer selected by RECONCILE_OBSERVER
+func observedState(ctx context.Context, p Project) (string, error) {
+	switch reconcileObserver {
+	case "docker":
+		return dockerObservedState(ctx, p)
+	case "health":
+		return healthObservedState(p), nil
+	}
+	return getProjectState(p.Repo).State, nil
+}
+
+// healthObservedState treats a healthy project as up and an unhealthy one as
+// down. Projects without a health result fall back to their tracked state.
+func healthObservedState(p Project) string {
+	s := getProjectState(p.Repo)
+	switch {
+	case p.HealthCheck == nil || s.Health == "":
+		return s.State
+	case s.State == stateStarting || s.State == stateStopping:
+		return s.State
+	case s.Health == healthHealthy:
+		return stateUp
+	}
+	return stateDown
+}
+
+// correctiveAction returns the action that moves a project from observed to
+// desired, or "" if none is needed. Projects in the middle of a transition are
+// left alone until it completes.
+func correctiveAction(desired, observed string) string {
+	switch observed {
+	case stateStarting, stateStopping:
+		return ""
+	}
+	switch {
+	case desired == stateUp && observed != stateUp:
+		return "up"
+	case desired == stateDown && observed != stateDown:
+		return "down"
+	}
+	return ""
+}
+
+// runReconciler periodically compares every project's desired state with its
+// observed state and dispatches up or down to converge them
+func runReconciler(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if isLeader() {
+					reconcile(ctx, rdb)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Reconciling desired project state every %s (observer: %s)", reconcileInterval, reconcileObserver)
+}
+
+func reconcile(ctx context.Context, rdb *redis.Client) {
+	for _, p := range allProjects() {
+		desired, err := desiredState(ctx, rdb, p)
+		if err != nil {
+			log.Printf("Reconcile: %v", err)
+			continue
+		}
+		if desired == "" {
+			continue
+		}
+		// Idle projects are brought back up by their activity signal instead,
+		// or they would be started again as soon as they were stopped
+		if desired == stateUp && getProjectState(p.Repo).IdleStopped {
+			continue
+		}
+
+		observed, err := observedState(ctx, p)
+		if err != nil {
+			log.Printf("Reconcile: failed to observe %s: %v", p.Repo, err)
+			continue
+		}
+
+		action := correctiveAction(desired, observed)
+		if action == "" {
+			continue
+		}
+		log.Printf("Reconcile: %s is %s but should be %s, dispatching %s", p.Repo, observed, desired, action)
+		msg := RedisMessage{Action: action, Repo: Target(p.Repo), CorrelationID: "reconcile-" + newCorrelationID()}
+		message, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("Reconcile: %v", err)
+			continue
+		}
+		if err := processMessage(ctx, rdb, string(message)); err != nil {
+			log.Printf("Reconcile: failed to dispatch %s for %s: %v", action, p.Repo, err)
+		}
+	}
+}
+
+// validateDesiredState checks a desired state value
+func validateDesiredState(state string) error {
+	switch state {
+	case "", stateUp, stateDown:
+		return nil
+	}
+	return fmt.Errorf("invalid desired state %q (expected %s or %s)", state, stateUp, stateDown)
+}
+
+// handleSetDesiredState handles PUT /projects/{owner}/{name}/desired with a
+// body of {"state": "up"}. An empty state removes the override.
+func handleSetDesiredState(w http.ResponseWriter, r *http.Request) {
+	repo := r.PathValue("owner") + "/" + r.PathValue("name")
+	project, exists := lookupProject(repo)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Project %s not found", repo), http.StatusNotFound)
+		return
+	}
+
+	var body openapi.DesiredStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	state := string(body.State)
+	if err := validateDesiredState(state); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if state != "" {
+		if err := authorizeProjects(contextIdentity(r.Context()), state, []Project{project}); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	var err error
+	message := fmt.Sprintf("Desired state of %s set to %s", project.Repo, state)
+	if state == "" {
+		err = redisClient.HDel(r.Context(), desiredStateKey, project.Repo).Err()
+		message = fmt.Sprintf("Desired state override of %s removed", project.Repo)
+	} else {
+		err = redisClient.HSet(r.Context(), desiredStateKey, project.Repo, state).Err()
+	}
+	if err != nil {
+		log.Printf("Error setting desired state of %s: %v", project.Repo, err)
+		http.Error(w, fmt.Sprintf("Failed to set desired state: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Println(message)
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: message})
+}
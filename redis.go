@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// targetRedisClient holds the Poppit target queues when they live on a
+	// different Redis server from the source lists
+	targetRedisClient *redis.Client
+
+	sourceRedisHealthy atomic.Bool
+	targetRedisHealthy atomic.Bool
+)
+
+// redisOptions returns the connection settings for a Redis server, from
+// rawURL when set and from the discrete address and password otherwise. The
+// URL may carry an ACL username, password, DB index, and rediss:// for TLS.
+func redisOptions(rawURL, addr, password string) (*redis.Options, error) {
+	if rawURL == "" {
+		return &redis.Options{Addr: addr, Password: password, DB: 0}, nil
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	return opts, nil
+}
+
+// targetRedis returns the client for the Poppit target queues: the one for
+// TARGET_REDIS_URL or TARGET_REDIS_ADDR if set, and the source client otherwise
+func targetRedis(rdb *redis.Client) *redis.Client {
+	if targetRedisClient != nil {
+		return targetRedisClient
+	}
+	return rdb
+}
+
+// runRedisHealthChecks pings the source and target Redis servers every
+// REDIS_HEALTH_INTERVAL, logging when either becomes unreachable or recovers
+func runRedisHealthChecks(ctx context.Context, rdb *redis.Client) {
+	sourceRedisHealthy.Store(true)
+	targetRedisHealthy.Store(true)
+
+	go func() {
+		ticker := time.NewTicker(redisHealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkRedis(ctx, "source", rdb, &sourceRedisHealthy)
+				if targetRedisClient != nil {
+					checkRedis(ctx, "target", targetRedisClient, &targetRedisHealthy)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Checking Redis connections every %s", redisHealthInterval)
+}
+
+// checkRedis pings one Redis server and records whether it answered
+func checkRedis(ctx context.Context, name string, rdb *redis.Client, healthy *atomic.Bool) {
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err := rdb.Ping(pingCtx).Err()
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		if healthy.Swap(false) {
+			log.Printf("Lost connection to %s Redis at %s: %v", name, rdb.Options().Addr, err)
+		}
+		return
+	}
+	if !healthy.Swap(true) {
+		log.Printf("Reconnected to %s Redis at %s", name, rdb.Options().Addr)
+		requestOutboxFlush()
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// processingHeartbeatTTL is how long a processing list is considered owned
+// after its instance last read from the source lists
+const processingHeartbeatTTL = 30 * time.Second
+
+// processingList is this instance's list of messages taken from the source
+// lists but not yet fully processed
+func processingList() string {
+	return processingListPrefix + instanceID
+}
+
+// processingHeartbeat is the key that marks a processing list as owned by a
+// running instance
+func processingHeartbeat(list string) string {
+	return list + ":alive"
+}
+
+// consumeReliably takes one message, if any, from the source lists and
+// processes it. The message is moved to the processing list rather than
+// popped, and only removed from there once processing has finished, so a
+// crash in between leaves it to be recovered. Source lists are tried in
+// priority order; when all are empty, the primary list is watched with
+// BLMOVE for up to a second.
+func consumeReliably(ctx context.Context, rdb *redis.Client) {
+	processing := processingList()
+	if err := rdb.Set(ctx, processingHeartbeat(processing), instanceID, processingHeartbeatTTL).Err(); err != nil && ctx.Err() == nil {
+		log.Printf("Error refreshing processing list heartbeat: %v", err)
+	}
+
+	var source, message string
+	for _, list := range getSourceLists() {
+		m, err := rdb.LMove(ctx, list, processing, "LEFT", "RIGHT").Result()
+		if err == nil {
+			source, message = list, m
+			break
+		}
+		if !errors.Is(err, redis.Nil) {
+			if ctx.Err() == nil {
+				log.Printf("Error reading from Redis: %v", err)
+				time.Sleep(time.Second)
+			}
+			return
+		}
+	}
+	if message == "" {
+		source = getSourceList()
+		m, err := rdb.BLMove(ctx, source, processing, "LEFT", "RIGHT", time.Second).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				log.Printf("Error reading from Redis: %v", err)
+				time.Sleep(time.Second)
+			}
+			return
+		}
+		message = m
+	}
+
+	log.Printf("Received message from %s: %s", source, message)
+	if err := receiveMessage(withSource(ctx, source), rdb, message); err != nil {
+		log.Printf("Error processing message: %v", err)
+	}
+	if ctx.Err() != nil {
+		// Interrupted: leave the message to be recovered
+		return
+	}
+	// Failed messages are removed too, as processing them again would fail again
+	if err := rdb.LRem(ctx, processing, 1, message).Err(); err != nil {
+		log.Printf("Error removing message from %s: %v", processing, err)
+	}
+}
+
+// recoverOrphanedMessages returns messages left in the processing lists of
+// instances that are no longer running, including this instance's own list
+// from before a restart, to the front of the primary source list
+func recoverOrphanedMessages(ctx context.Context, rdb *redis.Client) {
+	target := getSourceList()
+	iter := rdb.Scan(ctx, 0, processingListPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		list := iter.Val()
+		if rdb.Type(ctx, list).Val() != "list" {
+			continue
+		}
+		if alive, err := rdb.Exists(ctx, processingHeartbeat(list)).Result(); err != nil || (alive > 0 && list != processingList()) {
+			continue
+		}
+
+		// Moving from the tail to the head keeps the original order
+		recovered := 0
+		for {
+			_, err := rdb.LMove(ctx, list, target, "RIGHT", "LEFT").Result()
+			if err != nil {
+				if !errors.Is(err, redis.Nil) {
+					log.Printf("Error recovering messages from %s: %v", list, err)
+				}
+				break
+			}
+			recovered++
+		}
+		if recovered > 0 {
+			log.Printf("Recovered %d unprocessed messages from %s onto %s", recovered, list, target)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Error scanning for processing lists: %v", err)
+	}
+}
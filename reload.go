@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	// settingsMu guards the environment-derived settings that can change on SIGHUP
+	settingsMu sync.RWMutex
+
+	envFile string
+	// envFileOriginals remembers the process environment value (if any) of
+	// every variable overridden by ENV_FILE, so removed entries can be restored
+	envFileOriginals = make(map[string]*string)
+)
+
+// loadReloadableSettings reads the settings that can be changed without a restart
+func loadReloadableSettings() {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	sourceLists = parseSourceLists(getEnv("SOURCE_LIST", "service:commands"))
+	priorityLevels = parsePriorityLevels(getEnv("PRIORITY_LEVELS", "high,normal,low"))
+	configFile = getEnv("CONFIG_FILE", "projects.json")
+	configDir = getEnv("CONFIG_DIR", "")
+	defaultTargetQueue = getEnv("TARGET_QUEUE", "poppit:notifications")
+}
+
+// getSourceList returns the primary source list, which messages queued by the
+// service itself are pushed onto
+func getSourceList() string {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return sourceLists[0]
+}
+
+func getPriorityLevels() []string {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return priorityLevels
+}
+
+func getConfigFile() string {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return configFile
+}
+
+func getConfigDir() string {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return configDir
+}
+
+func getDefaultTargetQueue() string {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return defaultTargetQueue
+}
+
+// loadEnvFile applies KEY=VALUE pairs from ENV_FILE to the process environment.
+// Values in the file take precedence over the existing environment. Variables
+// that were set by a previous load but are no longer in the file are restored.
+func loadEnvFile() error {
+	if envFile == "" {
+		return nil
+	}
+
+	f, err := os.Open(envFile)
+	if err != nil {
+		return fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return fmt.Errorf("invalid env file line %d: %q", lineNum, line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	for key, original := range envFileOriginals {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if original != nil {
+			os.Setenv(key, *original)
+		} else {
+			os.Unsetenv(key)
+		}
+		delete(envFileOriginals, key)
+	}
+
+	for key, value := range values {
+		if _, tracked := envFileOriginals[key]; !tracked {
+			if original, ok := os.LookupEnv(key); ok {
+				envFileOriginals[key] = &original
+			} else {
+				envFileOriginals[key] = nil
+			}
+		}
+		os.Setenv(key, value)
+	}
+
+	return nil
+}
+
+// reload re-reads environment-derived settings and the project configuration.
+// Settings that require reconnecting or rebinding are only reported if changed.
+func reload() {
+	log.Println("Reloading configuration...")
+
+	if err := loadEnvFile(); err != nil {
+		log.Printf("Failed to reload env file, keeping previous environment: %v", err)
+	}
+
+	if getEnv("REDIS_ADDR", "localhost:6379") != redisAddr || getEnv("REDIS_PASSWORD", "") != redisPassword || getEnv("REDIS_URL", "") != redisURL {
+		log.Println("Redis connection settings changed; restart the service to apply them")
+	}
+	if getEnv("PORT", "8080") != httpPort {
+		log.Println("HTTP port changed; restart the service to apply it")
+	}
+
+	loadReloadableSettings()
+	if err := loadAPIKeys(); err != nil {
+		log.Printf("Failed to reload API keys, keeping previous keys: %v", err)
+	}
+	if err := loadPermissions(); err != nil {
+		log.Printf("Failed to reload permissions, keeping previous permissions: %v", err)
+	}
+	if err := loadAllowedNetworks(); err != nil {
+		log.Printf("Failed to reload allowed networks, keeping previous networks: %v", err)
+	}
+	if err := loadWebhookSecrets(); err != nil {
+		log.Printf("Failed to reload webhook secrets, keeping previous secrets: %v", err)
+	}
+	if err := loadTLSCertificate(); err != nil {
+		log.Printf("Failed to reload TLS certificate, keeping previous certificate: %v", err)
+	}
+	log.Printf("Listening for messages on lists: %s", strings.Join(getSourceLists(), ", "))
+
+	if err := loadConfig(); err != nil {
+		log.Printf("Failed to reload configuration, keeping previous config: %v", err)
+	}
+}
+
+// handleReloadSignals triggers a reload every time the process receives SIGHUP
+func handleReloadSignals(ctx context.Context) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hupChan)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				log.Println("Received SIGHUP")
+				reload()
+			}
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern matches request IDs accepted from clients; others are
+// replaced so they cannot inject anything into the logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// accessLogger writes one JSON line per HTTP request
+var accessLogger = slog.New(slog.NewJSONHandler(redactingWriter{w: os.Stderr}, nil))
+
+// requestInfo describes the HTTP request a context belongs to. The identity
+// is filled in once the request has been authorized.
+type requestInfo struct {
+	ID       string
+	Identity string
+}
+
+type requestInfoContextKey struct{}
+
+func getRequestInfo(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoContextKey{}).(*requestInfo)
+	return info
+}
+
+// requestID returns the ID of the HTTP request ctx belongs to, if any
+func requestID(ctx context.Context) string {
+	if info := getRequestInfo(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
+
+// statusRecorder remembers the status and size of a response for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// server-sent events need to flush
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Hijack hands the connection over for a WebSocket upgrade
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rec.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+// tagRequest gives every request an ID, taken from its X-Request-ID header or
+// generated, which is returned in the response's X-Request-ID header, and
+// writes an access log entry once the request has been handled
+func tagRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newCorrelationID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		info := &requestInfo{ID: id}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoContextKey{}, info))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		if !accessLog {
+			return
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		accessLogger.LogAttrs(r.Context(), slog.LevelInfo, "http request",
+			slog.String("requestId", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Float64("durationMs", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remoteAddr", r.RemoteAddr),
+			slog.String("identity", info.Identity),
+			slog.String("correlationId", rec.Header().Get("X-Correlation-ID")),
+			slog.String("userAgent", r.UserAgent()),
+		)
+	})
+}
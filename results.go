@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// resultSource is the history source of results reported by Poppit
+const resultSource = "poppit"
+
+// PoppitResult is what Poppit pushes onto RESULTS_QUEUE once it has run the
+// commands of a notification. The repo, type, and correlation ID are copied
+// from the notification.
+type PoppitResult struct {
+	Repo          string `json:"repo"`
+	Type          string `json:"type"`
+	CorrelationID string `json:"correlationId"`
+	ExitCode      int    `json:"exitCode"`
+	Error         string `json:"error,omitempty"`
+}
+
+// action returns the action of the notification, e.g. up for service-up
+func (r PoppitResult) action() string {
+	return strings.TrimPrefix(r.Type, "service-")
+}
+
+// failure describes why the commands failed, or returns nil if they succeeded
+func (r PoppitResult) failure() error {
+	switch {
+	case r.Error != "" && r.ExitCode != 0:
+		return fmt.Errorf("exit code %d: %s", r.ExitCode, r.Error)
+	case r.Error != "":
+		return errors.New(r.Error)
+	case r.ExitCode != 0:
+		return fmt.Errorf("exit code %d", r.ExitCode)
+	}
+	return nil
+}
+
+// runResultsConsumer takes the results Poppit reports from RESULTS_QUEUE on
+// the target Redis while this instance leads
+func runResultsConsumer(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		for ctx.Err() == nil {
+			if !isLeader() {
+				time.Sleep(time.Second)
+				continue
+			}
+			result, err := targetRedis(rdb).BLPop(ctx, 5*time.Second, resultsQueue).Result()
+			if err != nil {
+				if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+					log.Printf("Error reading results from %s: %v", resultsQueue, err)
+					time.Sleep(time.Second)
+				}
+				continue
+			}
+			if err := applyResult(ctx, rdb, result[1]); err != nil {
+				log.Printf("Error applying result %s: %v", result[1], err)
+			}
+		}
+	}()
+
+	log.Printf("Tracking Poppit results on %s", resultsQueue)
+}
+
+// applyResult applies a result that Poppit reported on RESULTS_QUEUE
+func applyResult(ctx context.Context, rdb *redis.Client, data string) error {
+	var result PoppitResult
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return fmt.Errorf("failed to parse result: %w", err)
+	}
+	if result.Repo == "" || result.Type == "" || result.CorrelationID == "" {
+		return errors.New("result must contain repo, type, and correlationId")
+	}
+	acknowledge(ctx, rdb, pendingAck{CorrelationID: result.CorrelationID, Repo: result.Repo, Action: result.action()})
+	recordResult(ctx, rdb, resultSource, result)
+	return nil
+}
+
+// recordResult records the outcome of a dispatched action in the history,
+// moves the project to its new state if the result is for its last action,
+// and completes the job tracking the message. source names what ran the
+// commands, such as poppit.
+func recordResult(ctx context.Context, rdb *redis.Client, source string, result PoppitResult) {
+	action := result.action()
+	failure := result.failure()
+
+	entry := HistoryEntry{Source: source, Repo: result.Repo, Action: action, Outcome: outcomeCompleted, CorrelationID: result.CorrelationID}
+	event := Event{Type: eventResult, Repo: result.Repo, Action: action, Source: source, Message: string(outcomeCompleted), CorrelationID: result.CorrelationID}
+	if failure != nil {
+		entry.Outcome, entry.Error = outcomeFailed, failure.Error()
+		event.Message = fmt.Sprintf("%s: %v", outcomeFailed, failure)
+		log.Printf("[%s] %s reports %s of %s failed: %v", result.CorrelationID, source, action, result.Repo, failure)
+	} else {
+		log.Printf("[%s] %s reports %s of %s completed", result.CorrelationID, source, action, result.Repo)
+	}
+	recordHistory(ctx, rdb, entry)
+	actionResults.WithLabelValues(action, result.Repo, string(entry.Outcome)).Inc()
+	publishEvent(event)
+
+	// A result for an action that has since been superseded no longer says
+	// anything about the project's state
+	if s := getProjectState(result.Repo); s.LastCorrelationID == result.CorrelationID && s.LastAction == action {
+		completeAction(result.Repo, action, s.LastActionAt, failure == nil)
+	} else {
+		log.Printf("[%s] Not updating the state of %s: the result is not for its last action", result.CorrelationID, result.Repo)
+	}
+
+	// A job covering several projects fails if any of them fails
+	if failure != nil {
+		updateJob(ctx, rdb, result.CorrelationID, jobFailed, fmt.Errorf("%s: %w", result.Repo, failure))
+	} else if job, err := getJob(ctx, rdb, result.CorrelationID); err == nil && job.State == jobDispatched {
+		updateJob(ctx, rdb, result.CorrelationID, jobCompleted, nil)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+// scheduledMessage is a message held in the schedule until it is due. The ID
+// identifies the job for cancellation and keeps identical messages scheduled
+// for the same time distinct in the set.
+type scheduledMessage struct {
+	ID      string       `json:"id"`
+	DueAt   time.Time    `json:"dueAt"`
+	Message RedisMessage `json:"message"`
+}
+
+var errJobNotFound = errors.New("scheduled job not found")
+
+// dispatchTime returns when the message should be dispatched, from either its
+// RFC3339 "at" timestamp or its "delay" duration. It returns the zero time for
+// messages that should be dispatched immediately.
+func (msg RedisMessage) dispatchTime() (time.Time, error) {
+	switch {
+	case msg.At != "" && msg.Delay != "":
+		return time.Time{}, fmt.Errorf("message cannot contain both 'at' and 'delay'")
+	case msg.At != "":
+		t, err := time.Parse(time.RFC3339, msg.At)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid at timestamp %q: %w", msg.At, err)
+		}
+		return t, nil
+	case msg.Delay != "":
+		d, err := time.ParseDuration(msg.Delay)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid delay %q: %w", msg.Delay, err)
+		}
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid delay %q: must not be negative", msg.Delay)
+		}
+		return time.Now().Add(d), nil
+	}
+	return time.Time{}, nil
+}
+
+// newCorrelationID returns a random identifier for messages that do not carry one
+func newCorrelationID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// expired reports whether the message's expiresAt time has passed
+func (msg RedisMessage) expired() (bool, error) {
+	if msg.ExpiresAt == "" {
+		return false, nil
+	}
+	t, err := time.Parse(time.RFC3339, msg.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("invalid expiresAt timestamp %q: %w", msg.ExpiresAt, err)
+	}
+	return !time.Now().Before(t), nil
+}
+
+// scheduleMessage stores msg in the Redis schedule so that it is dispatched
+// at the given time, even if the service restarts in the meantime. It returns
+// the job ID that can be used to cancel it.
+func scheduleMessage(ctx context.Context, rdb *redis.Client, msg RedisMessage, at time.Time) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate schedule id: %w", err)
+	}
+	id := hex.EncodeToString(b)
+	msg.At = ""
+	msg.Delay = ""
+	if identity := contextIdentity(ctx); identity != "" {
+		msg.OnBehalfOf = identity
+	}
+
+	data, err := json.Marshal(scheduledMessage{ID: id, DueAt: at.UTC(), Message: msg})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal scheduled message: %w", err)
+	}
+	if err := rdb.ZAdd(ctx, scheduleKey, redis.Z{Score: float64(at.UnixMilli()), Member: data}).Err(); err != nil {
+		return "", fmt.Errorf("failed to schedule message: %w", err)
+	}
+
+	action, target, _ := msg.actionTarget()
+	log.Printf("[%s] Scheduled %s for %s at %s as job %s", msg.CorrelationID, action, target, at.Format(time.RFC3339), id)
+	return id, nil
+}
+
+// listScheduledMessages returns the pending scheduled jobs, soonest first
+func listScheduledMessages(ctx context.Context, rdb *redis.Client) ([]scheduledMessage, error) {
+	entries, err := rdb.ZRange(ctx, scheduleKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedule: %w", err)
+	}
+	jobs := make([]scheduledMessage, 0, len(entries))
+	for _, entry := range entries {
+		var job scheduledMessage
+		if err := json.Unmarshal([]byte(entry), &job); err != nil {
+			log.Printf("Skipping unreadable scheduled message: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// cancelScheduledMessage removes the pending job with the given ID
+func cancelScheduledMessage(ctx context.Context, rdb *redis.Client, id string) error {
+	entries, err := rdb.ZRange(ctx, scheduleKey, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read schedule: %w", err)
+	}
+	for _, entry := range entries {
+		var job scheduledMessage
+		if json.Unmarshal([]byte(entry), &job) != nil || job.ID != id {
+			continue
+		}
+		// Cancelling needs the same permission as the job's own action
+		if err := authorizeMessage(contextIdentity(ctx), job.Message); err != nil {
+			return err
+		}
+		removed, err := rdb.ZRem(ctx, scheduleKey, entry).Result()
+		if err != nil {
+			return fmt.Errorf("failed to cancel job %s: %w", id, err)
+		}
+		if removed == 0 {
+			// Dispatched between the read and the removal
+			break
+		}
+		action, target, _ := job.Message.actionTarget()
+		log.Printf("[%s] Cancelled scheduled job %s (%s for %s)", job.Message.CorrelationID, id, action, target)
+		return nil
+	}
+	return errJobNotFound
+}
+
+// handleListScheduled handles GET /scheduled
+func handleListScheduled(w http.ResponseWriter, r *http.Request) {
+	jobs, err := listScheduledMessages(r.Context(), redisClient)
+	if err != nil {
+		log.Printf("Error listing scheduled jobs: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list scheduled jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// handleCancelScheduled handles DELETE /scheduled/{id}
+func handleCancelScheduled(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := cancelScheduledMessage(r.Context(), redisClient, id); err != nil {
+		if errors.Is(err, errJobNotFound) {
+			http.Error(w, fmt.Sprintf("Scheduled job %s not found", id), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		log.Printf("Error cancelling scheduled job %s: %v", id, err)
+		http.Error(w, fmt.Sprintf("Failed to cancel job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: fmt.Sprintf("Cancelled scheduled job %s", id)})
+}
+
+// runScheduler polls the schedule and queues messages on the source list for
+// their priority once they are due. Each entry is removed before it is queued,
+// so when several instances share the schedule only the one that removed it
+// queues it.
+func runScheduler(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		ticker := time.NewTicker(schedulePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if isLeader() {
+					dispatchDueMessages(ctx, rdb)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Dispatching scheduled messages from %s", scheduleKey)
+}
+
+func dispatchDueMessages(ctx context.Context, rdb *redis.Client) {
+	due, err := rdb.ZRangeByScore(ctx, scheduleKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: 100,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Error reading schedule: %v", err)
+		}
+		return
+	}
+
+	for _, entry := range due {
+		removed, err := rdb.ZRem(ctx, scheduleKey, entry).Result()
+		if err != nil {
+			log.Printf("Error removing scheduled message: %v", err)
+			continue
+		}
+		if removed == 0 {
+			// Another instance claimed it
+			continue
+		}
+
+		var scheduled scheduledMessage
+		if err := json.Unmarshal([]byte(entry), &scheduled); err != nil {
+			log.Printf("Discarding unreadable scheduled message: %v", err)
+			continue
+		}
+		if err := enqueueMessage(ctx, rdb, scheduled.Message); err != nil {
+			log.Printf("Error queueing scheduled message: %v", err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule runs a project action on a recurring cron schedule
+type Schedule struct {
+	Name   string `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+	Cron   string `json:"cron" yaml:"cron" toml:"cron"`
+	Action string `json:"action" yaml:"action" toml:"action"`
+}
+
+// ScheduleStatus describes a configured schedule for the schedules API
+type ScheduleStatus = openapi.ScheduleStatus
+
+var (
+	cronParseMu sync.Mutex
+	// cronCache holds parsed cron expressions keyed by their spec
+	cronCache = map[string]cron.Schedule{}
+)
+
+// scheduleName returns the name used to address the schedule at index i,
+// which is its configured name or its position in the list
+func scheduleName(s Schedule, i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return strconv.Itoa(i)
+}
+
+func scheduleID(repo, name string) string {
+	return repo + "#" + name
+}
+
+// parseCron parses a standard five-field cron expression or descriptor such as
+// @daily, caching the result
+func parseCron(spec string) (cron.Schedule, error) {
+	cronParseMu.Lock()
+	defer cronParseMu.Unlock()
+	if sched, ok := cronCache[spec]; ok {
+		return sched, nil
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+	cronCache[spec] = sched
+	return sched, nil
+}
+
+// validateSchedules checks that every schedule has a valid cron expression, a
+// unique name, and an action the project defines
+func validateSchedules(p Project) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, s := range p.Schedules {
+		name := scheduleName(s, i)
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("project %s: duplicate schedule name %q", p.Repo, name))
+		}
+		seen[name] = true
+
+		if _, err := parseCron(s.Cron); err != nil {
+			errs = append(errs, fmt.Errorf("project %s: schedule %s: %w", p.Repo, name, err))
+		}
+		if s.Action == "" {
+			errs = append(errs, fmt.Errorf("project %s: schedule %s: action must not be empty", p.Repo, name))
+		} else if _, ok := p.Actions[s.Action]; !ok && !slices.Contains(builtinActions, s.Action) {
+			errs = append(errs, fmt.Errorf("project %s: schedule %s: unknown action %q", p.Repo, name, s.Action))
+		} else if s.Action == "restart" && !p.canRestart() {
+			errs = append(errs, fmt.Errorf("project %s: schedule %s: restart requires restartCommands", p.Repo, name))
+		}
+	}
+	return errs
+}
+
+// runCronSchedules fires project schedules as they come due. The projects are
+// re-read on every tick so configuration reloads take effect immediately.
+func runCronSchedules(ctx context.Context, rdb *redis.Client) {
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if isLeader() {
+					fireDueSchedules(ctx, rdb, last, now)
+				}
+				last = now
+			}
+		}
+	}()
+
+	log.Println("Running project cron schedules")
+}
+
+// fireDueSchedules dispatches every schedule with a run time in (since, now]
+func fireDueSchedules(ctx context.Context, rdb *redis.Client, since, now time.Time) {
+	for _, p := range allProjects() {
+		for i, s := range p.Schedules {
+			sched, err := parseCron(s.Cron)
+			if err != nil || sched.Next(since).After(now) {
+				continue
+			}
+
+			id := scheduleID(p.Repo, scheduleName(s, i))
+			paused, err := rdb.SIsMember(ctx, schedulesPausedKey, id).Result()
+			if err != nil {
+				log.Printf("Error checking schedule %s: %v", id, err)
+				continue
+			}
+			if paused {
+				log.Printf("Skipping paused schedule %s", id)
+				continue
+			}
+
+			log.Printf("Schedule %s triggered %s for %s", id, s.Action, p.Repo)
+			message, err := json.Marshal(RedisMessage{Action: s.Action, Repo: Target(p.Repo)})
+			if err != nil {
+				log.Printf("Error running schedule %s: %v", id, err)
+				continue
+			}
+			if err := processMessage(ctx, rdb, string(message)); err != nil {
+				log.Printf("Error running schedule %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// handleListSchedules handles GET /schedules
+func handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	paused, err := redisClient.SMembers(r.Context(), schedulesPausedKey).Result()
+	if err != nil {
+		log.Printf("Error listing schedules: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list schedules: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	projects := allProjects()
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Repo < projects[j].Repo })
+
+	now := time.Now()
+	statuses := []ScheduleStatus{}
+	for _, p := range projects {
+		for i, s := range p.Schedules {
+			name := scheduleName(s, i)
+			status := ScheduleStatus{
+				ID:     scheduleID(p.Repo, name),
+				Repo:   p.Repo,
+				Name:   name,
+				Cron:   s.Cron,
+				Action: s.Action,
+			}
+			status.Paused = slices.Contains(paused, status.ID)
+			if sched, err := parseCron(s.Cron); err != nil {
+				status.Error = err.Error()
+			} else {
+				status.Next = sched.Next(now)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// handlePauseSchedule handles POST /projects/{owner}/{name}/schedules/{schedule}/pause
+func handlePauseSchedule(w http.ResponseWriter, r *http.Request) {
+	setSchedulePaused(w, r, true)
+}
+
+// handleResumeSchedule handles POST /projects/{owner}/{name}/schedules/{schedule}/resume
+func handleResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	setSchedulePaused(w, r, false)
+}
+
+func setSchedulePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	repo := r.PathValue("owner") + "/" + r.PathValue("name")
+	name := r.PathValue("schedule")
+
+	project, exists := lookupProject(repo)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Project %s not found", repo), http.StatusNotFound)
+		return
+	}
+	found := false
+	for i, s := range project.Schedules {
+		if scheduleName(s, i) == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("Schedule %s not found for project %s", name, repo), http.StatusNotFound)
+		return
+	}
+	if err := authorizeConfig(contextIdentity(r.Context()), project); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	id := scheduleID(project.Repo, name)
+	var err error
+	message := "Schedule paused"
+	if paused {
+		err = redisClient.SAdd(r.Context(), schedulesPausedKey, id).Err()
+	} else {
+		err = redisClient.SRem(r.Context(), schedulesPausedKey, id).Err()
+		message = "Schedule resumed"
+	}
+	if err != nil {
+		log.Printf("Error updating schedule %s: %v", id, err)
+		http.Error(w, fmt.Sprintf("Failed to update schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("%s: %s", message, id)
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: message})
+}
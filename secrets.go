@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secretPattern matches secret://NAME references in configuration values
+var secretPattern = regexp.MustCompile(`secret://([A-Za-z_][A-Za-z0-9_.-]*)`)
+
+const redactedPlaceholder = "[REDACTED]"
+
+var (
+	secretsMu sync.RWMutex
+	// secretValues holds every resolved secret so it can be redacted from logs
+	secretValues = make(map[string]bool)
+)
+
+// resolveProjectSecrets replaces secret://NAME references in the project with
+// values from the environment variable NAME or the file SECRETS_DIR/NAME.
+func resolveProjectSecrets(p Project) (Project, error) {
+	var unresolved []string
+	p = transformProject(p, func(v string) string {
+		return secretPattern.ReplaceAllStringFunc(v, func(match string) string {
+			name := match[len("secret://"):]
+			value, err := lookupSecret(name)
+			if err != nil {
+				unresolved = append(unresolved, name)
+				return match
+			}
+			registerSecret(value)
+			return value
+		})
+	})
+
+	if len(unresolved) > 0 {
+		return p, fmt.Errorf("project %s references unresolved secrets: %s", p.Repo, strings.Join(unresolved, ", "))
+	}
+	return p, nil
+}
+
+// lookupSecret returns the value of the named secret, preferring the
+// environment over the secrets directory
+func lookupSecret(name string) (string, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	if secretsDir == "" {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+	data, err := os.ReadFile(filepath.Join(secretsDir, name))
+	if err != nil {
+		return "", fmt.Errorf("secret %s not found: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func registerSecret(value string) {
+	if value == "" {
+		return
+	}
+	secretsMu.Lock()
+	secretValues[value] = true
+	secretsMu.Unlock()
+}
+
+// redact replaces every known secret value in s with a placeholder
+func redact(s []byte) []byte {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	if len(secretValues) == 0 {
+		return s
+	}
+
+	// Replace longer secrets first so one secret containing another is fully hidden
+	values := make([]string, 0, len(secretValues))
+	for v := range secretValues {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, v := range values {
+		s = bytes.ReplaceAll(s, []byte(v), []byte(redactedPlaceholder))
+	}
+	return s
+}
+
+// redactingWriter filters secret values out of everything written through it
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := rw.w.Write(redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
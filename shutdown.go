@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SHUTDOWN_DOWN modes
+const (
+	shutdownDownOff     = "off"
+	shutdownDownAll     = "all"
+	shutdownDownFlagged = "flagged"
+)
+
+// shutdownProjects dispatches down for the projects selected by SHUTDOWN_DOWN
+// before the service exits, stopping dependents before their dependencies
+func shutdownProjects(ctx context.Context, rdb *redis.Client) {
+	switch shutdownDown {
+	case shutdownDownAll, shutdownDownFlagged:
+	case shutdownDownOff:
+		return
+	default:
+		log.Printf("Not stopping projects on shutdown: invalid SHUTDOWN_DOWN %q", shutdownDown)
+		return
+	}
+	if !isLeader() {
+		log.Println("Not stopping projects on shutdown: this instance is not the leader")
+		return
+	}
+
+	var projects []Project
+	for _, p := range allProjects() {
+		if shutdownDown == shutdownDownAll || p.DownOnShutdown {
+			projects = append(projects, p)
+		}
+	}
+	if len(projects) == 0 {
+		return
+	}
+
+	msg := RedisMessage{CorrelationID: "shutdown-" + newCorrelationID()}
+	msg.Force = true
+	tiers := dependencyTiers(projects, "down")
+	slices.Reverse(tiers)
+	log.Printf("[%s] Stopping %s before shutting down", msg.CorrelationID, tierRepos(tiers))
+
+	// Actions run by the service are cancelled when it exits, so wait for
+	// each tier to finish, up to SHUTDOWN_TIMEOUT in total, before stopping
+	// the next one and before returning
+	waitCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	for _, tier := range tiers {
+		for _, project := range tier {
+			if err := dispatchAction(ctx, rdb, msg, project, "down"); err != nil {
+				log.Printf("[%s] Error stopping %s: %v", msg.CorrelationID, project.Repo, err)
+			}
+		}
+		if !waitExecutions(waitCtx) {
+			log.Printf("[%s] Gave up waiting for projects to stop after %s", msg.CorrelationID, shutdownTimeout)
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	signatureHeader = "X-Hub-Signature-256"
+	signaturePrefix = "sha256="
+	// maxSignedBodySize bounds the body read into memory to check its signature
+	maxSignedBodySize = 1 << 20
+)
+
+var (
+	webhookSecretsMu sync.RWMutex
+	// webhookSecrets maps a route's path to the secret its requests are signed with
+	webhookSecrets map[string]string
+)
+
+// loadWebhookSecrets reads path=secret pairs from WEBHOOK_SECRETS. The current
+// secrets are kept if it cannot be parsed.
+func loadWebhookSecrets() error {
+	secrets := make(map[string]string)
+	for _, entry := range strings.Split(getEnv("WEBHOOK_SECRETS", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		path, secret, ok := strings.Cut(entry, "=")
+		path, secret = strings.TrimSpace(path), strings.TrimSpace(secret)
+		if !ok || !strings.HasPrefix(path, "/") || secret == "" {
+			return fmt.Errorf("invalid webhook secret %q: expected /path=secret", path)
+		}
+		if _, exists := secrets[path]; exists {
+			return fmt.Errorf("duplicate webhook secret for %s", path)
+		}
+		secrets[path] = secret
+	}
+
+	for _, secret := range secrets {
+		registerSecret(secret)
+	}
+	webhookSecretsMu.Lock()
+	webhookSecrets = secrets
+	webhookSecretsMu.Unlock()
+	return nil
+}
+
+// webhookSecret returns the secret that POSTs to path must be signed with
+func webhookSecret(path string) (string, bool) {
+	webhookSecretsMu.RLock()
+	defer webhookSecretsMu.RUnlock()
+	secret, ok := webhookSecrets[path]
+	return secret, ok
+}
+
+// signedRoute reports whether the request must carry a signature, which then
+// stands in for an API key
+func signedRoute(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	_, ok := webhookSecret(r.URL.Path)
+	return ok
+}
+
+// checkSignature verifies a GitHub-style X-Hub-Signature-256 header, the hex
+// HMAC-SHA256 of the body keyed with the secret
+func checkSignature(header string, body []byte, secret string) error {
+	sig, ok := strings.CutPrefix(header, signaturePrefix)
+	if !ok {
+		return fmt.Errorf("missing %s header", signatureHeader)
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// requireSignature rejects POSTs to routes with a webhook secret unless their
+// body is signed with it
+func requireSignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := webhookSecret(r.URL.Path)
+		if !ok || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodySize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := checkSignature(r.Header.Get(signatureHeader), body, secret); err != nil {
+			log.Printf("[%s] Rejected %s %s from %s: %v", requestID(r.Context()), r.Method, r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		// Hand the handler the body that was read to check the signature
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
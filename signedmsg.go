@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SIGNED_MESSAGES modes
+const (
+	signedMessagesOptional = "optional"
+	signedMessagesRequire  = "require"
+)
+
+// signedMessage wraps a message with the identity that sent it, for example
+// {"identity":"ci","signature":"sha256=...","message":{"restart":"..."}}.
+// The signature is the hex HMAC-SHA256 of the message exactly as written,
+// keyed with the API key of that name. Messages the service queues for itself
+// carry no identity and are signed with MESSAGE_SIGNING_KEY.
+type signedMessage struct {
+	Identity  string          `json:"identity,omitempty"`
+	Signature string          `json:"signature"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// checkMessageSigning validates SIGNED_MESSAGES and MESSAGE_SIGNING_KEY
+func checkMessageSigning() error {
+	switch signedMessages {
+	case signedMessagesOptional:
+	case signedMessagesRequire:
+		if messageSigningKey == "" {
+			return errors.New("MESSAGE_SIGNING_KEY must be set when SIGNED_MESSAGES=require, so messages the service queues for itself are accepted")
+		}
+	default:
+		return fmt.Errorf("invalid SIGNED_MESSAGES %q: expected %s or %s", signedMessages, signedMessagesOptional, signedMessagesRequire)
+	}
+	registerSecret(messageSigningKey)
+	return nil
+}
+
+// messageSignature returns the signature of data keyed with key
+func messageSignature(data []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// signMessage signs a message the service queues for itself with
+// MESSAGE_SIGNING_KEY. It is returned unchanged when no key is set.
+func signMessage(data []byte) []byte {
+	if messageSigningKey == "" {
+		return data
+	}
+	// Built by hand, since encoding the message with json.Marshal could change
+	// the bytes that were signed
+	return []byte(`{"signature":` + strconv.Quote(messageSignature(data, messageSigningKey)) + `,"message":` + string(data) + `}`)
+}
+
+// messageKey returns the key that messages signed by identity are checked with
+func messageKey(identity string) (string, bool) {
+	if identity == "" {
+		return messageSigningKey, messageSigningKey != ""
+	}
+	for _, k := range getAPIKeys() {
+		if k.Name == identity {
+			return k.Key, true
+		}
+	}
+	return "", false
+}
+
+// verifyMessage unwraps a signed message, returning the message inside and
+// the identity that signed it. Unsigned messages are returned as they are,
+// unless SIGNED_MESSAGES=require.
+func verifyMessage(message string) (string, string, error) {
+	var signed signedMessage
+	if !strings.HasPrefix(strings.TrimSpace(message), "{") || json.Unmarshal([]byte(message), &signed) != nil || signed.Signature == "" {
+		if signedMessages == signedMessagesRequire {
+			return "", "", errors.New("refusing unsigned message: SIGNED_MESSAGES=require")
+		}
+		return message, "", nil
+	}
+
+	key, ok := messageKey(signed.Identity)
+	if !ok {
+		return "", "", fmt.Errorf("no key to check messages signed by %q", signed.Identity)
+	}
+	if !strings.HasPrefix(signed.Signature, signaturePrefix) {
+		return "", "", errors.New("malformed message signature")
+	}
+	if err := checkSignature(signed.Signature, signed.Message, key); err != nil {
+		return "", "", fmt.Errorf("invalid message signature: %w", err)
+	}
+	return string(signed.Message), signed.Identity, nil
+}
+
+// receiveMessage checks the signature of a message taken from Redis or another
+// broker and processes it on behalf of whoever signed it
+func receiveMessage(ctx context.Context, rdb *redis.Client, message string) error {
+	message, identity, err := verifyMessage(message)
+	if err != nil {
+		return err
+	}
+	if identity != "" {
+		ctx = context.WithValue(withSource(ctx, messageSource(ctx)+":"+identity), identityContextKey{}, identity)
+	}
+	return processMessage(ctx, rdb, message)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// slackMaxSkew is how old a request's timestamp may be, to stop replays
+	slackMaxSkew = 5 * time.Minute
+	// slackReplyWait is how long to wait for a command's result before
+	// acknowledging it, since Slack expects a reply within three seconds
+	slackReplyWait = 2500 * time.Millisecond
+)
+
+// slackResponse is a message posted back to Slack
+type slackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// handleSlackCommand handles POST /slack/commands, Slack's slash command
+// protocol, e.g. "/service restart InnerGate". The result is posted in the
+// channel. Commands that take longer than Slack waits for are acknowledged
+// first and their result is sent to the command's response_url.
+func handleSlackCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackSignature(r.Header, body); err != nil {
+		log.Printf("Rejected Slack command: %v", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid form: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	text := strings.TrimSpace(form.Get("text"))
+	if text == "" || text == "help" {
+		writeSlackResponse(w, slackResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("Usage: `%s <up|down|restart|toggle|status|action> <repo>`", form.Get("command")),
+		})
+		return
+	}
+
+	user := form.Get("user_name")
+	log.Printf("Received Slack command from %s: %s", user, text)
+	done := make(chan slackResponse, 1)
+	go func() {
+		cmd, err := runChatCommand(text, "slack:"+user, "")
+		done <- slackResponse{ResponseType: "in_channel", Text: slackResultText(user, text, cmd, err)}
+	}()
+
+	select {
+	case resp := <-done:
+		writeSlackResponse(w, resp)
+	case <-time.After(slackReplyWait):
+		writeSlackResponse(w, slackResponse{ResponseType: "ephemeral", Text: fmt.Sprintf("Working on `%s`...", text)})
+		go postSlackResponse(form.Get("response_url"), <-done)
+	}
+}
+
+// verifySlackSignature checks the request was signed with
+// SLACK_SIGNING_SECRET, as described in Slack's request verification guide
+func verifySlackSignature(header http.Header, body []byte) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", ts)
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > slackMaxSkew || age < -slackMaxSkew {
+		return fmt.Errorf("timestamp %s is too far from the current time", ts)
+	}
+
+	mac := hmac.New(sha256.New, []byte(slackSigningSecret))
+	fmt.Fprintf(mac, "v0:%s:", ts)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// slackResultText describes the outcome of a command for the channel
+func slackResultText(user, text string, cmd chatCommand, err error) string {
+	if err != nil {
+		return fmt.Sprintf("@%s `%s` failed: %v", user, text, err)
+	}
+	if cmd.Status != nil {
+		if !cmd.Status.Found {
+			return fmt.Sprintf("No project matches %s", cmd.Target)
+		}
+		status := fmt.Sprintf("*%s* is %s", cmd.Status.Repo, cmd.Status.State)
+		if cmd.Status.LastAction != "" {
+			status += fmt.Sprintf(" (last action: %s at %s)", cmd.Status.LastAction, cmd.Status.LastActionAt)
+		}
+		return status
+	}
+	return fmt.Sprintf("@%s %s *%s* (%s): %s", user, cmd.Action, cmd.Target, strings.Join(cmd.Repos, ", "), cmd.Result.Message)
+}
+
+func writeSlackResponse(w http.ResponseWriter, resp slackResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// postSlackResponse sends a delayed result to a slash command's response_url
+func postSlackResponse(responseURL string, resp slackResponse) {
+	if !strings.HasPrefix(responseURL, "https://hooks.slack.com/") {
+		log.Printf("Not posting Slack result to unexpected response_url %q", responseURL)
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error encoding Slack response: %v", err)
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Post(responseURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Error posting Slack response: %v", err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		log.Printf("Error posting Slack response: %s", res.Status)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+	"github.com/redis/go-redis/v9"
+)
+
+const snapshotVersion = 1
+
+var errInvalidSnapshot = errors.New("invalid snapshot")
+
+// Snapshot is the state of the service that is not part of the project
+// configuration, for moving it to another instance
+type Snapshot struct {
+	Version         int                `json:"version"`
+	ExportedAt      time.Time          `json:"exportedAt"`
+	States          []ProjectState     `json:"states"`
+	Scheduled       []scheduledMessage `json:"scheduled"`
+	DesiredStates   map[string]string  `json:"desiredStates"`
+	PausedSchedules []string           `json:"pausedSchedules"`
+}
+
+// exportSnapshot collects the tracked states, pending scheduled jobs, desired
+// state overrides, and paused schedules
+func exportSnapshot(ctx context.Context, rdb *redis.Client) (Snapshot, error) {
+	snapshot := Snapshot{Version: snapshotVersion, ExportedAt: time.Now().UTC(), States: []ProjectState{}}
+
+	stateMu.RLock()
+	for _, s := range projectStates {
+		snapshot.States = append(snapshot.States, s)
+	}
+	stateMu.RUnlock()
+	sort.Slice(snapshot.States, func(i, j int) bool { return snapshot.States[i].Repo < snapshot.States[j].Repo })
+
+	var err error
+	if snapshot.Scheduled, err = listScheduledMessages(ctx, rdb); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.DesiredStates, err = rdb.HGetAll(ctx, desiredStateKey).Result(); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read desired states: %w", err)
+	}
+	if snapshot.PausedSchedules, err = rdb.SMembers(ctx, schedulesPausedKey).Result(); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read paused schedules: %w", err)
+	}
+	sort.Strings(snapshot.PausedSchedules)
+	return snapshot, nil
+}
+
+// importSnapshot merges a snapshot into this instance. Entries in the snapshot
+// replace existing ones for the same repo, job, or schedule; anything else is
+// kept.
+func importSnapshot(ctx context.Context, rdb *redis.Client, snapshot Snapshot) error {
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d (expected %d)", errInvalidSnapshot, snapshot.Version, snapshotVersion)
+	}
+
+	existing, err := listScheduledMessages(ctx, rdb)
+	if err != nil {
+		return err
+	}
+	scheduled := make(map[string]bool, len(existing))
+	for _, job := range existing {
+		scheduled[job.ID] = true
+	}
+
+	pipe := rdb.TxPipeline()
+	for _, s := range snapshot.States {
+		if s.Repo == "" {
+			return fmt.Errorf("%w: state without a repo", errInvalidSnapshot)
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("failed to marshal state of %s: %w", s.Repo, err)
+		}
+		pipe.HSet(ctx, stateKey, s.Repo, data)
+	}
+	for _, job := range snapshot.Scheduled {
+		if scheduled[job.ID] {
+			continue
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			return fmt.Errorf("failed to marshal scheduled job %s: %w", job.ID, err)
+		}
+		pipe.ZAdd(ctx, scheduleKey, redis.Z{Score: float64(job.DueAt.UnixMilli()), Member: data})
+	}
+	for repo, state := range snapshot.DesiredStates {
+		if err := validateDesiredState(state); err != nil {
+			return fmt.Errorf("%w: desired state of %s: %w", errInvalidSnapshot, repo, err)
+		}
+		pipe.HSet(ctx, desiredStateKey, repo, state)
+	}
+	for _, id := range snapshot.PausedSchedules {
+		pipe.SAdd(ctx, schedulesPausedKey, id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to import snapshot: %w", err)
+	}
+
+	stateMu.Lock()
+	for _, s := range snapshot.States {
+		projectStates[s.Repo] = s
+	}
+	stateMu.Unlock()
+	for _, s := range snapshot.States {
+		settleLater(s, max(0, stateSettleDelay-time.Since(s.LastActionAt)))
+	}
+	return nil
+}
+
+// handleExportSnapshot handles GET /snapshot
+func handleExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := exportSnapshot(r.Context(), redisClient)
+	if err != nil {
+		log.Printf("Error exporting snapshot: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to export snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleImportSnapshot handles POST /snapshot
+func handleImportSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !requireLeader(w, r) {
+		return
+	}
+	if err := authorizeConfigAll(contextIdentity(r.Context())); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := importSnapshot(r.Context(), redisClient, snapshot); err != nil {
+		if errors.Is(err, errInvalidSnapshot) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error importing snapshot: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to import snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+	message := fmt.Sprintf("Imported %d states and %d scheduled jobs", len(snapshot.States), len(snapshot.Scheduled))
+	log.Println(message)
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: message})
+}
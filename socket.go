@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// listenUnixSocket listens on HTTP_SOCKET for host-local clients, applying
+// HTTP_SOCKET_MODE and HTTP_SOCKET_GROUP to the socket file. A socket left
+// behind by a previous run is replaced.
+func listenUnixSocket() (net.Listener, error) {
+	mode, err := strconv.ParseUint(httpSocketMode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP_SOCKET_MODE %q: %w", httpSocketMode, err)
+	}
+
+	if info, err := os.Lstat(httpSocket); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", httpSocket)
+		}
+		if err := os.Remove(httpSocket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", httpSocket, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to check socket %s: %w", httpSocket, err)
+	}
+
+	l, err := net.Listen("unix", httpSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", httpSocket, err)
+	}
+	if err := os.Chmod(httpSocket, fs.FileMode(mode)); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set permissions of %s: %w", httpSocket, err)
+	}
+	if httpSocketGroup != "" {
+		gid, err := lookupGroupID(httpSocketGroup)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		if err := os.Chown(httpSocket, -1, gid); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to set group of %s: %w", httpSocket, err)
+		}
+	}
+	return l, nil
+}
+
+// lookupGroupID resolves a group name or numeric ID
+func lookupGroupID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("invalid HTTP_SOCKET_GROUP %q: %w", group, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
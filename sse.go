@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sseKeepAlive is how often a comment is sent on an idle event stream so
+// that proxies do not close it
+const sseKeepAlive = 30 * time.Second
+
+// sseShutdown is closed when the HTTP server shuts down, ending the streams
+// that would otherwise keep it waiting
+var sseShutdown = make(chan struct{})
+
+// handleEvents handles GET /events, streaming events as Server-Sent Events
+// with the event type as the SSE event name and the event as JSON data. The
+// repo query parameter limits the stream to one project and the type query
+// parameter, a comma-separated list, to the given event types.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	repo := r.URL.Query().Get("repo")
+	var types []string
+	if t := r.URL.Query().Get("type"); t != "" {
+		types = strings.Split(t, ",")
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := subscribeEvents()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sseShutdown:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case e := <-events:
+			if repo != "" && !e.concerns(repo) {
+				continue
+			}
+			if len(types) > 0 && !slices.Contains(types, e.Type) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("Error encoding event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProjectState is the tracked state of a project, derived from the actions
+// dispatched for it and, when available, the results reported for them. It
+// reflects what was requested of Poppit; the health fields hold the result of
+// the project's healthCheck, if it has one.
+type ProjectState struct {
+	Repo         string    `json:"repo,omitempty"`
+	State        string    `json:"state"`
+	LastAction   string    `json:"lastAction,omitempty"`
+	LastActionAt time.Time `json:"lastActionAt,omitempty"`
+	// LastCorrelationID identifies the last action, so that its result can be
+	// told apart from those of earlier actions
+	LastCorrelationID string    `json:"lastCorrelationId,omitempty"`
+	StateChangedAt    time.Time `json:"stateChangedAt,omitempty"`
+	Health            string    `json:"health,omitempty"`
+	HealthCheckedAt   time.Time `json:"healthCheckedAt,omitempty"`
+	HealthError       string    `json:"healthError,omitempty"`
+	HealthFailures    int       `json:"healthFailures,omitempty"`
+	// AutoRestarts counts the automatic restarts since the project was last
+	// healthy
+	AutoRestarts      int       `json:"autoRestarts,omitempty"`
+	LastAutoRestartAt time.Time `json:"lastAutoRestartAt,omitempty"`
+	AutoRestartGaveUp bool      `json:"autoRestartGaveUp,omitempty"`
+	// Flapping is set while the project restarts too often for automatic
+	// restarts to be allowed
+	Flapping bool `json:"flapping,omitempty"`
+	// LastActivityAt is when the project's activity signal last changed, and
+	// IdleStopped is set while it is down because it was idle
+	LastActivityAt time.Time `json:"lastActivityAt,omitempty"`
+	IdleStopped    bool      `json:"idleStopped,omitempty"`
+}
+
+// Project states. Dispatching up or restart moves a project to starting and
+// dispatching down moves it to stopping; the result of the action then moves
+// it to up, down, or failed.
+const (
+	stateUnknown  = "unknown"
+	stateStarting = "starting"
+	stateUp       = "up"
+	stateStopping = "stopping"
+	stateDown     = "down"
+	stateFailed   = "failed"
+)
+
+var allStates = []string{stateUnknown, stateStarting, stateUp, stateStopping, stateDown, stateFailed}
+
+var (
+	stateMu       sync.RWMutex
+	projectStates = make(map[string]ProjectState)
+)
+
+// setState moves repo to a new state, logging the transition. The caller must
+// hold stateMu.
+func setState(s *ProjectState, state string, now time.Time) {
+	if s.State == state {
+		return
+	}
+	log.Printf("State of %s: %s -> %s", s.Repo, s.State, state)
+	publishEvent(Event{Type: eventState, Repo: s.Repo, State: state, PreviousState: s.State, At: now})
+	s.State = state
+	s.StateChangedAt = now
+}
+
+// recordDispatch updates the tracked state of repo after an action has been
+// sent to Poppit. Custom actions are recorded but do not change the state.
+// Unless STATE_ASSUME_SUCCESS is disabled, the action is treated as successful
+// after STATE_SETTLE_DELAY.
+func recordDispatch(repo, action, correlationID string) {
+	now := time.Now().UTC()
+
+	stateMu.Lock()
+	s, ok := projectStates[repo]
+	if !ok {
+		s = ProjectState{Repo: repo, State: stateUnknown}
+	}
+	switch action {
+	case "up", "restart":
+		setState(&s, stateStarting, now)
+		s.IdleStopped = false
+	case "down":
+		setState(&s, stateStopping, now)
+		s.IdleStopped = false
+	}
+	s.LastAction = action
+	s.LastActionAt = now
+	s.LastCorrelationID = correlationID
+	projectStates[repo] = s
+	stateMu.Unlock()
+
+	persistState(s)
+	settleLater(s, stateSettleDelay)
+	if action == "restart" {
+		recordRestart(repo)
+	}
+}
+
+// settleLater schedules the assumed success of a starting or stopping project
+func settleLater(s ProjectState, delay time.Duration) {
+	if !stateAssumeSuccess || (s.State != stateStarting && s.State != stateStopping) {
+		return
+	}
+	time.AfterFunc(delay, func() {
+		completeAction(s.Repo, s.LastAction, s.LastActionAt, true)
+	})
+}
+
+// completeAction applies the result of the action dispatched for repo at the
+// given time. Results for an action that has since been superseded are ignored.
+// A failure is applied even if the action was already assumed to succeed.
+func completeAction(repo, action string, dispatchedAt time.Time, success bool) {
+	stateMu.Lock()
+	s, ok := projectStates[repo]
+	if !ok || s.LastAction != action || !s.LastActionAt.Equal(dispatchedAt) {
+		stateMu.Unlock()
+		return
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case !success && slices.Contains(builtinActions, action):
+		setState(&s, stateFailed, now)
+	case !success:
+		// A failed custom action says nothing about whether the project is up
+	case s.State == stateStarting:
+		setState(&s, stateUp, now)
+	case s.State == stateStopping:
+		setState(&s, stateDown, now)
+	}
+	projectStates[repo] = s
+	stateMu.Unlock()
+
+	persistState(s)
+}
+
+// persistState stores the state of a project in the STATE_KEY Redis hash so
+// that it survives restarts and can be read by other tools
+func persistState(s ProjectState) {
+	if redisClient == nil {
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("Error persisting state of %s: %v", s.Repo, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := redisClient.HSet(ctx, stateKey, s.Repo, data).Err(); err != nil {
+		log.Printf("Error persisting state of %s: %v", s.Repo, err)
+	}
+}
+
+// loadStates restores the tracked project states from Redis on startup.
+// Projects left starting or stopping are settled once their remaining
+// STATE_SETTLE_DELAY has passed.
+func loadStates(ctx context.Context) error {
+	entries, err := redisClient.HGetAll(ctx, stateKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load project states: %w", err)
+	}
+
+	loaded := make(map[string]ProjectState, len(entries))
+	for repo, data := range entries {
+		var s ProjectState
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			log.Printf("Skipping unreadable state of %s: %v", repo, err)
+			continue
+		}
+		s.Repo = repo
+		loaded[repo] = s
+	}
+
+	stateMu.Lock()
+	projectStates = loaded
+	stateMu.Unlock()
+
+	for _, s := range loaded {
+		settleLater(s, max(0, stateSettleDelay-time.Since(s.LastActionAt)))
+	}
+	log.Printf("Loaded state of %d projects from %s", len(loaded), stateKey)
+	return nil
+}
+
+// getProjectState returns the tracked state of repo
+func getProjectState(repo string) ProjectState {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	if s, ok := projectStates[repo]; ok {
+		return s
+	}
+	return ProjectState{Repo: repo, State: stateUnknown}
+}
+
+// validateIfState checks the ifState condition of a message
+func validateIfState(state string) error {
+	if state == "" {
+		return nil
+	}
+	for _, s := range allStates {
+		if s == state {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid ifState %q (expected one of %v)", state, allStates)
+}
+
+// toggledAction returns the action that flips the tracked state of repo:
+// down if it is up or starting, and up otherwise
+func toggledAction(repo string) string {
+	switch getProjectState(repo).State {
+	case stateUp, stateStarting:
+		return "down"
+	}
+	return "up"
+}
+
+// handleListStates handles GET /state, returning the state of every configured
+// project sorted by repo
+func handleListStates(w http.ResponseWriter, r *http.Request) {
+	states := []ProjectState{}
+	for _, p := range allProjects() {
+		states = append(states, getProjectState(p.Repo))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Repo < states[j].Repo })
+
+	writeJSON(w, http.StatusOK, states)
+}
+
+// handleGetState handles GET /state/{owner}/{name}
+func handleGetState(w http.ResponseWriter, r *http.Request) {
+	repo := r.PathValue("owner") + "/" + r.PathValue("name")
+	project, exists := lookupProject(repo)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Project %s not found", repo), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, getProjectState(project.Repo))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StatusReply answers a status query with a project's configured commands and
+// its tracked state
+type StatusReply struct {
+	Repo            string              `json:"repo"`
+	Found           bool                `json:"found"`
+	State           string              `json:"state,omitempty"`
+	LastAction      string              `json:"lastAction,omitempty"`
+	LastActionAt    string              `json:"lastActionAt,omitempty"`
+	UpCommands      []string            `json:"upCommands,omitempty"`
+	DownCommands    []string            `json:"downCommands,omitempty"`
+	RestartCommands []string            `json:"restartCommands,omitempty"`
+	Actions         map[string][]string `json:"actions,omitempty"`
+	CorrelationID   string              `json:"correlationId,omitempty"`
+}
+
+// buildStatusReply describes the project addressed by repo. Resolved secrets
+// are redacted from the commands.
+func buildStatusReply(repo, correlationID string) StatusReply {
+	reply := StatusReply{Repo: repo, CorrelationID: correlationID}
+	project, exists := lookupProject(repo)
+	if !exists {
+		return reply
+	}
+
+	project = transformProject(project, func(v string) string {
+		return string(redact([]byte(v)))
+	})
+	state := getProjectState(project.Repo)
+
+	reply.Repo = project.Repo
+	reply.Found = true
+	reply.State = state.State
+	reply.LastAction = state.LastAction
+	if !state.LastActionAt.IsZero() {
+		reply.LastActionAt = state.LastActionAt.Format(time.RFC3339)
+	}
+	reply.UpCommands = project.UpCommands
+	reply.DownCommands = project.DownCommands
+	reply.RestartCommands = project.RestartCommands
+	reply.Actions = project.Actions
+	return reply
+}
+
+// replyStatus pushes the status of the project named in msg to its replyTo list
+func replyStatus(ctx context.Context, rdb *redis.Client, msg RedisMessage) error {
+	if msg.ReplyTo == "" {
+		return fmt.Errorf("status query for %s has no replyTo list", msg.Status)
+	}
+
+	reply := buildStatusReply(msg.Status, msg.CorrelationID)
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return fmt.Errorf("failed to marshal status reply: %w", err)
+	}
+	if err := rdb.RPush(ctx, msg.ReplyTo, data).Err(); err != nil {
+		return fmt.Errorf("failed to push status reply to %s: %w", msg.ReplyTo, err)
+	}
+
+	log.Printf("[%s] Sent status of %s to %s", msg.CorrelationID, msg.Status, msg.ReplyTo)
+	return nil
+}
+
+// handleStatusMessage handles a {"status": "<repo>"} message posted to
+// /messages. The reply is returned in the response, and also pushed to the
+// replyTo list if one is given.
+func handleStatusMessage(w http.ResponseWriter, r *http.Request, msg RedisMessage) {
+	if msg.ReplyTo != "" {
+		if err := replyStatus(r.Context(), redisClient, msg); err != nil {
+			log.Printf("Error replying to status query %s: %v", msg.CorrelationID, err)
+			http.Error(w, fmt.Sprintf("Failed to send status reply: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	reply := buildStatusReply(msg.Status, msg.CorrelationID)
+	status := http.StatusOK
+	if !reply.Found {
+		status = http.StatusNotFound
+	}
+
+	writeJSON(w, status, reply)
+}
+
+// ProjectStatus is a project's tracked state together with the scheduled
+// jobs still pending for it
+type ProjectStatus struct {
+	ProjectState
+	Scheduled []scheduledMessage `json:"scheduled"`
+}
+
+// handleGetProjectStatus handles GET /projects/{owner}/{name}/status
+func handleGetProjectStatus(w http.ResponseWriter, r *http.Request) {
+	repo := r.PathValue("owner") + "/" + r.PathValue("name")
+	project, exists := lookupProject(repo)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Project %s not found", repo), http.StatusNotFound)
+		return
+	}
+
+	jobs, err := listScheduledMessages(r.Context(), redisClient)
+	if err != nil {
+		log.Printf("Error listing scheduled jobs for %s: %v", project.Repo, err)
+		http.Error(w, fmt.Sprintf("Failed to list scheduled jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := ProjectStatus{ProjectState: getProjectState(project.Repo), Scheduled: []scheduledMessage{}}
+	for _, job := range jobs {
+		if _, target, ok := job.Message.actionTarget(); ok && targetIncludes(target, project) {
+			status.Scheduled = append(status.Scheduled, job)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// streamMessage returns the command carried by a stream entry: its "message"
+// field, or its only field if it has just one
+func streamMessage(entry redis.XMessage) (string, error) {
+	if value, ok := entry.Values["message"]; ok {
+		return fmt.Sprint(value), nil
+	}
+	if len(entry.Values) == 1 {
+		for _, value := range entry.Values {
+			return fmt.Sprint(value), nil
+		}
+	}
+	return "", fmt.Errorf("stream entry %s has no \"message\" field", entry.ID)
+}
+
+// runStreamConsumer consumes commands from SOURCE_STREAM as a member of the
+// SOURCE_STREAM_GROUP consumer group. Entries are acknowledged only once they
+// have been processed, so a crash leaves them pending: this consumer replays
+// its own pending entries on startup, and entries left pending by a consumer
+// that has gone away are claimed after STREAM_CLAIM_IDLE.
+func runStreamConsumer(ctx context.Context, rdb *redis.Client) error {
+	err := rdb.XGroupCreateMkStream(ctx, sourceStream, sourceStreamGroup, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s on %s: %w", sourceStreamGroup, sourceStream, err)
+	}
+
+	go func() {
+		// Replay entries this consumer read but did not acknowledge before
+		// it last stopped, then read new ones
+		replaying := true
+		lastClaim := time.Now()
+		for ctx.Err() == nil {
+			if !isLeader() {
+				time.Sleep(time.Second)
+				continue
+			}
+
+			if time.Since(lastClaim) >= streamClaimIdle {
+				claimStaleEntries(ctx, rdb)
+				lastClaim = time.Now()
+			}
+
+			id := ">"
+			if replaying {
+				id = "0"
+			}
+			streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    sourceStreamGroup,
+				Consumer: instanceID,
+				Streams:  []string{sourceStream, id},
+				Count:    10,
+				Block:    5 * time.Second,
+			}).Result()
+			if err != nil {
+				if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+					continue
+				}
+				log.Printf("Error reading from stream %s: %v", sourceStream, err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			read := 0
+			for _, stream := range streams {
+				read += len(stream.Messages)
+				for _, entry := range stream.Messages {
+					processStreamEntry(ctx, rdb, entry)
+				}
+			}
+			if replaying && read == 0 {
+				replaying = false
+			}
+		}
+	}()
+
+	log.Printf("Consuming stream %s as %s in group %s", sourceStream, instanceID, sourceStreamGroup)
+	return nil
+}
+
+// processStreamEntry processes a stream entry and acknowledges it. Entries
+// whose processing fails are acknowledged too, as retrying would fail again.
+func processStreamEntry(ctx context.Context, rdb *redis.Client, entry redis.XMessage) {
+	message, err := streamMessage(entry)
+	if err == nil {
+		log.Printf("Received message from %s (%s): %s", sourceStream, entry.ID, message)
+		err = receiveMessage(withSource(ctx, sourceStream), rdb, message)
+	}
+	if err != nil {
+		log.Printf("Error processing stream entry %s: %v", entry.ID, err)
+	}
+	if ctx.Err() != nil {
+		// Leave the entry pending to be replayed
+		return
+	}
+	if err := rdb.XAck(ctx, sourceStream, sourceStreamGroup, entry.ID).Err(); err != nil {
+		log.Printf("Error acknowledging stream entry %s: %v", entry.ID, err)
+	}
+}
+
+// claimStaleEntries takes over and processes entries that another consumer
+// read but has not acknowledged for STREAM_CLAIM_IDLE, e.g. because it crashed
+func claimStaleEntries(ctx context.Context, rdb *redis.Client) {
+	start := "0"
+	for {
+		entries, next, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   sourceStream,
+			Group:    sourceStreamGroup,
+			MinIdle:  streamClaimIdle,
+			Start:    start,
+			Count:    10,
+			Consumer: instanceID,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error claiming pending entries on %s: %v", sourceStream, err)
+			}
+			return
+		}
+		for _, entry := range entries {
+			log.Printf("Claimed pending stream entry %s", entry.ID)
+			processStreamEntry(ctx, rdb, entry)
+		}
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
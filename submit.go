@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// errInvalidMessage marks messages rejected before any processing, so
+// callers can report them as the client's fault
+var errInvalidMessage = errors.New("invalid message")
+
+// submitResult describes what became of a submitted action message
+type submitResult struct {
+	CorrelationID string `json:"correlationId"`
+	Message       string `json:"message"`
+	JobID         string `json:"jobId,omitempty"`
+	Queued        bool   `json:"queued,omitempty"`
+}
+
+// submitMessage validates an action message received from an interactive
+// client and processes it, schedules it if it has a dispatch time, or, on a
+// follower, queues it for the leader. It is processed on behalf of the
+// identity in ctx, if any.
+func submitMessage(ctx context.Context, rdb *redis.Client, msg RedisMessage, source string) (submitResult, error) {
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = newCorrelationID()
+	}
+	result := submitResult{CorrelationID: msg.CorrelationID}
+
+	if _, _, ok := msg.actionTarget(); !ok {
+		return result, fmt.Errorf("%w: message must contain either 'up', 'down', 'restart', or 'toggle' field, or 'action' and 'repo'", errInvalidMessage)
+	}
+	if _, err := priorityList(msg.Priority); err != nil {
+		return result, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	if err := validateIfState(msg.IfState); err != nil {
+		return result, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	expired, err := msg.expired()
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	if expired {
+		return result, fmt.Errorf("%w: message expired at %s", errInvalidMessage, msg.ExpiresAt)
+	}
+	at, err := msg.dispatchTime()
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+
+	// Followers leave dispatching to the leader
+	if !isLeader() {
+		if err := enqueueMessage(ctx, rdb, msg); err != nil {
+			log.Printf("Error queueing message %s for the leader: %v", msg.CorrelationID, err)
+			return result, fmt.Errorf("failed to queue message: %w", err)
+		}
+		result.Queued = true
+		result.Message = "Message queued for the leader"
+		return result, nil
+	}
+
+	if at.After(time.Now()) {
+		id, err := scheduleMessage(ctx, rdb, msg, at)
+		if err != nil {
+			log.Printf("Error scheduling message %s: %v", msg.CorrelationID, err)
+			return result, fmt.Errorf("failed to schedule message: %w", err)
+		}
+		result.JobID = id
+		result.Message = fmt.Sprintf("Message scheduled for %s", at.Format(time.RFC3339))
+		return result, nil
+	}
+
+	messageJSON, err := json.Marshal(msg)
+	if err != nil {
+		return result, fmt.Errorf("failed to process message: %w", err)
+	}
+	if err := processMessage(withSource(ctx, source), rdb, string(messageJSON)); err != nil {
+		log.Printf("Error processing message %s: %v", msg.CorrelationID, err)
+		return result, fmt.Errorf("failed to process message: %w", err)
+	}
+	result.Message = "Message processed successfully"
+	return result, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// groupPrefix marks a message target as a project group rather than a repo
+	groupPrefix = "group:"
+	// allTarget addresses every configured project
+	allTarget = "all"
+	// selectorPrefix marks a message target as a label selector
+	selectorPrefix = "selector:"
+)
+
+// Target is the target of a message action. It is either a string (a repo,
+// alias, pattern, group:<name>, or all) or an object {"selector": "k=v,..."},
+// which is stored as selector:<selector>.
+type Target string
+
+func (t *Target) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = Target(s)
+		return nil
+	}
+	var obj struct {
+		Selector string `json:"selector"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("target must be a string or an object with a selector")
+	}
+	if obj.Selector == "" {
+		return fmt.Errorf("target selector must not be empty")
+	}
+	*t = Target(selectorPrefix + obj.Selector)
+	return nil
+}
+
+// resolveTargets returns the projects addressed by a message target, in the
+// order their actions should be dispatched
+func resolveTargets(target, action string) ([]Project, error) {
+	if target == allTarget {
+		projects := sortForAction(allProjects(), action)
+		log.Printf("Expanded %s to %d projects", allTarget, len(projects))
+		return projects, nil
+	}
+
+	if selector, ok := strings.CutPrefix(target, selectorPrefix); ok {
+		matches, err := selectorMatches(selector)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Selector %s matched %d projects", selector, len(matches))
+		return sortForAction(matches, action), nil
+	}
+
+	if name, ok := strings.CutPrefix(target, groupPrefix); ok {
+		members := groupMembers(name, action)
+		log.Printf("Expanded group %s to %d projects", name, len(members))
+		return members, nil
+	}
+
+	if isGlob(target) {
+		return globMatches(target)
+	}
+
+	if project, exists := lookupProject(target); exists {
+		return []Project{project}, nil
+	}
+	return nil, nil
+}
+
+// targetIncludes reports whether a message target addresses the project,
+// without the logging and limits applied when the target is dispatched
+func targetIncludes(target string, p Project) bool {
+	if target == allTarget {
+		return true
+	}
+	if selector, ok := strings.CutPrefix(target, selectorPrefix); ok {
+		reqs, err := parseSelector(selector)
+		if err != nil {
+			return false
+		}
+		for _, req := range reqs {
+			if (p.Labels[req.key] == req.value) == req.negate {
+				return false
+			}
+		}
+		return true
+	}
+	if name, ok := strings.CutPrefix(target, groupPrefix); ok {
+		return p.Group == name
+	}
+	if isGlob(target) {
+		ok, _ := path.Match(target, p.Repo)
+		return ok
+	}
+	project, exists := lookupProject(target)
+	return exists && project.Repo == p.Repo
+}
+
+// isGlob reports whether target contains glob metacharacters
+func isGlob(target string) bool {
+	return strings.ContainsAny(target, "*?[")
+}
+
+// globMatches returns the projects whose repo matches pattern, sorted by repo.
+// Patterns matching more than maxGlobMatches projects are rejected.
+func globMatches(pattern string) ([]Project, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid repo pattern %q: %w", pattern, err)
+	}
+
+	var matches []Project
+	for _, p := range allProjects() {
+		if ok, _ := path.Match(pattern, p.Repo); ok {
+			matches = append(matches, p)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Repo < matches[j].Repo })
+
+	log.Printf("Pattern %s matched %d projects", pattern, len(matches))
+	if len(matches) > maxGlobMatches {
+		return nil, fmt.Errorf("pattern %s matched %d projects, exceeding the limit of %d", pattern, len(matches), maxGlobMatches)
+	}
+	return matches, nil
+}
+
+// labelRequirement is one term of a label selector
+type labelRequirement struct {
+	key, value string
+	negate     bool
+}
+
+// parseSelector parses a comma-separated label selector such as
+// "team=vibe,tier!=frontend". Every term must hold for a project to match.
+func parseSelector(selector string) ([]labelRequirement, error) {
+	var reqs []labelRequirement
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		req := labelRequirement{}
+		key, value, ok := strings.Cut(term, "!=")
+		if ok {
+			req.negate = true
+		} else if key, value, ok = strings.Cut(term, "="); !ok {
+			return nil, fmt.Errorf("invalid selector term %q: expected key=value or key!=value", term)
+		}
+		req.key, req.value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if req.key == "" {
+			return nil, fmt.Errorf("invalid selector term %q: label key must not be empty", term)
+		}
+		reqs = append(reqs, req)
+	}
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("selector must not be empty")
+	}
+	return reqs, nil
+}
+
+// selectorMatches returns the projects whose labels satisfy the selector
+func selectorMatches(selector string) ([]Project, error) {
+	reqs, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Project
+	for _, p := range allProjects() {
+		ok := true
+		for _, req := range reqs {
+			if (p.Labels[req.key] == req.value) == req.negate {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matches = append(matches, p)
+		}
+	}
+	return matches, nil
+}
+
+// groupMembers returns the projects in the named group sorted by groupOrder
+// (then repo). Members are stopped in the reverse of their start order.
+func groupMembers(name, action string) []Project {
+	var members []Project
+	for _, p := range allProjects() {
+		if p.Group == name {
+			members = append(members, p)
+		}
+	}
+	return sortForAction(members, action)
+}
+
+// sortForAction orders projects by groupOrder (then repo), reversing the
+// order for down so projects stop in the reverse of their start order
+func sortForAction(projects []Project, action string) []Project {
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].GroupOrder != projects[j].GroupOrder {
+			return projects[i].GroupOrder < projects[j].GroupOrder
+		}
+		return projects[i].Repo < projects[j].Repo
+	})
+	if action == "down" {
+		slices.Reverse(projects)
+	}
+	return projects
+}
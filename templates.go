@@ -0,0 +1,145 @@
+package main
+
+import "fmt"
+
+// applyTemplates resolves the "extends" reference of every project, returning
+// the projects with inherited fields filled in. Templates cannot themselves
+// extend another template.
+func applyTemplates(config Config) ([]Project, []error) {
+	var errs []error
+	for name, template := range config.Templates {
+		if template.Extends != "" {
+			errs = append(errs, fmt.Errorf("template %s: templates cannot extend other templates", name))
+		}
+	}
+
+	resolved := make([]Project, 0, len(config.Projects))
+	for _, p := range config.Projects {
+		if p.Extends != "" {
+			template, ok := config.Templates[p.Extends]
+			if !ok {
+				errs = append(errs, fmt.Errorf("project %s: extends unknown template %q", p.Repo, p.Extends))
+			} else {
+				p = mergeProject(template, p)
+			}
+		}
+		resolved = append(resolved, p)
+	}
+	return resolved, errs
+}
+
+// mergeProject returns override with any unset fields taken from base. A nil
+// command list inherits from base, while an explicitly empty one does not.
+func mergeProject(base, override Project) Project {
+	merged := override
+	if merged.Dir == "" {
+		merged.Dir = base.Dir
+	}
+	if merged.UpDir == "" {
+		merged.UpDir = base.UpDir
+	}
+	if merged.DownDir == "" {
+		merged.DownDir = base.DownDir
+	}
+	if merged.RestartDir == "" {
+		merged.RestartDir = base.RestartDir
+	}
+	if merged.UpCommands == nil {
+		merged.UpCommands = base.UpCommands
+	}
+	if merged.DownCommands == nil {
+		merged.DownCommands = base.DownCommands
+	}
+	if merged.RestartCommands == nil {
+		merged.RestartCommands = base.RestartCommands
+	}
+	if merged.Cooldown == "" {
+		merged.Cooldown = base.Cooldown
+	}
+	if merged.CooldownMode == "" {
+		merged.CooldownMode = base.CooldownMode
+	}
+	if merged.DependsOn == nil {
+		merged.DependsOn = base.DependsOn
+	}
+	if !merged.BootUp {
+		merged.BootUp = base.BootUp
+	}
+	if !merged.DownOnShutdown {
+		merged.DownOnShutdown = base.DownOnShutdown
+	}
+	if merged.DesiredState == "" {
+		merged.DesiredState = base.DesiredState
+	}
+	if merged.HealthCheck == nil {
+		merged.HealthCheck = base.HealthCheck
+	}
+	if merged.IdleTimeout == "" {
+		merged.IdleTimeout = base.IdleTimeout
+	}
+	if merged.Activity == nil {
+		merged.Activity = base.Activity
+	}
+	if merged.Schedules == nil {
+		merged.Schedules = base.Schedules
+	}
+	if merged.AlertRules == nil {
+		merged.AlertRules = base.AlertRules
+	}
+	if merged.TargetQueue == "" {
+		merged.TargetQueue = base.TargetQueue
+	}
+	if merged.Executor == "" {
+		merged.Executor = base.Executor
+	}
+	if merged.Webhook == nil {
+		merged.Webhook = base.Webhook
+	}
+	if merged.Local == nil {
+		merged.Local = base.Local
+	}
+	if merged.SSH == nil {
+		merged.SSH = base.SSH
+	}
+	if merged.Docker == nil {
+		merged.Docker = base.Docker
+	}
+	if merged.Kubernetes == nil {
+		merged.Kubernetes = base.Kubernetes
+	}
+	if merged.Group == "" {
+		merged.Group = base.Group
+		merged.GroupOrder = base.GroupOrder
+	}
+	if len(base.Actions) > 0 {
+		actions := make(map[string][]string, len(base.Actions)+len(merged.Actions))
+		for name, commands := range base.Actions {
+			actions[name] = commands
+		}
+		for name, commands := range merged.Actions {
+			actions[name] = commands
+		}
+		merged.Actions = actions
+	}
+	if len(base.Labels) > 0 {
+		labels := make(map[string]string, len(base.Labels)+len(merged.Labels))
+		for k, v := range base.Labels {
+			labels[k] = v
+		}
+		for k, v := range merged.Labels {
+			labels[k] = v
+		}
+		merged.Labels = labels
+	}
+	if len(base.Env) > 0 {
+		env := make(map[string]string, len(base.Env)+len(merged.Env))
+		for k, v := range base.Env {
+			env[k] = v
+		}
+		for k, v := range merged.Env {
+			env[k] = v
+		}
+		merged.Env = env
+	}
+	return merged
+}
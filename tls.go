@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// TLS_CLIENT_AUTH modes
+const (
+	clientAuthRequire  = "require"
+	clientAuthOptional = "optional"
+)
+
+var (
+	tlsCertMu sync.RWMutex
+	tlsCert   *tls.Certificate
+)
+
+// tlsEnabled reports whether the HTTP server on PORT speaks HTTPS
+func tlsEnabled() bool {
+	return tlsCertFile != "" || tlsKeyFile != ""
+}
+
+// loadTLSCertificate reads TLS_CERT_FILE and TLS_KEY_FILE. The current
+// certificate is kept if they cannot be read.
+func loadTLSCertificate() error {
+	if !tlsEnabled() {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsCertMu.Lock()
+	tlsCert = &cert
+	tlsCertMu.Unlock()
+	return nil
+}
+
+// serverTLSConfig returns the TLS configuration of the HTTP server. The
+// certificate is looked up on every handshake so a reloaded one is used for
+// new connections straight away.
+func serverTLSConfig() (*tls.Config, error) {
+	if tlsCertFile == "" || tlsKeyFile == "" {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must both be set")
+	}
+	if err := loadTLSCertificate(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			tlsCertMu.RLock()
+			defer tlsCertMu.RUnlock()
+			return tlsCert, nil
+		},
+	}
+
+	if tlsClientCAFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(tlsClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", tlsClientCAFile)
+	}
+	config.ClientCAs = pool
+	switch tlsClientAuth {
+	case clientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case clientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("invalid TLS_CLIENT_AUTH %q: expected %s or %s", tlsClientAuth, clientAuthRequire, clientAuthOptional)
+	}
+	return config, nil
+}
+
+// clientCertIdentity returns the common name of the verified client
+// certificate the request was made with, if any
+func clientCertIdentity(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,153 @@
+"use strict";
+
+const REFRESH_INTERVAL = 5000;
+const HISTORY_LIMIT = 20;
+const ACTIONS = ["up", "down", "restart"];
+
+const keyInput = document.getElementById("api-key");
+const notice = document.getElementById("notice");
+
+keyInput.value = sessionStorage.getItem("apiKey") || "";
+document.getElementById("auth").addEventListener("submit", (e) => {
+  e.preventDefault();
+  sessionStorage.setItem("apiKey", keyInput.value.trim());
+  showNotice("API key saved for this browser session.");
+});
+
+function showNotice(text, isError) {
+  notice.textContent = text;
+  notice.className = isError ? "error" : "";
+  notice.hidden = false;
+}
+
+async function getJSON(path) {
+  const resp = await fetch(path);
+  if (!resp.ok) {
+    throw new Error(`${path}: ${(await resp.text()).trim() || resp.statusText}`);
+  }
+  return resp.json();
+}
+
+function formatTime(value) {
+  if (!value || value.startsWith("0001-")) {
+    return "";
+  }
+  return new Date(value).toLocaleString();
+}
+
+function cell(text, className) {
+  const td = document.createElement("td");
+  td.textContent = text ?? "";
+  if (className) {
+    td.className = className;
+  }
+  return td;
+}
+
+function fillTable(id, rows, emptyText) {
+  const tbody = document.querySelector(`#${id} tbody`);
+  tbody.replaceChildren();
+  if (rows.length === 0) {
+    const tr = document.createElement("tr");
+    const td = cell(emptyText, "muted");
+    td.colSpan = document.querySelectorAll(`#${id} th`).length;
+    tr.append(td);
+    tbody.append(tr);
+    return;
+  }
+  for (const cells of rows) {
+    const tr = document.createElement("tr");
+    tr.append(...cells);
+    tbody.append(tr);
+  }
+}
+
+async function dispatch(action, repo, button) {
+  if (action !== "up" && !confirm(`${action} ${repo}?`)) {
+    return;
+  }
+  const headers = { "Content-Type": "application/json" };
+  const key = sessionStorage.getItem("apiKey");
+  if (key) {
+    headers["Authorization"] = `Bearer ${key}`;
+  }
+
+  button.disabled = true;
+  try {
+    const resp = await fetch("../messages", {
+      method: "POST",
+      headers,
+      body: JSON.stringify({ [action]: repo }),
+    });
+    const text = await resp.text();
+    if (!resp.ok) {
+      throw new Error(resp.status === 401 ? "Unauthorized: set a valid API key" : text.trim());
+    }
+    const reply = JSON.parse(text);
+    showNotice(`${action} ${repo}: ${reply.message} (${reply.correlationId})`);
+  } catch (err) {
+    showNotice(`${action} ${repo} failed: ${err.message}`, true);
+  } finally {
+    button.disabled = false;
+    refresh();
+  }
+}
+
+function renderProjects(states) {
+  fillTable("projects", states.map((s) => {
+    const actions = cell("", "actions");
+    for (const action of ACTIONS) {
+      const button = document.createElement("button");
+      button.type = "button";
+      button.textContent = action;
+      button.addEventListener("click", () => dispatch(action, s.repo, button));
+      actions.append(button, " ");
+    }
+    return [
+      cell(s.repo),
+      cell(s.state, `state state-${s.state}`),
+      cell(s.health, s.health ? `health-${s.health}` : ""),
+      cell(s.lastAction ? `${s.lastAction} ${formatTime(s.lastActionAt)}` : ""),
+      actions,
+    ];
+  }), "No projects configured");
+}
+
+function renderQueues(queues) {
+  fillTable("queues", queues.map((q) => [cell(q.list), cell(String(q.depth))]), "No source lists");
+}
+
+function renderHistory(page) {
+  fillTable("history", page.entries.map((e) => [
+    cell(formatTime(e.timestamp)),
+    cell(e.repo),
+    cell(e.action),
+    cell(e.error ? `${e.outcome}: ${e.error}` : e.outcome, `outcome-${e.outcome}`),
+    cell(e.source, "muted"),
+  ]), "Nothing recorded yet");
+}
+
+async function refresh() {
+  const results = await Promise.allSettled([
+    getJSON("../state").then(renderProjects),
+    getJSON("../queues").then(renderQueues),
+    getJSON(`../history?limit=${HISTORY_LIMIT}`).then(renderHistory),
+  ]);
+  const failed = results.find((r) => r.status === "rejected");
+  if (failed) {
+    showNotice(`Failed to refresh: ${failed.reason.message}`, true);
+  }
+}
+
+// Refresh straight away when something happens, and regularly regardless
+let pending = null;
+const events = new EventSource("../events?type=dispatched,result,state,alert");
+for (const type of ["dispatched", "result", "state", "alert"]) {
+  events.addEventListener(type, () => {
+    clearTimeout(pending);
+    pending = setTimeout(refresh, 250);
+  });
+}
+
+refresh();
+setInterval(refresh, REFRESH_INTERVAL);
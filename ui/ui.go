@@ -0,0 +1,13 @@
+// Package ui holds the static files of the admin web UI served at /ui/
+package ui
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed static
+var static embed.FS
+
+// Files are the UI's files, with index.html at the root
+var Files, _ = fs.Sub(static, "static")
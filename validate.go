@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/api/openapi"
+)
+
+// validateProjects checks the project configuration for problems that would
+// otherwise only surface as broken notifications at runtime
+func validateProjects(config []Project) []error {
+	var errs []error
+	seen := make(map[string]int)
+
+	for i, p := range config {
+		name := p.Repo
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			errs = append(errs, fmt.Errorf("project %s: repo must not be empty", name))
+		} else if first, dup := seen[p.Repo]; dup {
+			errs = append(errs, fmt.Errorf("project %s: duplicate repo (first defined as project #%d)", name, first+1))
+		} else {
+			seen[p.Repo] = i
+		}
+
+		if p.Dir == "" && p.runsCommands() {
+			errs = append(errs, fmt.Errorf("project %s: dir must not be empty", name))
+		} else if p.Dir != "" && !hasReference(p.Dir) && !filepath.IsAbs(p.Dir) {
+			errs = append(errs, fmt.Errorf("project %s: dir must be an absolute path, got %q", name, p.Dir))
+		}
+
+		for _, override := range []struct{ field, dir string }{
+			{"upDir", p.UpDir}, {"downDir", p.DownDir}, {"restartDir", p.RestartDir},
+		} {
+			if override.dir != "" && !hasReference(override.dir) && !filepath.IsAbs(override.dir) {
+				errs = append(errs, fmt.Errorf("project %s: %s must be an absolute path, got %q", name, override.field, override.dir))
+			}
+		}
+
+		if len(p.UpCommands) == 0 && p.runsCommands() {
+			errs = append(errs, fmt.Errorf("project %s: upCommands must contain at least one command", name))
+		}
+		if len(p.DownCommands) == 0 && p.runsCommands() {
+			errs = append(errs, fmt.Errorf("project %s: downCommands must contain at least one command", name))
+		}
+		if p.RestartCommands != nil && len(p.RestartCommands) == 0 {
+			errs = append(errs, fmt.Errorf("project %s: restartCommands is configured but empty", name))
+		}
+		for _, key := range slices.Sorted(maps.Keys(p.Labels)) {
+			if key == "" || strings.ContainsAny(key, "=!,") {
+				errs = append(errs, fmt.Errorf("project %s: invalid label key %q", name, key))
+			}
+		}
+		if err := validateDesiredState(p.DesiredState); err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", name, err))
+		}
+		errs = append(errs, validateActions(name, p.Actions)...)
+		errs = append(errs, validateSchedules(p)...)
+		errs = append(errs, validateAlertRules(p)...)
+		errs = append(errs, validateHealthCheck(p)...)
+		errs = append(errs, validateIdlePolicy(p)...)
+		errs = append(errs, validateCooldown(p)...)
+		errs = append(errs, validateCommandTemplates(p)...)
+		errs = append(errs, validateExecutor(p)...)
+	}
+
+	errs = append(errs, validateAliases(config)...)
+	errs = append(errs, validateDependencies(config)...)
+	return errs
+}
+
+// validateActions rejects custom actions that are unnamed, have no commands,
+// or redefine one of the built-in actions
+func validateActions(project string, actions map[string][]string) []error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(actions)) {
+		switch {
+		case strings.TrimSpace(name) == "":
+			errs = append(errs, fmt.Errorf("project %s: action names must not be empty", project))
+		case slices.Contains(builtinActions, name):
+			errs = append(errs, fmt.Errorf("project %s: action %q redefines a built-in action", project, name))
+		case name == configAction:
+			errs = append(errs, fmt.Errorf("project %s: action %q is reserved for permissions", project, name))
+		case len(actions[name]) == 0:
+			errs = append(errs, fmt.Errorf("project %s: action %q must contain at least one command", project, name))
+		}
+	}
+	return errs
+}
+
+// validateAliases rejects aliases that are empty, shadow a repository name, or
+// are claimed by more than one project. Aliases are compared case-insensitively.
+func validateAliases(config []Project) []error {
+	var errs []error
+
+	repos := make(map[string]bool)
+	for _, p := range config {
+		repos[strings.ToLower(p.Repo)] = true
+	}
+
+	owners := make(map[string]string)
+	for _, p := range config {
+		for _, alias := range p.Aliases {
+			key := strings.ToLower(alias)
+			switch {
+			case key == "":
+				errs = append(errs, fmt.Errorf("project %s: aliases must not be empty", p.Repo))
+			case repos[key]:
+				errs = append(errs, fmt.Errorf("project %s: alias %q conflicts with a configured repo", p.Repo, alias))
+			case owners[key] != "" && owners[key] != p.Repo:
+				errs = append(errs, fmt.Errorf("project %s: alias %q is already used by project %s", p.Repo, alias, owners[key]))
+			default:
+				owners[key] = p.Repo
+			}
+		}
+	}
+
+	return errs
+}
+
+// handleValidateConfig handles POST /config/validate, checking a
+// configuration document without applying it. The format query parameter
+// selects json (the default), yaml, or toml. References to environment
+// variables and secrets are only checked to exist, never resolved, so the
+// errors cannot reveal their values.
+func handleValidateConfig(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if !isConfigFileName("config." + format) {
+		http.Error(w, fmt.Sprintf("Unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	config, err := parseConfig(data, "."+format)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid configuration: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resolved, errs := applyTemplates(config)
+	if err := authorizeConfig(contextIdentity(r.Context()), resolved...); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	for _, p := range resolved {
+		errs = append(errs, checkProjectReferences(p)...)
+	}
+	errs = append(errs, validateProjects(resolved)...)
+	_, formatErrs := buildNotificationFormats(config.Queues)
+	errs = append(errs, formatErrs...)
+	if len(errs) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d error(s) found\n", len(errs))
+		for _, err := range errs {
+			fmt.Fprintf(&b, "  - %v\n", err)
+		}
+		http.Error(w, string(redact([]byte(b.String()))), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, openapi.StatusResponse{Message: fmt.Sprintf("%d projects OK", len(resolved))})
+}
+
+// runValidate implements the "validate" subcommand. It loads the given config
+// file, prints every problem found, and returns the process exit code.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	path := fs.String("config", getConfigFile(), "path to the projects configuration file")
+	dir := fs.String("dir", getConfigDir(), "path to a directory of project configuration files (overrides -config)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	stderr := redactingWriter{w: os.Stderr}
+
+	var config Config
+	var err error
+	if *dir != "" {
+		*path = *dir
+		config, err = readConfigDir(*dir)
+	} else if isRemoteConfig(*path) {
+		config, _, err = fetchRemoteConfig(context.Background(), *path)
+	} else {
+		config, err = readConfigFile(*path)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", *path, err)
+		return 1
+	}
+
+	if discoveryRoot != "" {
+		discovered, err := discoverProjects(discoveryRoot)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		config = mergeDiscoveredProjects(config, discovered)
+	}
+
+	loaded, errs := buildProjects(config)
+	if len(errs) > 0 {
+		fmt.Fprintf(stderr, "%s: %d error(s) found\n", *path, len(errs))
+		for _, err := range errs {
+			fmt.Fprintf(stderr, "  - %v\n", err)
+		}
+		return 1
+	}
+
+	fmt.Printf("%s: %d projects OK\n", *path, len(loaded))
+	return 0
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/its-the-vibe/TurnItOffAndOnAgain/ui"
+)
+
+// uiHandler serves the embedded admin web UI under /ui/. The UI only loads
+// its own files and talks to the API on the same origin.
+func uiHandler() http.Handler {
+	files := http.StripPrefix("/ui/", http.FileServerFS(ui.Files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsWriteWait    = 10 * time.Second
+)
+
+// wsReply answers a message sent by a WebSocket client. Events are sent on
+// the same connection with their own types.
+type wsReply struct {
+	Type          string       `json:"type"`
+	Status        string       `json:"status"`
+	Message       string       `json:"message"`
+	CorrelationID string       `json:"correlationId,omitempty"`
+	JobID         string       `json:"jobId,omitempty"`
+	Queued        bool         `json:"queued,omitempty"`
+	Project       *StatusReply `json:"project,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || slices.Contains(wsAllowedOrigins, "*") || slices.Contains(wsAllowedOrigins, origin) {
+			return true
+		}
+		// Same-origin requests are always allowed
+		return origin == "http://"+r.Host || origin == "https://"+r.Host
+	},
+}
+
+// handleWebSocket handles GET /ws. Clients send messages in any of the
+// formats the source list accepts and receive an "ack" or "error" reply for
+// each, followed by the dispatch, state, and alert events as they happen. The
+// repo query parameter limits the events to one project.
+func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client
+		log.Printf("Error upgrading WebSocket connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	repo := r.URL.Query().Get("repo")
+	// Without a valid API key the connection can only query status and
+	// follow events
+	_, authErr := authorizeRequest(r)
+	canSubmit := authErr == nil
+	identity := contextIdentity(r.Context())
+	source := requestSource(r, "websocket")
+	events, unsubscribe := subscribeEvents()
+	defer unsubscribe()
+
+	// Replies are written by the same goroutine as events, since a
+	// connection supports only one concurrent writer
+	replies := make(chan wsReply, 16)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(done)
+		readWebSocket(conn, replies, stop, source, identity, canSubmit)
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var payload any
+		select {
+		case <-done:
+			return
+		case reply := <-replies:
+			payload = reply
+		case e := <-events:
+			if repo != "" && !e.concerns(repo) {
+				continue
+			}
+			payload = e
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			continue
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteJSON(payload); err != nil {
+			log.Printf("Error writing to WebSocket client: %v", err)
+			return
+		}
+	}
+}
+
+// readWebSocket handles messages from the client until the connection closes
+func readWebSocket(conn *websocket.Conn, replies chan<- wsReply, stop <-chan struct{}, source, identity string, canSubmit bool) {
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Error reading from WebSocket client: %v", err)
+			}
+			return
+		}
+		select {
+		case replies <- handleWebSocketMessage(data, source, identity, canSubmit):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// handleWebSocketMessage processes one message from a client
+func handleWebSocketMessage(data []byte, source, identity string, canSubmit bool) wsReply {
+	var msg RedisMessage
+	var err error
+	if isPlainText(string(data)) {
+		msg, err = parsePlainMessage(string(data))
+	} else {
+		msg, err = decodeMessage(data)
+	}
+	if err != nil {
+		return wsReply{Type: "error", Status: "error", Message: fmt.Sprintf("Invalid message: %v", err)}
+	}
+
+	if msg.Status != "" {
+		reply := buildStatusReply(msg.Status, msg.CorrelationID)
+		return wsReply{Type: "status", Status: "success", Message: "Project status", CorrelationID: msg.CorrelationID, Project: &reply}
+	}
+	if !canSubmit {
+		return wsReply{Type: "error", Status: "error", Message: "Unauthorized: an API key is required to send actions", CorrelationID: msg.CorrelationID}
+	}
+	if msg.Cancel != "" {
+		if err := cancelScheduledMessage(context.WithValue(context.Background(), identityContextKey{}, identity), redisClient, msg.Cancel); err != nil {
+			return wsReply{Type: "error", Status: "error", Message: fmt.Sprintf("Failed to cancel job: %v", err), CorrelationID: msg.CorrelationID}
+		}
+		return wsReply{Type: "ack", Status: "success", Message: fmt.Sprintf("Cancelled scheduled job %s", msg.Cancel), CorrelationID: msg.CorrelationID}
+	}
+
+	if err := authorizeMessage(identity, msg); err != nil {
+		return wsReply{Type: "error", Status: "error", Message: err.Error(), CorrelationID: msg.CorrelationID}
+	}
+
+	result, err := submitMessage(context.WithValue(context.Background(), identityContextKey{}, identity), redisClient, msg, source)
+	if err != nil {
+		if !errors.Is(err, errInvalidMessage) {
+			log.Printf("Error processing WebSocket message %s: %v", result.CorrelationID, err)
+		}
+		return wsReply{Type: "error", Status: "error", Message: err.Error(), CorrelationID: result.CorrelationID}
+	}
+	return wsReply{
+		Type:          "ack",
+		Status:        "success",
+		Message:       result.Message,
+		CorrelationID: result.CorrelationID,
+		JobID:         result.JobID,
+		Queued:        result.Queued,
+	}
+}